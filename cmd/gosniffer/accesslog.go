@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// openAccessLog opens the -access-log destination in format: "-" writes to stdout,
+// anything else is a rotating file added to files. Returns nil if path is empty.
+func openAccessLog(path, format string, files *logFiles) (*logger.AccessLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path == "-" {
+		return logger.NewAccessLog(os.Stdout, format)
+	}
+
+	// Validate the format before creating the file
+	if _, err := logger.NewAccessLog(nil, format); err != nil {
+		return nil, err
+	}
+	file, err := files.open(path)
+	if err != nil {
+		return nil, err
+	}
+	return logger.NewAccessLog(file, format)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// buildBreakpoints creates breakpoints from -breakpoint specs of the form
+// phase:pattern, where phase is request, response or both
+func buildBreakpoints(specs []string, timeout time.Duration, timeoutAction string, log *logger.Logger) (*proxy.Breakpoints, error) {
+	breakpoints := proxy.NewBreakpoints()
+	breakpoints.SetLogger(log)
+	if err := breakpoints.SetTimeout(timeout, timeoutAction); err != nil {
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		phase, pattern, _ := strings.Cut(spec, ":")
+		filter := proxy.BreakpointFilter{Pattern: pattern}
+		switch phase {
+		case proxy.BreakpointPhaseRequest:
+			filter.Request = true
+		case proxy.BreakpointPhaseResponse:
+			filter.Response = true
+		case "both":
+			filter.Request, filter.Response = true, true
+		default:
+			return nil, fmt.Errorf("invalid breakpoint %q: expected request:, response: or both: before the URL pattern", spec)
+		}
+		if _, err := breakpoints.AddFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	return breakpoints, nil
+}
+
+// startBreakpointAPI serves the breakpoint API at http://addr/ in the background
+func startBreakpointAPI(addr string, breakpoints *proxy.Breakpoints, log *logger.Logger) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           breakpoints,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.LogError("breakpoint API server failed", err)
+		}
+	}()
+	log.LogInfo(fmt.Sprintf("Breakpoint API at http://%s/flows", addr))
+	return server
+}
+
+// stopBreakpointAPI shuts the breakpoint API server down within timeout
+func stopBreakpointAPI(server *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	server.Shutdown(ctx)
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/signer"
+)
+
+const (
+	// defaultIssueDays is the validity of certificates minted by "ca issue"
+	// Apple platforms reject TLS server certificates valid for more than 825 days
+	defaultIssueDays = 825
+)
+
+// keySpecHelp lists the accepted -key-type values for flag usage text
+const keySpecHelp = "rsa-2048, rsa-3072, rsa-4096, ecdsa-p256, ecdsa-p384 or ed25519 ('rsa' and 'ecdsa' are aliases)"
+
+// parseKeySpecFlag parses a key type flag value; an empty value yields fallback
+func parseKeySpecFlag(value string, fallback ca.KeySpec) (ca.KeySpec, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return ca.ParseKeySpec(value)
+}
+
+// runCACommand dispatches the "gosniffer ca <subcommand>" command family
+func runCACommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing ca subcommand (available: init, show, verify, issue, intermediate, export, rotate, encrypt-key, agent)")
+	}
+
+	switch args[0] {
+	case "init":
+		return runCAInit(args[1:])
+	case "show":
+		return runCAShow(args[1:])
+	case "verify":
+		return runCAVerify(args[1:])
+	case "issue":
+		return runCAIssue(args[1:])
+	case "intermediate":
+		return runCAIntermediate(args[1:])
+	case "export":
+		return runCAExport(args[1:])
+	case "rotate":
+		return runCARotate(args[1:])
+	case "encrypt-key":
+		return runCAEncryptKey(args[1:])
+	case "agent":
+		return runCAAgent(args[1:])
+	default:
+		return fmt.Errorf("unknown ca subcommand %q (available: init, show, verify, issue, intermediate, export, rotate, encrypt-key, agent)", args[0])
+	}
+}
+
+// runCAInit generates a new root CA with a custom subject, validity and key type
+func runCAInit(args []string) error {
+	fs := flag.NewFlagSet("ca init", flag.ContinueOnError)
+	passphrase := addPassphraseFlags(fs)
+	certPath := fs.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Output path for CA certificate file")
+	keyPath := fs.String("ca-key", getDefaultCAPath("ca-key.pem"), "Output path for CA private key file")
+	keyType := fs.String("key-type", "rsa-2048", "CA key type: "+keySpecHelp)
+	commonName := fs.String("cn", "", "Subject Common Name (default: GoSniffer Root CA)")
+	organization := fs.String("org", "", "Subject Organization")
+	orgUnit := fs.String("ou", "", "Subject Organizational Unit")
+	country := fs.String("country", "", "Subject Country (two-letter code)")
+	days := fs.Int("days", int(ca.DefaultCAValidity/(24*time.Hour)), "Validity period in days")
+	permitDNS := fs.String("permit-dns", "", "Comma-separated DNS domains the CA may sign for (name constraints)")
+	excludeDNS := fs.String("exclude-dns", "", "Comma-separated DNS domains the CA may never sign for")
+	permitIP := fs.String("permit-ip", "", "Comma-separated CIDR ranges the CA may sign for")
+	excludeIP := fs.String("exclude-ip", "", "Comma-separated CIDR ranges the CA may never sign for")
+	force := fs.Bool("force", false, "Overwrite an existing CA")
+	encryptKey := fs.Bool("encrypt-key", false, "Encrypt the private key with a passphrase")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days <= 0 {
+		return fmt.Errorf("-days must be positive")
+	}
+
+	// Replacing a CA silently would break every client that trusts it
+	if _, err := os.Stat(*keyPath); err == nil && !*force {
+		return fmt.Errorf("%s already exists, refusing to overwrite (use -force or 'gosniffer ca rotate')", *keyPath)
+	}
+
+	constraints, err := parseNameConstraints(*permitDNS, *excludeDNS, *permitIP, *excludeIP)
+	if err != nil {
+		return fmt.Errorf("invalid name constraints: %w", err)
+	}
+
+	subject := pkix.Name{CommonName: *commonName}
+	if *organization != "" {
+		subject.Organization = []string{*organization}
+	}
+	if *orgUnit != "" {
+		subject.OrganizationalUnit = []string{*orgUnit}
+	}
+	if *country != "" {
+		subject.Country = []string{*country}
+	}
+
+	spec, err := parseKeySpecFlag(*keyType, "")
+	if err != nil {
+		return err
+	}
+
+	opts := ca.CAOptions{
+		KeyType:         spec,
+		NameConstraints: constraints,
+		Subject:         subject,
+		Validity:        time.Duration(*days) * 24 * time.Hour,
+	}
+	if *encryptKey {
+		if opts.KeyPassphrase, err = passphrase.NewPassphrase(); err != nil {
+			return err
+		}
+	}
+
+	rootCA, err := ca.GenerateCAWithOptions(opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate CA: %w", err)
+	}
+
+	if err := rootCA.SaveToPEM(*certPath, *keyPath); err != nil {
+		return fmt.Errorf("failed to save CA: %w", err)
+	}
+
+	fmt.Printf("CA written to %s (key: %s)\n", *certPath, *keyPath)
+	printCertificate(os.Stdout, rootCA.Certificate)
+	return nil
+}
+
+// runCAShow prints the subject, validity and fingerprint of each certificate in a CA file
+// Only the certificate is read, so the private key does not need to be accessible
+func runCAShow(args []string) error {
+	fs := flag.NewFlagSet("ca show", flag.ContinueOnError)
+	certPath := fs.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to CA certificate file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	certs, err := ca.LoadCertificates(*certPath)
+	if err != nil {
+		return err
+	}
+
+	for i, cert := range certs {
+		if i > 0 {
+			fmt.Println()
+		}
+		printCertificate(os.Stdout, cert)
+	}
+	return nil
+}
+
+// runCAVerify checks that a CA can be used for signing: the key matches the
+// certificate and meets the strength requirements, and the chain is valid
+func runCAVerify(args []string) error {
+	fs := flag.NewFlagSet("ca verify", flag.ContinueOnError)
+	passphrase := addPassphraseFlags(fs)
+	certPath := fs.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to CA certificate file")
+	keyPath := fs.String("ca-key", getDefaultCAPath("ca-key.pem"), "Path to CA private key file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// LoadFromPEMWithPassphrase performs the key match, strength, CA flag and validity checks
+	rootCA, err := ca.LoadFromPEMWithPassphrase(*certPath, *keyPath, passphrase.Passphrase)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	fmt.Printf("OK: private key (%s) matches certificate %q\n", describePublicKey(rootCA.Certificate.PublicKey), rootCA.Certificate.Subject.CommonName)
+	fmt.Println("OK: key strength meets minimum requirements")
+	fmt.Printf("OK: %d certificate(s) in chain are valid CAs until %s\n", len(rootCA.Chain)+1, rootCA.NotAfter().Format(time.RFC3339))
+	if warning := rootCA.ExpiryWarning(ca.ExpiryWarningThreshold); warning != "" {
+		fmt.Printf("WARNING: %s\n", warning)
+	}
+	return nil
+}
+
+// runCAIssue mints a leaf certificate for local development servers from the CA
+// Writes <host>.pem (certificate chain) and <host>-key.pem to the output directory
+func runCAIssue(args []string) error {
+	fs := flag.NewFlagSet("ca issue", flag.ContinueOnError)
+	passphrase := addPassphraseFlags(fs)
+	certPath := fs.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to CA certificate file")
+	keyPath := fs.String("ca-key", getDefaultCAPath("ca-key.pem"), "Path to CA private key file")
+	hosts := fs.String("host", "", "Comma-separated DNS names and IP addresses the certificate is valid for")
+	outDir := fs.String("out", ".", "Output directory for certificate and key files")
+	keyType := fs.String("key-type", "", "Leaf key type: "+keySpecHelp+" (default: same as CA)")
+	days := fs.Int("days", defaultIssueDays, "Validity period in days")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	hostList := splitList(*hosts)
+	if len(hostList) == 0 {
+		return fmt.Errorf("-host is required")
+	}
+	if *days <= 0 {
+		return fmt.Errorf("-days must be positive")
+	}
+
+	rootCA, err := ca.LoadFromPEMWithPassphrase(*certPath, *keyPath, passphrase.Passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to load CA: %w", err)
+	}
+	for _, host := range hostList {
+		if !rootCA.Permits(strings.TrimPrefix(host, "*.")) {
+			return fmt.Errorf("host %s is outside the CA's name constraints", host)
+		}
+	}
+
+	spec, err := parseKeySpecFlag(*keyType, rootCA.KeySpec())
+	if err != nil {
+		return err
+	}
+	bundle, err := rootCA.IssueCertificate(hostList, spec, time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		return fmt.Errorf("failed to issue certificate: %w", err)
+	}
+
+	certPEM, keyPEM, err := bundle.EncodePEM()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", *outDir, err)
+	}
+	base := strings.ReplaceAll(hostList[0], "*", "_wildcard")
+	certOut := filepath.Join(*outDir, base+".pem")
+	keyOut := filepath.Join(*outDir, base+"-key.pem")
+	if err := os.WriteFile(certOut, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate to %s: %w", certOut, err)
+	}
+	if err := os.WriteFile(keyOut, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write private key to %s: %w", keyOut, err)
+	}
+
+	fmt.Printf("Certificate for %s written to %s (key: %s)\n", strings.Join(hostList, ", "), certOut, keyOut)
+	fmt.Printf("Valid until %s\n", bundle.Certificate.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// printCertificate writes a human-readable summary of a certificate
+func printCertificate(w io.Writer, cert *x509.Certificate) {
+	fmt.Fprintf(w, "Subject:     %s\n", cert.Subject)
+	fmt.Fprintf(w, "Issuer:      %s\n", cert.Issuer)
+	fmt.Fprintf(w, "Serial:      %x\n", cert.SerialNumber)
+	fmt.Fprintf(w, "Not Before:  %s\n", cert.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(w, "Not After:   %s\n", cert.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(w, "Key:         %s\n", describePublicKey(cert.PublicKey))
+	fmt.Fprintf(w, "CA:          %t\n", cert.IsCA)
+	if len(cert.DNSNames) > 0 || len(cert.IPAddresses) > 0 {
+		names := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			names = append(names, ip.String())
+		}
+		fmt.Fprintf(w, "Names:       %s\n", strings.Join(names, ", "))
+	}
+	fmt.Fprintf(w, "SHA-256:     %s\n", ca.Fingerprint(cert))
+}
+
+// describePublicKey returns the algorithm and size of a public key, e.g. "RSA 2048-bit"
+func describePublicKey(pub interface{}) string {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d-bit", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
+
+// runCAIntermediate creates an intermediate CA signed by an existing root
+// The written certificate file contains the intermediate followed by the root,
+// so it can be passed directly to -ca-cert while clients only trust the root.
+func runCAIntermediate(args []string) error {
+	fs := flag.NewFlagSet("ca intermediate", flag.ContinueOnError)
+	passphrase := addPassphraseFlags(fs)
+	rootCert := fs.String("root-cert", getDefaultCAPath("ca-cert.pem"), "Path to root CA certificate file")
+	rootKey := fs.String("root-key", getDefaultCAPath("ca-key.pem"), "Path to root CA private key file")
+	certOut := fs.String("cert", getDefaultCAPath("intermediate-cert.pem"), "Output path for intermediate certificate chain")
+	keyOut := fs.String("key", getDefaultCAPath("intermediate-key.pem"), "Output path for intermediate private key")
+	keyType := fs.String("key-type", "rsa-2048", "Intermediate key type: "+keySpecHelp)
+	encryptKey := fs.Bool("encrypt-key", false, "Encrypt the intermediate private key with a passphrase")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Refuse to overwrite an existing intermediate key
+	if _, err := os.Stat(*keyOut); err == nil {
+		return fmt.Errorf("%s already exists, refusing to overwrite", *keyOut)
+	}
+
+	rootCA, err := ca.LoadFromPEMWithPassphrase(*rootCert, *rootKey, passphrase.Passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to load root CA: %w", err)
+	}
+
+	spec, err := parseKeySpecFlag(*keyType, "")
+	if err != nil {
+		return err
+	}
+	intermediate, err := rootCA.GenerateIntermediateCA(spec)
+	if err != nil {
+		return fmt.Errorf("failed to generate intermediate CA: %w", err)
+	}
+
+	if *encryptKey {
+		secret, err := passphrase.NewPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := intermediate.EncryptKey(secret); err != nil {
+			return err
+		}
+	}
+
+	if err := intermediate.SaveToPEM(*certOut, *keyOut); err != nil {
+		return fmt.Errorf("failed to save intermediate CA: %w", err)
+	}
+
+	fmt.Printf("Intermediate CA written to %s (key: %s)\n", *certOut, *keyOut)
+	fmt.Printf("Start the proxy with: gosniffer -ca-cert %s -ca-key %s\n", *certOut, *keyOut)
+	return nil
+}
+
+// runCAExport writes the root certificate in device-friendly formats:
+// PEM, DER (.cer), PKCS#12 (.p12), Android hashed name (<hash>.0) and .mobileconfig
+// Only public certificates are exported, so the private key is not read; this
+// also works for encrypted keys and keys held by a signing agent.
+func runCAExport(args []string) error {
+	fs := flag.NewFlagSet("ca export", flag.ContinueOnError)
+	certPath := fs.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to CA certificate file")
+	outDir := fs.String("out", getDefaultCAPath("export"), "Output directory for exported files")
+	password := fs.String("p12-password", "", "Password for the PKCS#12 (.p12) file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	certs, err := ca.LoadCertificates(*certPath)
+	if err != nil {
+		return err
+	}
+	rootCA := &ca.CA{Certificate: certs[0], Chain: certs[1:]}
+
+	paths, err := rootCA.ExportAll(*outDir, *password)
+	if err != nil {
+		return fmt.Errorf("failed to export CA: %w", err)
+	}
+
+	for _, path := range paths {
+		fmt.Println(path)
+	}
+	return nil
+}
+
+// runCARotate replaces the CA with a newly generated one
+// The previous CA keeps signing for the grace period so clients can install the
+// new root from the onboarding page; a running proxy picks the change up on SIGHUP.
+func runCARotate(args []string) error {
+	fs := flag.NewFlagSet("ca rotate", flag.ContinueOnError)
+	passphrase := addPassphraseFlags(fs)
+	certPath := fs.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to CA certificate file")
+	keyPath := fs.String("ca-key", getDefaultCAPath("ca-key.pem"), "Path to CA private key file")
+	keyType := fs.String("key-type", "", "New CA key type: "+keySpecHelp+" (default: same as current CA)")
+	grace := fs.Duration("grace", 7*24*time.Hour, "How long the previous CA keeps signing certificates (0 switches immediately)")
+	encryptKey := fs.Bool("encrypt-key", false, "Encrypt the new private key (implied if the current key is encrypted)")
+	force := fs.Bool("force", false, "Rotate even while the previous rotation's grace period is running, discarding the CA that is still signing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *grace < 0 {
+		return fmt.Errorf("-grace must not be negative")
+	}
+
+	// The same passphrase unlocks the current key and protects the new one
+	spec, err := parseKeySpecFlag(*keyType, "")
+	if err != nil {
+		return err
+	}
+	opts := ca.CAOptions{KeyType: spec}
+	encrypted, err := ca.KeyFileEncrypted(*keyPath)
+	if err != nil {
+		return err
+	}
+	if encrypted || *encryptKey {
+		if opts.KeyPassphrase, err = passphrase.NewPassphrase(); err != nil {
+			return err
+		}
+	}
+
+	newCA, err := ca.RotateCA(*certPath, *keyPath, opts, *grace, *force)
+	if err != nil {
+		return err
+	}
+
+	prevCert, _ := ca.PreviousPaths(*certPath, *keyPath)
+	fmt.Printf("New CA written to %s (SHA-256: %s)\n", *certPath, ca.Fingerprint(newCA.Certificate))
+	fmt.Printf("Previous CA kept as %s\n", prevCert)
+	if *grace > 0 {
+		fmt.Printf("The previous CA keeps signing until %s; install the new root on clients before then\n",
+			time.Now().Add(*grace).Format(time.RFC3339))
+	}
+	fmt.Println("Send SIGHUP to a running proxy to load the new CA")
+	return nil
+}
+
+// runCAEncryptKey rewrites an existing CA private key encrypted with a passphrase
+// An already encrypted key is re-encrypted, which changes its passphrase.
+func runCAEncryptKey(args []string) error {
+	fs := flag.NewFlagSet("ca encrypt-key", flag.ContinueOnError)
+	certPath := fs.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to CA certificate file")
+	keyPath := fs.String("ca-key", getDefaultCAPath("ca-key.pem"), "Path to CA private key file")
+	current := addPassphraseFlags(fs)
+	newEnv := fs.String("new-passphrase-env", "", "Environment variable holding the new passphrase (default: prompt)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rootCA, err := ca.LoadFromPEMWithPassphrase(*certPath, *keyPath, current.Passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to load CA: %w", err)
+	}
+
+	// Unlike the current passphrase, the new one is never taken from the default variable
+	noFD := -1
+	next := &passphraseSource{envName: newEnv, fd: &noFD}
+	secret, err := next.NewPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := rootCA.EncryptKey(secret); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(*keyPath, rootCA.KeyPEM, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Private key %s is now encrypted\n", *keyPath)
+	return nil
+}
+
+// writeFileAtomic replaces path with data via a temporary file and rename,
+// so an interrupted write never leaves a truncated key behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions on %s: %w", tmp.Name(), err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// runCAAgent serves signatures with the CA key over a Unix socket until
+// interrupted, so the proxy can run with -ca-signer-socket and never load the key
+func runCAAgent(args []string) error {
+	fs := flag.NewFlagSet("ca agent", flag.ContinueOnError)
+	passphrase := addPassphraseFlags(fs)
+	certPath := fs.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to CA certificate file")
+	keyPath := fs.String("ca-key", getDefaultCAPath("ca-key.pem"), "Path to CA private key file")
+	socketPath := fs.String("socket", getDefaultCAPath("signer.sock"), "Path of the Unix socket to listen on")
+	socketMode := fs.String("socket-mode", "0600", "Permissions of the socket file (octal); only users with write access can request signatures")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return fmt.Errorf("invalid -socket-mode %q (must be octal permissions such as 0600)", *socketMode)
+	}
+
+	rootCA, err := ca.LoadFromPEMWithPassphrase(*certPath, *keyPath, passphrase.Passphrase)
+	if err != nil {
+		return err
+	}
+
+	agent, err := signer.NewAgent(rootCA.PrivateKey)
+	if err != nil {
+		return err
+	}
+	listener, err := agent.Listen(*socketPath, os.FileMode(mode))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(*socketPath)
+
+	fmt.Printf("Signing for CA %q (%s)\n", rootCA.Certificate.Subject.CommonName, describePublicKey(rootCA.Certificate.PublicKey))
+	fmt.Printf("Start the proxy with: -ca-cert %s -ca-signer-socket %s\n", *certPath, *socketPath)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		agent.Close()
+	}()
+
+	return agent.Serve(listener)
+}
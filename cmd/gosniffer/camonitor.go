@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+const (
+	// caCheckInterval is how often the CA is checked for expiry and the end of a rotation grace period
+	caCheckInterval = time.Hour
+)
+
+// caLoader loads the active CA and, during a rotation grace period, the upcoming one
+type caLoader func() (active, upcoming *ca.CA, state *ca.RotationState, err error)
+
+// caMonitor reloads the CA on SIGHUP, switches to the new CA when a rotation
+// grace period ends and periodically logs expiry warnings
+type caMonitor struct {
+	certPath   string   // Locates the rotation state
+	load       caLoader // Reads the CA from disk or the signing agent
+	mitm       *proxy.MITMHandler
+	onboarding *proxy.OnboardingHandler // nil when the onboarding page is disabled
+	logger     *logger.Logger
+
+	rotating bool // True while a rotation grace period is running
+	hup      chan os.Signal
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newCAMonitor creates a monitor for the CA certificate at certPath
+func newCAMonitor(certPath string, load caLoader, mitm *proxy.MITMHandler, onboarding *proxy.OnboardingHandler, log *logger.Logger) *caMonitor {
+	return &caMonitor{
+		certPath:   certPath,
+		load:       load,
+		mitm:       mitm,
+		onboarding: onboarding,
+		logger:     log,
+		hup:        make(chan os.Signal, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start begins listening for SIGHUP and runs the periodic check
+func (m *caMonitor) Start() {
+	if state, err := ca.LoadRotationState(m.certPath); err == nil {
+		m.rotating = state.InGracePeriod()
+	}
+
+	signal.Notify(m.hup, syscall.SIGHUP)
+	go m.run()
+}
+
+// Stop stops the monitor goroutine
+func (m *caMonitor) Stop() {
+	signal.Stop(m.hup)
+	close(m.stop)
+	<-m.done
+}
+
+// run handles reload signals and periodic checks until Stop is called
+func (m *caMonitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(caCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.hup:
+			m.logger.LogInfo("Received SIGHUP, reloading CA")
+			m.reload()
+		case <-ticker.C:
+			m.check()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// check reloads the CA once a rotation grace period has ended and logs expiry warnings
+func (m *caMonitor) check() {
+	if m.rotating {
+		state, err := ca.LoadRotationState(m.certPath)
+		if err != nil {
+			m.logger.LogError("checking CA rotation state", err)
+		} else if !state.InGracePeriod() {
+			m.logger.LogInfo("CA rotation grace period ended, switching to new CA")
+			m.reload()
+		}
+	}
+
+	if warning := m.mitm.CA().ExpiryWarning(ca.ExpiryWarningThreshold); warning != "" {
+		m.logger.Warn(warning)
+	}
+}
+
+// reload loads the active CA and swaps it into the handlers
+// On failure the current CA stays in use
+func (m *caMonitor) reload() {
+	active, upcoming, state, err := m.load()
+	if err != nil {
+		m.logger.LogError("reloading CA (keeping current CA)", err)
+		return
+	}
+
+	m.rotating = upcoming != nil
+	m.mitm.ReloadCA(active)
+	if m.onboarding != nil {
+		m.onboarding.SetCA(active, upcoming)
+	}
+
+	if m.rotating {
+		m.logger.LogInfo(fmt.Sprintf("CA rotation in progress: previous CA signs until %s",
+			state.GraceUntil.Format(time.RFC3339)))
+	}
+}
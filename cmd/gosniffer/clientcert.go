@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// defaultClientCertPasswordEnv holds the password of PKCS#12 client certificates
+const defaultClientCertPasswordEnv = "GOSNIFFER_CLIENT_CERT_PASSWORD"
+
+// stringListFlag is a repeatable string flag
+type stringListFlag []string
+
+// stringList defines a repeatable string flag on fs
+func stringList(fs *flag.FlagSet, name, usage string) *stringListFlag {
+	f := &stringListFlag{}
+	fs.Var(f, name, usage)
+	return f
+}
+
+// String returns the values joined for flag usage output
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+// Set appends one occurrence of the flag
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// loadClientCertificates builds the upstream client certificate table from
+// -client-cert values of the form host=cert.pem[,key.pem] or host=file.p12
+// PKCS#12 files (.p12/.pfx) are unlocked with the password in passwordEnv.
+// Returns nil if specs is empty.
+func loadClientCertificates(specs []string, passwordEnv string) (*proxy.ClientCertificates, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	certs := proxy.NewClientCertificates()
+	for _, spec := range specs {
+		pattern, files, ok := strings.Cut(spec, "=")
+		if !ok || files == "" {
+			return nil, fmt.Errorf("invalid -client-cert %q (expected host=cert.pem[,key.pem] or host=file.p12)", spec)
+		}
+		certPath, keyPath, _ := strings.Cut(files, ",")
+
+		var cert *tls.Certificate
+		var err error
+		switch strings.ToLower(filepath.Ext(certPath)) {
+		case ".p12", ".pfx":
+			cert, err = proxy.LoadClientCertificatePKCS12(certPath, os.Getenv(passwordEnv))
+		default:
+			cert, err = proxy.LoadClientCertificate(certPath, keyPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := certs.Add(pattern, cert); err != nil {
+			return nil, fmt.Errorf("invalid -client-cert %q: %w", spec, err)
+		}
+	}
+	return certs, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// buildFingerprintRules parses -fingerprint-rule values of the form
+// action:field=pattern, e.g. "tunnel:ja4=t13d*_8daaf6152771_*"
+// Returns nil if there are no rules.
+func buildFingerprintRules(specs []string) (*proxy.FingerprintRules, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	rules := proxy.NewFingerprintRules()
+	for _, spec := range specs {
+		action, match, ok := strings.Cut(spec, ":")
+		field, pattern, ok2 := strings.Cut(match, "=")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid -fingerprint-rule %q (expected action:ja3=pattern or action:ja4=pattern)", spec)
+		}
+		if err := rules.Add(field, pattern, proxy.FingerprintAction(action)); err != nil {
+			return nil, fmt.Errorf("invalid -fingerprint-rule %q: %w", spec, err)
+		}
+	}
+	return rules, nil
+}
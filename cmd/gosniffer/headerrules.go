@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// buildHeaderRules returns the default X-Proxied-By rule (unless proxyHeader is
+// false) followed by the rules of the -header-rules file, if any
+func buildHeaderRules(path string, proxyHeader bool) (*proxy.HeaderRules, error) {
+	rules := proxy.NewHeaderRules()
+	if proxyHeader {
+		rules = proxy.DefaultHeaderRules()
+	}
+	if path == "" {
+		return rules, nil
+	}
+
+	fileRules, err := proxy.ReadHeaderRules(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range fileRules {
+		if err := rules.Add(rule); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+	}
+	return rules, nil
+}
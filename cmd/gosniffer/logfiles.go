@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// bytesPerMB converts -log-max-size to bytes
+const bytesPerMB = 1 << 20
+
+// logFiles owns the log and access log files and reopens them on SIGHUP, so
+// logrotate's default "move and signal" mode works alongside built-in rotation
+type logFiles struct {
+	options logger.RotateOptions
+	files   []*logger.RotatingFile
+	hup     chan os.Signal
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newLogFiles creates a set of log files rotated according to options
+func newLogFiles(options logger.RotateOptions) (*logFiles, error) {
+	if options.MaxSize < 0 || options.MaxBackups < 0 {
+		return nil, fmt.Errorf("log rotation size and backup count must not be negative")
+	}
+	return &logFiles{
+		options: options,
+		hup:     make(chan os.Signal, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// open opens a rotating log file at path
+func (l *logFiles) open(path string) (*logger.RotatingFile, error) {
+	file, err := logger.OpenRotatingFile(path, l.options)
+	if err != nil {
+		return nil, err
+	}
+	l.files = append(l.files, file)
+	return file, nil
+}
+
+// Start reopens the files whenever SIGHUP is received
+func (l *logFiles) Start(log *logger.Logger) {
+	if len(l.files) == 0 {
+		close(l.done)
+		return
+	}
+
+	signal.Notify(l.hup, syscall.SIGHUP)
+	go func() {
+		defer close(l.done)
+		for {
+			select {
+			case <-l.hup:
+				for _, file := range l.files {
+					if err := file.Reopen(); err != nil {
+						log.LogError("reopening log file", err)
+					}
+				}
+				log.LogInfo("Received SIGHUP, reopened log files")
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops listening for SIGHUP and closes the files
+func (l *logFiles) Close() {
+	signal.Stop(l.hup)
+	close(l.stop)
+	<-l.done
+	for _, file := range l.files {
+		file.Close()
+	}
+}
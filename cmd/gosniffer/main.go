@@ -3,34 +3,122 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/yourusername/go-mitmproxy/pkg/ca"
 	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/metrics"
 	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+	"github.com/yourusername/go-mitmproxy/pkg/signer"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
 )
 
 var (
 	// Command-line flags (FR-010)
-	addr            = flag.String("addr", ":8080", "Listen address for proxy server")
-	caCertPath      = flag.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to root CA certificate file")
-	caKeyPath       = flag.String("ca-key", getDefaultCAPath("ca-key.pem"), "Path to root CA private key file")
-	shutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second, "Graceful shutdown timeout")
-	enableHTTPS     = flag.Bool("enable-https", true, "Enable HTTPS MITM interception (default: true)")
-	caKeyType       = flag.String("ca-key-type", "rsa", "CA key type: 'rsa' or 'ecdsa' (default: rsa)")
+	addr                  = flag.String("addr", ":8080", "Listen address for proxy server")
+	caCertPath            = flag.String("ca-cert", getDefaultCAPath("ca-cert.pem"), "Path to root CA certificate file")
+	caKeyPath             = flag.String("ca-key", getDefaultCAPath("ca-key.pem"), "Path to root CA private key file")
+	shutdownTimeout       = flag.Duration("shutdown-timeout", 30*time.Second, "Graceful shutdown timeout")
+	enableHTTPS           = flag.Bool("enable-https", true, "Enable HTTPS MITM interception (default: true)")
+	caKeyType             = flag.String("ca-key-type", "rsa-2048", "CA key type: "+keySpecHelp)
+	leafKeyType           = flag.String("leaf-key-type", "", "Leaf certificate key type: "+keySpecHelp+" (default: same as CA)")
+	reuseLeafKey          = flag.Bool("reuse-leaf-key", false, "Reuse one long-lived private key for all generated leaf certificates")
+	keyPoolSize           = flag.Int("key-pool-size", ca.DefaultKeyPoolSize, "Number of pre-generated leaf keys to keep ready (0 disables the pool)")
+	caPermitDNS           = flag.String("ca-permit-dns", "", "Comma-separated DNS domains a newly generated CA may sign for (name constraints)")
+	caExcludeDNS          = flag.String("ca-exclude-dns", "", "Comma-separated DNS domains a newly generated CA may never sign for")
+	caPermitIP            = flag.String("ca-permit-ip", "", "Comma-separated CIDR ranges a newly generated CA may sign for")
+	caExcludeIP           = flag.String("ca-exclude-ip", "", "Comma-separated CIDR ranges a newly generated CA may never sign for")
+	outOfScope            = flag.String("out-of-scope", "tunnel", "Handling of hosts outside the CA name constraints: 'tunnel' or 'reject'")
+	onboarding            = flag.Bool("onboarding", true, "Serve CA certificate downloads at http://"+proxy.OnboardingHost+"/")
+	p12Password           = flag.String("p12-password", "", "Password for the PKCS#12 (.p12) CA certificate download")
+	encryptCAKey          = flag.Bool("encrypt-ca-key", false, "Encrypt the private key of a newly generated CA with a passphrase")
+	caPassphrase          = addPassphraseFlags(flag.CommandLine)
+	clientCertPasswordEnv = flag.String("client-cert-password-env", defaultClientCertPasswordEnv, "Environment variable holding the password of PKCS#12 client certificates")
+	requestClientCert     = flag.Bool("request-client-cert", false, "Ask clients for a TLS client certificate and record its subject (not verified)")
+	clientCertSpecs       = stringList(flag.CommandLine, "client-cert", "Client certificate for upstream mTLS as host=cert.pem[,key.pem] or host=file.p12 (repeatable; host may be *.domain or *)")
+	upstreamCABundle      = flag.String("upstream-ca-bundle", "", "PEM file of extra CA certificates trusted for upstream servers (in addition to the system roots)")
+	upstreamInsecure      = stringList(flag.CommandLine, "upstream-insecure", "Host pattern whose upstream certificate is not verified (repeatable; *.domain or *)")
+	upstreamPins          = stringList(flag.CommandLine, "upstream-pin", "SPKI pin for upstream hosts as host=sha256/BASE64 (repeatable)")
+	errorTemplate         = flag.String("error-template", "", "html/template file for error pages served when the upstream cannot be reached")
+	errorTemplateJSON     = flag.String("error-template-json", "", "text/template file for JSON error responses (clients sending Accept: application/json)")
+	fingerprintRuleSpecs  = stringList(flag.CommandLine, "fingerprint-rule", "Route clients by TLS fingerprint as action:ja3=pattern or action:ja4=pattern; action is intercept, tunnel or block (repeatable, first match wins)")
+	clientTLSSpec         = flag.String("client-tls", "", "TLS settings offered to clients as min=1.2;max=1.3;ciphers=...;curves=...;alpn=http/1.1")
+	clientTLSHosts        = stringList(flag.CommandLine, "client-tls-host", "Per-host client TLS settings as host=settings (repeatable; same syntax as -client-tls)")
+	upstreamTLSSpec       = flag.String("upstream-tls", "", "TLS settings offered to upstream servers (same syntax as -client-tls)")
+	upstreamTLSHosts      = stringList(flag.CommandLine, "upstream-tls-host", "Per-host upstream TLS settings as host=settings (repeatable)")
+	keyLogFile            = flag.String("keylog-file", "", "Append TLS secrets of both MITM legs to this file for Wireshark (default: $"+proxy.KeyLogEnv+")")
+	logLevel              = flag.String("log-level", "info", "Minimum log level: debug, info, warn or error")
+	logFormat             = flag.String("log-format", "text", "Log format: text or json (one object per line)")
+	logFile               = flag.String("log-file", "", "Write logs to this file instead of stderr")
+	logMaxSize            = flag.Int64("log-max-size", 0, "Rotate log files when they would exceed this many megabytes (0 disables)")
+	logRotateDaily        = flag.Bool("log-rotate-daily", false, "Rotate log files on the first write of each day")
+	logMaxBackups         = flag.Int("log-max-backups", 0, "Number of rotated log files to keep (0 keeps all)")
+	logCompress           = flag.Bool("log-compress", false, "Gzip rotated log files")
+	accessLogPath         = flag.String("access-log", "", "Write an access log line per proxied request to this file ('-' for stdout; default: disabled)")
+	accessLogFormat       = flag.String("access-log-format", logger.AccessFormatCombined, "Access log format: common, combined, json or a Go template such as '{{.ClientIP}} {{.Method}} {{.URL}} {{.Status}}'")
+	metricsAddr           = flag.String("metrics-addr", "", "Serve Prometheus metrics at http://ADDR/metrics, e.g. 127.0.0.1:9090 (default: disabled)")
+	headerRulesPath       = flag.String("header-rules", "", "JSON file of rules adding, setting, removing or rewriting request and response headers")
+	proxyHeader           = flag.Bool("proxy-header", true, "Set the "+proxy.ProxyHeaderName+": "+proxy.ProxyHeaderValue+" request header (the default header rule)")
+	mapLocalSpecs         = stringList(flag.CommandLine, "map-local", "Serve URLs matching a glob from a local file or directory as pattern=path, e.g. https://example.com/app/*=./dist (repeatable, first match wins)")
+	mapRemoteSpecs        = stringList(flag.CommandLine, "map-remote", "Send requests for URLs matching a glob elsewhere as pattern=target, e.g. https://api.example.com/v2/*=http://localhost:9000/v2/* (repeatable, first match wins)")
+	breakpointSpecs       = stringList(flag.CommandLine, "breakpoint", "Pause flows whose URL matches a glob for editing as phase:pattern, phase being request, response or both, e.g. both:https://api.example.com/* (repeatable; requires -breakpoint-api)")
+	breakpointAPIAddr     = flag.String("breakpoint-api", "", "Serve the API listing, editing and resuming paused flows at http://ADDR/, e.g. 127.0.0.1:9091 (default: disabled)")
+	breakpointTimeout     = flag.Duration("breakpoint-timeout", proxy.DefaultBreakpointTimeout, "How long a flow stays paused at a breakpoint before the timeout action is taken")
+	breakpointTimeoutAct  = flag.String("breakpoint-timeout-action", proxy.BreakpointResume, "What happens to flows paused for longer than -breakpoint-timeout: resume (unchanged) or drop")
+	traceEnabled          = flag.Bool("trace", false, "Generate and propagate W3C traceparent headers on proxied requests (implied by -otlp-endpoint)")
+	otlpEndpoint          = flag.String("otlp-endpoint", "", "Export spans as OTLP/HTTP JSON to this collector URL, e.g. http://localhost:4318 (default: disabled)")
+	traceServiceName      = flag.String("trace-service-name", tracing.DefaultServiceName, "service.name of exported spans")
+	caSignerSocket        = flag.String("ca-signer-socket", "", "Unix socket of a signing agent holding the CA key (see 'gosniffer ca agent'); -ca-key is not read")
 )
 
 func main() {
+	// Subcommands: "gosniffer ca ..." manages certificates without starting the proxy
+	if len(os.Args) > 1 && os.Args[1] == "ca" {
+		if err := runCACommand(os.Args[2:]); err != nil {
+			log.Fatalf("ca: %v", err)
+		}
+		return
+	}
+
 	// Parse command-line flags
 	flag.Parse()
 
-	// Create logger
-	requestLogger := logger.NewLogger()
+	// Create logger; packages without an injected logger use the default
+	level, err := logger.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("Invalid -log-level: %v", err)
+	}
+	format, err := logger.ParseFormat(*logFormat)
+	if err != nil {
+		log.Fatalf("Invalid -log-format: %v", err)
+	}
+	logFiles, err := newLogFiles(logger.RotateOptions{
+		MaxSize:    *logMaxSize * bytesPerMB,
+		Daily:      *logRotateDaily,
+		MaxBackups: *logMaxBackups,
+		Compress:   *logCompress,
+	})
+	if err != nil {
+		log.Fatalf("Invalid log rotation settings: %v", err)
+	}
+	var logOutput io.Writer = os.Stderr
+	if *logFile != "" {
+		if logOutput, err = logFiles.open(*logFile); err != nil {
+			log.Fatalf("Failed to open -log-file: %v", err)
+		}
+	}
+	rootLogger := logger.New(logOutput, level, format)
+	logger.SetDefault(rootLogger)
+	requestLogger := rootLogger.Named("proxy")
 
 	requestLogger.LogInfo(fmt.Sprintf("GoSniffer v1.0 - Forward Proxy with MITM Interception"))
 	requestLogger.LogInfo(fmt.Sprintf("Listen address: %s", *addr))
@@ -38,27 +126,247 @@ func main() {
 	// T046: Initialize root CA (generate or load)
 	var proxyServer *proxy.ProxyServer
 	var certCache *ca.CertificateCache
+	var keyPool *ca.KeyPool
+	var caMonitor *caMonitor
+	var keyLog *proxy.KeyLog
 
 	if *enableHTTPS {
-		rootCA, err := initializeCA(*caCertPath, *caKeyPath, *caKeyType, requestLogger)
+		constraints, err := parseNameConstraints(*caPermitDNS, *caExcludeDNS, *caPermitIP, *caExcludeIP)
+		if err != nil {
+			log.Fatalf("Invalid name constraints: %v", err)
+		}
+
+		caSpec, err := parseKeySpecFlag(*caKeyType, "")
 		if err != nil {
-			log.Fatalf("Failed to initialize CA: %v", err)
+			log.Fatalf("Invalid -ca-key-type: %v", err)
+		}
+
+		var rootCA, upcomingCA *ca.CA
+		var loadCA caLoader
+		if *caSignerSocket != "" {
+			// The CA key stays with the signing agent; only the certificate is read
+			loadCA, err = signerCALoader(*caCertPath, *caSignerSocket)
+			if err != nil {
+				log.Fatalf("Failed to connect to CA signing agent: %v", err)
+			}
+			rootCA, _, _, err = loadCA()
+			if err != nil {
+				log.Fatalf("Failed to load CA: %v", err)
+			}
+			requestLogger.LogInfo(fmt.Sprintf("Signing with CA key held by agent at %s", *caSignerSocket))
+		} else {
+			caOptions := ca.CAOptions{KeyType: caSpec, NameConstraints: constraints}
+			rootCA, upcomingCA, err = initializeCA(*caCertPath, *caKeyPath, caOptions, *encryptCAKey, requestLogger)
+			if err != nil {
+				log.Fatalf("Failed to initialize CA: %v", err)
+			}
+			loadCA = func() (*ca.CA, *ca.CA, *ca.RotationState, error) {
+				return ca.LoadActive(*caCertPath, *caKeyPath, caPassphrase.Passphrase)
+			}
 		}
 
 		// Create certificate cache
 		certCache = ca.NewCertificateCache()
+		certCache.SetLogger(rootLogger.Named("cache"))
 		requestLogger.LogInfo("Certificate cache initialized")
 
 		// T047: Create MITM handler and proxy server with HTTPS support
 		mitmHandler := proxy.NewMITMHandler(rootCA, certCache, requestLogger)
+
+		// Configure leaf key generation (default: same type as the CA key)
+		keyType, err := parseKeySpecFlag(*leafKeyType, rootCA.KeySpec())
+		if err != nil {
+			log.Fatalf("Invalid -leaf-key-type: %v", err)
+		}
+		if err := mitmHandler.SetLeafKeyType(keyType); err != nil {
+			log.Fatalf("Invalid -leaf-key-type: %v", err)
+		}
+
+		// Hosts outside the CA's name constraints are tunnelled or rejected
+		switch policy := proxy.OutOfScopePolicy(*outOfScope); policy {
+		case proxy.OutOfScopeTunnel, proxy.OutOfScopeReject:
+			mitmHandler.SetOutOfScopePolicy(policy)
+		default:
+			log.Fatalf("Invalid -out-of-scope value %q (must be 'tunnel' or 'reject')", *outOfScope)
+		}
+		if rootCA.HasNameConstraints() {
+			requestLogger.LogInfo(fmt.Sprintf("CA is name-constrained; out-of-scope hosts will be handled with policy %q", *outOfScope))
+		}
+
+		if *reuseLeafKey {
+			sharedKey, err := ca.GenerateKey(keyType)
+			if err != nil {
+				log.Fatalf("Failed to generate shared leaf key: %v", err)
+			}
+			mitmHandler.SetSharedLeafKey(sharedKey)
+			requestLogger.LogInfo(fmt.Sprintf("Reusing a single %s key for all leaf certificates", keyType))
+		} else if *keyPoolSize > 0 {
+			keyPool, err = ca.NewKeyPool(keyType, *keyPoolSize)
+			if err != nil {
+				log.Fatalf("Failed to create leaf key pool: %v", err)
+			}
+			keyPool.SetLogger(rootLogger.Named("keypool"))
+			mitmHandler.SetKeyPool(keyPool)
+			requestLogger.LogInfo(fmt.Sprintf("Leaf key pool started (%s, size: %d)", keyType, *keyPoolSize))
+		}
+
+		// Mutual TLS: certificates for upstream servers, optional client certificates downstream
+		clientCerts, err := loadClientCertificates(*clientCertSpecs, *clientCertPasswordEnv)
+		if err != nil {
+			log.Fatalf("Failed to load client certificates: %v", err)
+		}
+		if clientCerts != nil {
+			mitmHandler.SetClientCertificates(clientCerts)
+			requestLogger.LogInfo(fmt.Sprintf("Loaded %d upstream client certificate(s)", len(*clientCertSpecs)))
+		}
+		mitmHandler.SetRequestClientCert(*requestClientCert)
+
+		// Upstream certificate verification: extra roots, insecure hosts, pins
+		upstreamPolicy, err := buildUpstreamTLSPolicy(*upstreamCABundle, *upstreamInsecure, *upstreamPins)
+		if err != nil {
+			log.Fatalf("Invalid upstream TLS policy: %v", err)
+		}
+		mitmHandler.SetUpstreamTLSPolicy(upstreamPolicy)
+		for _, pattern := range *upstreamInsecure {
+			requestLogger.Warn("upstream certificates will not be verified", "host", pattern)
+		}
+
+		// TLS versions, ciphers, curves and ALPN on each side of the MITM
+		clientTLS, err := buildTLSProfiles("client", *clientTLSSpec, *clientTLSHosts)
+		if err != nil {
+			log.Fatalf("Invalid client TLS settings: %v", err)
+		}
+		mitmHandler.SetClientTLSProfiles(clientTLS)
+		upstreamTLS, err := buildTLSProfiles("upstream", *upstreamTLSSpec, *upstreamTLSHosts)
+		if err != nil {
+			log.Fatalf("Invalid upstream TLS settings: %v", err)
+		}
+		mitmHandler.SetUpstreamTLSProfiles(upstreamTLS)
+
+		// TLS key log for decrypting packet captures of both legs
+		keyLogPath := *keyLogFile
+		if keyLogPath == "" {
+			keyLogPath = os.Getenv(proxy.KeyLogEnv)
+		}
+		if keyLogPath != "" {
+			keyLog, err = proxy.OpenKeyLog(keyLogPath)
+			if err != nil {
+				log.Fatalf("Failed to open TLS key log: %v", err)
+			}
+			mitmHandler.SetKeyLogWriter(keyLog)
+			requestLogger.Warn("writing TLS secrets to key log; anyone with this file can decrypt captured traffic", "path", keyLogPath)
+		}
+
+		errorPages, err := loadErrorPages(*errorTemplate, *errorTemplateJSON)
+		if err != nil {
+			log.Fatalf("Invalid error page template: %v", err)
+		}
+		mitmHandler.SetErrorPages(errorPages)
+
+		fingerprintRules, err := buildFingerprintRules(*fingerprintRuleSpecs)
+		if err != nil {
+			log.Fatalf("Invalid fingerprint rule: %v", err)
+		}
+		if fingerprintRules != nil {
+			mitmHandler.SetFingerprintRules(fingerprintRules)
+			requestLogger.LogInfo(fmt.Sprintf("Loaded %d fingerprint rule(s)", len(*fingerprintRuleSpecs)))
+		}
+
 		proxyServer = proxy.NewProxyServerWithMITM(*addr, requestLogger, mitmHandler)
 		requestLogger.LogInfo("HTTPS MITM interception enabled")
+
+		var onboardingHandler *proxy.OnboardingHandler
+		if *onboarding {
+			onboardingHandler = proxy.NewOnboardingHandler(rootCA, *p12Password, requestLogger)
+			onboardingHandler.SetCA(rootCA, upcomingCA)
+			proxyServer.SetOnboardingHandler(onboardingHandler)
+			requestLogger.LogInfo(fmt.Sprintf("Certificate onboarding page at http://%s/", proxy.OnboardingHost))
+		}
+
+		// Reload the CA from disk on SIGHUP and when a rotation grace period ends
+		caMonitor = newCAMonitor(*caCertPath, loadCA, mitmHandler, onboardingHandler, requestLogger)
+		caMonitor.Start()
 	} else {
 		// Create HTTP-only proxy server
 		proxyServer = proxy.NewProxyServer(*addr, requestLogger)
 		requestLogger.LogInfo("HTTP-only mode (HTTPS MITM disabled)")
 	}
 
+	// Access log for plain HTTP and intercepted HTTPS requests
+	accessLog, err := openAccessLog(*accessLogPath, *accessLogFormat, logFiles)
+	if err != nil {
+		log.Fatalf("Invalid access log: %v", err)
+	}
+	if accessLog != nil {
+		proxyServer.SetAccessLog(accessLog)
+		requestLogger.LogInfo(fmt.Sprintf("Access log: %s (%s format)", *accessLogPath, *accessLogFormat))
+	}
+
+	// Prometheus metrics on a separate listener
+	var metricsServer *http.Server
+	if *metricsAddr != "" {
+		registry := metrics.NewRegistry()
+		proxyServer.SetMetrics(proxy.NewMetrics(registry))
+		metricsServer = startMetricsServer(*metricsAddr, registry, requestLogger)
+	}
+
+	// Header rewriting for plain HTTP and intercepted HTTPS
+	headerRules, err := buildHeaderRules(*headerRulesPath, *proxyHeader)
+	if err != nil {
+		log.Fatalf("Invalid header rules: %v", err)
+	}
+	proxyServer.SetHeaderRules(headerRules)
+	if *headerRulesPath != "" {
+		requestLogger.LogInfo(fmt.Sprintf("Header rules loaded from %s (%d active)", *headerRulesPath, headerRules.Len()))
+	}
+
+	// Local files served instead of the upstream
+	mapLocal, err := buildMapLocal(*mapLocalSpecs)
+	if err != nil {
+		log.Fatalf("Invalid map local rule: %v", err)
+	}
+	if mapLocal != nil {
+		proxyServer.SetMapLocal(mapLocal)
+		requestLogger.LogInfo(fmt.Sprintf("Map local enabled (%d rules)", mapLocal.Len()))
+	}
+
+	// Requests sent to other upstreams
+	mapRemote, err := buildMapRemote(*mapRemoteSpecs)
+	if err != nil {
+		log.Fatalf("Invalid map remote rule: %v", err)
+	}
+	if mapRemote != nil {
+		proxyServer.SetMapRemote(mapRemote)
+		requestLogger.LogInfo(fmt.Sprintf("Map remote enabled (%d rules)", mapRemote.Len()))
+	}
+
+	// Flows paused for editing through the breakpoint API
+	var breakpoints *proxy.Breakpoints
+	var breakpointServer *http.Server
+	if len(*breakpointSpecs) > 0 && *breakpointAPIAddr == "" {
+		log.Fatalf("-breakpoint requires -breakpoint-api to resume paused flows")
+	}
+	if *breakpointAPIAddr != "" {
+		breakpoints, err = buildBreakpoints(*breakpointSpecs, *breakpointTimeout, *breakpointTimeoutAct, requestLogger)
+		if err != nil {
+			log.Fatalf("Invalid breakpoint: %v", err)
+		}
+		proxyServer.SetBreakpoints(breakpoints)
+		breakpointServer = startBreakpointAPI(*breakpointAPIAddr, breakpoints, requestLogger)
+	}
+
+	// W3C trace context and span export
+	tracer, spanExporter, err := newTracer(*traceEnabled, *otlpEndpoint, *traceServiceName, rootLogger.Named("tracing"))
+	if err != nil {
+		log.Fatalf("Invalid tracing configuration: %v", err)
+	}
+	if tracer != nil {
+		proxyServer.SetTracer(tracer)
+	}
+
+	// Reopen log files on SIGHUP (the CA monitor reloads the CA on the same signal)
+	logFiles.Start(requestLogger)
+
 	// Setup signal handlers for graceful shutdown (FR-008)
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -78,6 +386,9 @@ func main() {
 	case sig := <-sigChan:
 		requestLogger.LogInfo(fmt.Sprintf("Received signal %v, shutting down gracefully...", sig))
 
+		// Paused flows would hold up the shutdown
+		breakpoints.Close()
+
 		// Graceful shutdown (FR-009)
 		if err := proxyServer.Shutdown(*shutdownTimeout); err != nil {
 			log.Fatalf("Shutdown error: %v", err)
@@ -93,10 +404,45 @@ func main() {
 	if certCache != nil {
 		certCache.Stop()
 	}
+
+	// Stop leaf key generator goroutine if it was started
+	if keyPool != nil {
+		keyPool.Stop()
+	}
+
+	// Stop CA reload monitor if it was started
+	if caMonitor != nil {
+		caMonitor.Stop()
+	}
+
+	if metricsServer != nil {
+		stopMetricsServer(metricsServer, *shutdownTimeout)
+	}
+
+	if breakpointServer != nil {
+		stopBreakpointAPI(breakpointServer, *shutdownTimeout)
+	}
+
+	// Send spans of the last requests
+	if spanExporter != nil {
+		stopExporter(spanExporter, *shutdownTimeout, requestLogger)
+	}
+
+	// Close the TLS key log after the last connection is gone
+	if keyLog != nil {
+		keyLog.Close()
+	}
+
+	// Close log files last so shutdown messages are kept
+	logFiles.Close()
 }
 
 // initializeCA loads an existing CA or generates a new one
-func initializeCA(certPath, keyPath, keyType string, logger *logger.Logger) (*ca.CA, error) {
+// Options (key type, name constraints) only apply when a new CA is generated
+// During a rotation grace period it also returns the upcoming CA (nil otherwise)
+// Encrypted keys are unlocked with the passphrase from caPassphrase; encryptKey
+// encrypts the key of a newly generated CA.
+func initializeCA(certPath, keyPath string, opts ca.CAOptions, encryptKey bool, logger *logger.Logger) (*ca.CA, *ca.CA, error) {
 	// Check if CA files exist
 	_, certErr := os.Stat(certPath)
 	_, keyErr := os.Stat(keyPath)
@@ -104,29 +450,108 @@ func initializeCA(certPath, keyPath, keyType string, logger *logger.Logger) (*ca
 	if certErr == nil && keyErr == nil {
 		// CA files exist, load them
 		logger.LogInfo(fmt.Sprintf("Loading existing CA from %s", certPath))
-		rootCA, err := ca.LoadFromPEM(certPath, keyPath)
+		rootCA, upcoming, state, err := ca.LoadActive(certPath, keyPath, caPassphrase.Passphrase)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load CA: %w", err)
+			return nil, nil, fmt.Errorf("failed to load CA: %w", err)
+		}
+		if upcoming != nil {
+			logger.LogInfo(fmt.Sprintf("CA rotation in progress: previous CA signs until %s",
+				state.GraceUntil.Format(time.RFC3339)))
+		}
+		if !opts.NameConstraints.IsEmpty() {
+			logger.LogInfo("Name constraint flags ignored: they only apply when generating a new CA")
 		}
-		return rootCA, nil
+		if rootCA.IsIntermediate() {
+			logger.LogInfo(fmt.Sprintf("Using intermediate CA %q issued by root %q",
+				rootCA.Certificate.Subject.CommonName, rootCA.Root().Subject.CommonName))
+		}
+		return rootCA, upcoming, nil
 	}
 
 	// CA files don't exist, generate new CA
-	logger.LogInfo(fmt.Sprintf("Generating new %s CA certificate...", keyType))
-	rootCA, err := ca.GenerateCA(keyType)
+	if encryptKey {
+		passphrase, err := caPassphrase.NewPassphrase()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get CA key passphrase: %w", err)
+		}
+		opts.KeyPassphrase = passphrase
+	}
+
+	logger.LogInfo(fmt.Sprintf("Generating new %s CA certificate...", opts.KeyType))
+	rootCA, err := ca.GenerateCAWithOptions(opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate CA: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate CA: %w", err)
 	}
 
 	// Save CA to disk
 	if err := rootCA.SaveToPEM(certPath, keyPath); err != nil {
-		return nil, fmt.Errorf("failed to save CA: %w", err)
+		return nil, nil, fmt.Errorf("failed to save CA: %w", err)
 	}
 
 	logger.LogInfo(fmt.Sprintf("CA certificate saved to %s", certPath))
 	logger.LogInfo("IMPORTANT: Install the root CA certificate on your client devices to avoid certificate warnings")
 
-	return rootCA, nil
+	return rootCA, nil, nil
+}
+
+// signerCALoader connects to the signing agent at socketPath and returns a loader
+// pairing the CA certificate at certPath with the agent's key
+// Rotation grace periods are not tracked: the agent holds a single key.
+func signerCALoader(certPath, socketPath string) (caLoader, error) {
+	client, err := signer.Dial(socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return func() (*ca.CA, *ca.CA, *ca.RotationState, error) {
+		active, err := ca.LoadWithSigner(certPath, client)
+		return active, nil, nil, err
+	}, nil
+}
+
+// parseNameConstraints builds CA name constraints from comma-separated flag values
+// Returns nil if no constraint is given
+func parseNameConstraints(permitDNS, excludeDNS, permitIP, excludeIP string) (*ca.NameConstraints, error) {
+	constraints := &ca.NameConstraints{
+		PermittedDNSDomains: splitList(permitDNS),
+		ExcludedDNSDomains:  splitList(excludeDNS),
+	}
+
+	var err error
+	if constraints.PermittedIPRanges, err = parseCIDRs(permitIP); err != nil {
+		return nil, err
+	}
+	if constraints.ExcludedIPRanges, err = parseCIDRs(excludeIP); err != nil {
+		return nil, err
+	}
+
+	if constraints.IsEmpty() {
+		return nil, nil
+	}
+	return constraints, nil
+}
+
+// parseCIDRs parses a comma-separated list of CIDR ranges
+func parseCIDRs(list string) ([]*net.IPNet, error) {
+	var ranges []*net.IPNet
+	for _, cidr := range splitList(list) {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // getDefaultCAPath returns the default path for CA files
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// buildMapLocal parses -map-local specs of the form pattern=path
+// Returns nil if no rules are given.
+func buildMapLocal(specs []string) (*proxy.MapLocal, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	rules := proxy.NewMapLocal()
+	for _, spec := range specs {
+		pattern, target, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid map local rule %q: expected pattern=path", spec)
+		}
+		if err := rules.Add(pattern, target); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// buildMapRemote parses -map-remote specs of the form pattern=target
+// Returns nil if no rules are given.
+func buildMapRemote(specs []string) (*proxy.MapRemote, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	rules := proxy.NewMapRemote()
+	for _, spec := range specs {
+		pattern, target, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid map remote rule %q: expected pattern=target", spec)
+		}
+		if err := rules.Add(pattern, target); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
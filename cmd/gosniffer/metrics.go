@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/metrics"
+)
+
+// metricsPath is where Prometheus scrapes the metrics
+const metricsPath = "/metrics"
+
+// startMetricsServer serves registry at http://addr/metrics in the background
+func startMetricsServer(addr string, registry *metrics.Registry, log *logger.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, registry)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.LogError("metrics server failed", err)
+		}
+	}()
+	log.LogInfo(fmt.Sprintf("Prometheus metrics at http://%s%s", addr, metricsPath))
+	return server
+}
+
+// stopMetricsServer shuts the metrics server down within timeout
+func stopMetricsServer(server *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	server.Shutdown(ctx)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// defaultPassphraseEnv is the environment variable holding the CA key passphrase
+	defaultPassphraseEnv = "GOSNIFFER_CA_PASSPHRASE"
+
+	// maxPassphraseLength bounds how much is read from a passphrase file descriptor
+	maxPassphraseLength = 4096
+)
+
+// passphraseSource resolves the CA key passphrase from, in order: an environment
+// variable, a file descriptor, or an interactive prompt on the terminal.
+// The passphrase is read at most once and reused, e.g. for SIGHUP reloads.
+type passphraseSource struct {
+	envName *string
+	fd      *int
+
+	mu    sync.Mutex
+	value []byte
+}
+
+// addPassphraseFlags registers the passphrase flags on fs
+func addPassphraseFlags(fs *flag.FlagSet) *passphraseSource {
+	return &passphraseSource{
+		envName: fs.String("ca-passphrase-env", defaultPassphraseEnv, "Environment variable holding the CA key passphrase"),
+		fd:      fs.Int("ca-passphrase-fd", -1, "Read the CA key passphrase from this file descriptor (e.g. 3 with 3<passfile)"),
+	}
+}
+
+// Passphrase returns the passphrase for unlocking an existing key
+// Its signature matches ca.PassphraseFunc.
+func (p *passphraseSource) Passphrase() ([]byte, error) {
+	return p.get(false)
+}
+
+// NewPassphrase returns the passphrase for encrypting a key, asking twice when prompting
+func (p *passphraseSource) NewPassphrase() ([]byte, error) {
+	return p.get(true)
+}
+
+// get resolves and caches the passphrase
+func (p *passphraseSource) get(confirm bool) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.value != nil {
+		return p.value, nil
+	}
+
+	value, err := p.resolve(confirm)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	p.value = value
+	return value, nil
+}
+
+// resolve reads the passphrase from the first configured source
+func (p *passphraseSource) resolve(confirm bool) ([]byte, error) {
+	if *p.envName != "" {
+		if value := os.Getenv(*p.envName); value != "" {
+			return []byte(value), nil
+		}
+	}
+
+	if *p.fd >= 0 {
+		f := os.NewFile(uintptr(*p.fd), "passphrase")
+		if f == nil {
+			return nil, fmt.Errorf("invalid passphrase file descriptor %d", *p.fd)
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxPassphraseLength))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase from file descriptor %d: %w", *p.fd, err)
+		}
+		// Only the first line counts, so "echo secret" and files with a trailing newline work
+		if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+			data = data[:i]
+		}
+		return data, nil
+	}
+
+	if !isTerminal(os.Stdin.Fd()) {
+		if *p.envName == "" {
+			return nil, fmt.Errorf("no passphrase available: not running interactively")
+		}
+		return nil, fmt.Errorf("no passphrase available: set $%s, use -ca-passphrase-fd or run interactively", *p.envName)
+	}
+
+	value, err := promptPassphrase("Enter CA key passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		again, err := promptPassphrase("Confirm CA key passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(value, again) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return value, nil
+}
+
+// promptPassphrase asks for a passphrase on stderr and reads it from stdin without echo
+func promptPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	restore, err := disableEcho(os.Stdin.Fd())
+	if err != nil {
+		return nil, fmt.Errorf("failed to disable terminal echo: %w", err)
+	}
+	defer restore()
+
+	line, err := bufio.NewReader(os.Stdin).ReadBytes('\n')
+	if err != nil && !(err == io.EOF && len(line) > 0) {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import "syscall"
+
+// Terminal attribute ioctl requests (termios(3))
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
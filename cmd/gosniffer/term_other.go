@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package main
+
+import "fmt"
+
+// isTerminal reports whether fd refers to a terminal
+// Without termios support, interactive prompting is not available.
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+// disableEcho is not supported on this platform
+func disableEcho(fd uintptr) (func(), error) {
+	return nil, fmt.Errorf("terminal echo control is not supported on this platform")
+}
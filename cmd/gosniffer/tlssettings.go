@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// buildTLSProfiles builds the TLS settings for one side of the MITM from the
+// -<side>-tls default spec and the -<side>-tls-host host=spec overrides
+func buildTLSProfiles(side, spec string, hostSpecs []string) (*proxy.TLSProfiles, error) {
+	defaults, err := proxy.ParseTLSSettings(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s-tls %q: %w", side, spec, err)
+	}
+	profiles, err := proxy.NewTLSProfiles(defaults)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s-tls %q: %w", side, spec, err)
+	}
+
+	for _, hostSpec := range hostSpecs {
+		pattern, settingsSpec, ok := strings.Cut(hostSpec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid -%s-tls-host %q (expected host=settings)", side, hostSpec)
+		}
+		settings, err := proxy.ParseTLSSettings(settingsSpec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -%s-tls-host %q: %w", side, hostSpec, err)
+		}
+		if err := profiles.Add(pattern, settings); err != nil {
+			return nil, fmt.Errorf("invalid -%s-tls-host %q: %w", side, hostSpec, err)
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
+)
+
+// newTracer creates the tracer selected by -trace and -otlp-endpoint; an endpoint
+// implies -trace. Returns nil (and no exporter) if tracing is disabled.
+func newTracer(enabled bool, endpoint, serviceName string, log *logger.Logger) (*tracing.Tracer, *tracing.Exporter, error) {
+	if endpoint == "" {
+		if !enabled {
+			return nil, nil, nil
+		}
+		log.LogInfo("Propagating W3C trace context (spans are not exported)")
+		return tracing.NewTracer(nil), nil, nil
+	}
+
+	exporter, err := tracing.NewExporter(endpoint, tracing.ExporterOptions{ServiceName: serviceName})
+	if err != nil {
+		return nil, nil, err
+	}
+	exporter.SetLogger(log)
+	log.LogInfo(fmt.Sprintf("Exporting spans to %s as %q", exporter.Endpoint(), serviceName))
+	return tracing.NewTracer(exporter), exporter, nil
+}
+
+// stopExporter sends the remaining spans within timeout
+func stopExporter(exporter *tracing.Exporter, timeout time.Duration, log *logger.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		log.LogError("span export incomplete", err)
+	}
+}
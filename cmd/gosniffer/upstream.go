@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// buildUpstreamTLSPolicy builds the upstream verification policy from the
+// -upstream-ca-bundle, -upstream-insecure and -upstream-pin flag values
+func buildUpstreamTLSPolicy(caBundle string, insecureHosts, pins []string) (*proxy.UpstreamTLSPolicy, error) {
+	policy := proxy.NewUpstreamTLSPolicy()
+
+	if caBundle != "" {
+		if err := policy.LoadCABundle(caBundle); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pattern := range insecureHosts {
+		if err := policy.AddInsecureHost(pattern); err != nil {
+			return nil, fmt.Errorf("invalid -upstream-insecure %q: %w", pattern, err)
+		}
+	}
+
+	for _, spec := range pins {
+		pattern, pin, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid -upstream-pin %q (expected host=sha256/BASE64)", spec)
+		}
+		if err := policy.AddPin(pattern, pin); err != nil {
+			return nil, fmt.Errorf("invalid -upstream-pin %q: %w", spec, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// loadErrorPages builds the error pages from the -error-template and
+// -error-template-json flag values; empty paths keep the built-in templates
+func loadErrorPages(htmlPath, jsonPath string) (*proxy.ErrorPages, error) {
+	pages := proxy.NewErrorPages()
+	if htmlPath != "" {
+		if err := pages.LoadHTMLTemplate(htmlPath); err != nil {
+			return nil, err
+		}
+	}
+	if jsonPath != "" {
+		if err := pages.LoadJSONTemplate(jsonPath); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
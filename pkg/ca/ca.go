@@ -1,8 +1,10 @@
 package ca
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
-	"crypto/elliptic"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -10,44 +12,58 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
-	"log"
 	"math/big"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/pkcs8"
 )
 
 // CA represents a Certificate Authority with its private key and certificate
+// Certificate is the signing certificate, which is either a self-signed root or
+// an intermediate. For an intermediate, Chain holds its issuers up to the root.
 type CA struct {
-	PrivateKey  interface{} // *rsa.PrivateKey or *ecdsa.PrivateKey
+	PrivateKey  crypto.Signer // In-memory key or an external signer (see LoadWithSigner)
 	Certificate *x509.Certificate
-	CertPEM     []byte
+	Chain       []*x509.Certificate // Issuers of Certificate, nearest first (empty for a root CA)
+	CertPEM     []byte              // Signing certificate followed by Chain
 	KeyPEM      []byte
+
+	logger *logger.Logger // See SetLogger (nil uses the default logger)
+}
+
+const (
+	// DefaultCAValidity is the lifetime of a generated root CA
+	DefaultCAValidity = 10 * 365 * 24 * time.Hour
+
+	// defaultCAName is the subject Common Name and Organization of a generated root CA
+	defaultCAName = "GoSniffer Root CA"
+)
+
+// CAOptions configures root CA generation
+type CAOptions struct {
+	KeyType         KeySpec          // CA key algorithm and size (see SupportedKeySpecs)
+	NameConstraints *NameConstraints // Optional; limits the names the CA may sign
+	Subject         pkix.Name        // Optional; empty CommonName uses "GoSniffer Root CA"
+	Validity        time.Duration    // Optional; zero uses DefaultCAValidity
+	KeyPassphrase   []byte           // Optional; encrypts KeyPEM as PKCS#8 (see EncryptKey)
 }
 
 // GenerateCA creates a new self-signed root CA certificate
-// Supports both RSA (2048-bit minimum) and ECDSA (P-256)
+// Supports RSA (2048, 3072 or 4096-bit), ECDSA (P-256 or P-384) and Ed25519
 // Uses crypto/rand for cryptographically secure random generation
-func GenerateCA(keyType string) (*CA, error) {
-	var privateKey interface{}
-	var err error
+func GenerateCA(keyType KeySpec) (*CA, error) {
+	return GenerateCAWithOptions(CAOptions{KeyType: keyType})
+}
 
-	// Generate private key based on type
-	switch keyType {
-	case "rsa":
-		// Generate 2048-bit RSA key (minimum per constitution SR-001)
-		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate RSA private key: %w", err)
-		}
-	case "ecdsa":
-		// Generate P-256 ECDSA key (per constitution SR-001)
-		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate ECDSA private key: %w", err)
-		}
-	default:
-		return nil, fmt.Errorf("unsupported key type: %s (must be 'rsa' or 'ecdsa')", keyType)
+// GenerateCAWithOptions creates a new self-signed root CA certificate using opts
+// Returns an error for unsupported key types or malformed name constraints
+func GenerateCAWithOptions(opts CAOptions) (*CA, error) {
+	privateKey, err := GenerateKey(opts.KeyType)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate key strength
@@ -62,30 +78,47 @@ func GenerateCA(keyType string) (*CA, error) {
 		return nil, fmt.Errorf("failed to generate serial number: %w", err)
 	}
 
+	subject := opts.Subject
+	if subject.CommonName == "" {
+		subject.CommonName = defaultCAName
+		if len(subject.Organization) == 0 {
+			subject.Organization = []string{defaultCAName}
+		}
+	}
+
+	validity := opts.Validity
+	if validity == 0 {
+		validity = DefaultCAValidity
+	}
+	if validity < 0 {
+		return nil, fmt.Errorf("CA validity must be positive, got %s", validity)
+	}
+
 	// Create CA certificate template
 	template := &x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization: []string{"GoSniffer Root CA"},
-			CommonName:   "GoSniffer Root CA",
-		},
+		SerialNumber:          serialNumber,
+		Subject:               subject,
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour), // 10 years
+		NotAfter:              time.Now().Add(validity),
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 		IsCA:                  true,
+		SignatureAlgorithm:    signatureAlgorithmFor(privateKey),
+	}
+
+	// Restrict the names this CA may sign (RFC 5280 Section 4.2.1.10)
+	if !opts.NameConstraints.IsEmpty() {
+		if err := opts.NameConstraints.validate(); err != nil {
+			return nil, err
+		}
+		opts.NameConstraints.apply(template)
 	}
 
 	// Self-sign the certificate
-	var publicKey interface{}
-	switch key := privateKey.(type) {
-	case *rsa.PrivateKey:
-		publicKey = &key.PublicKey
-	case *ecdsa.PrivateKey:
-		publicKey = &key.PublicKey
-	default:
-		return nil, fmt.Errorf("unsupported private key type")
+	publicKey, err := publicKeyOf(privateKey)
+	if err != nil {
+		return nil, err
 	}
 
 	certDER, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, privateKey)
@@ -106,21 +139,13 @@ func GenerateCA(keyType string) (*CA, error) {
 	})
 
 	var keyPEM []byte
-	switch key := privateKey.(type) {
-	case *rsa.PrivateKey:
-		keyPEM = pem.EncodeToMemory(&pem.Block{
-			Type:  "RSA PRIVATE KEY",
-			Bytes: x509.MarshalPKCS1PrivateKey(key),
-		})
-	case *ecdsa.PrivateKey:
-		keyBytes, err := x509.MarshalECPrivateKey(key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal ECDSA private key: %w", err)
-		}
-		keyPEM = pem.EncodeToMemory(&pem.Block{
-			Type:  "EC PRIVATE KEY",
-			Bytes: keyBytes,
-		})
+	if len(opts.KeyPassphrase) > 0 {
+		keyPEM, err = encodeEncryptedPrivateKeyPEM(privateKey, opts.KeyPassphrase)
+	} else {
+		keyPEM, err = encodePrivateKeyPEM(privateKey)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	ca := &CA{
@@ -132,7 +157,7 @@ func GenerateCA(keyType string) (*CA, error) {
 
 	// Log certificate generation with fingerprint (SR-004)
 	fingerprint := calculateFingerprint(certDER)
-	log.Printf("[CERT] Generated root CA certificate (fingerprint: %s)\n", fingerprint)
+	packageLogger().Info("generated root CA certificate", "fingerprint", fingerprint)
 
 	return ca, nil
 }
@@ -154,17 +179,27 @@ func (ca *CA) SaveToPEM(certPath, keyPath string) error {
 	if err := os.WriteFile(keyPath, ca.KeyPEM, 0600); err != nil {
 		return fmt.Errorf("failed to write private key to %s: %w", keyPath, err)
 	}
+	// WriteFile keeps the mode of an existing file; tighten it explicitly
+	if err := os.Chmod(keyPath, keyFileMaxPerm); err != nil {
+		return fmt.Errorf("failed to restrict permissions on %s: %w", keyPath, err)
+	}
 
-	log.Printf("[CA] Saved certificate to %s and private key to %s\n", certPath, keyPath)
+	ca.log().Info("saved CA", "cert", certPath, "key", keyPath)
 	return nil
 }
 
-// LoadFromPEM loads a CA certificate and private key from PEM files
+// LoadFromPEM loads a CA certificate and unencrypted private key from PEM files
 func LoadFromPEM(certPath, keyPath string) (*CA, error) {
-	// Read certificate file
-	certPEM, err := os.ReadFile(certPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read certificate from %s: %w", certPath, err)
+	return LoadFromPEMWithPassphrase(certPath, keyPath, nil)
+}
+
+// LoadFromPEMWithPassphrase loads a CA certificate and private key from PEM files
+// passphrase is called only if the key is encrypted; it may be nil for plain keys.
+// Key files with permissions broader than 0600 are refused.
+func LoadFromPEMWithPassphrase(certPath, keyPath string, passphrase PassphraseFunc) (*CA, error) {
+	// The key can impersonate every site clients trust; refuse world/group access
+	if err := checkKeyFilePermissions(keyPath); err != nil {
+		return nil, err
 	}
 
 	// Read private key file
@@ -173,17 +208,6 @@ func LoadFromPEM(certPath, keyPath string) (*CA, error) {
 		return nil, fmt.Errorf("failed to read private key from %s: %w", keyPath, err)
 	}
 
-	// Parse certificate
-	certBlock, _ := pem.Decode(certPEM)
-	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
-		return nil, fmt.Errorf("failed to decode PEM certificate from %s", certPath)
-	}
-
-	cert, err := x509.ParseCertificate(certBlock.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse certificate: %w", err)
-	}
-
 	// Parse private key
 	keyBlock, _ := pem.Decode(keyPEM)
 	if keyBlock == nil {
@@ -208,6 +232,16 @@ func LoadFromPEM(certPath, keyPath string) (*CA, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse PKCS#8 private key: %w", err)
 		}
+	case pkcs8.PEMType:
+		// Encrypted PKCS#8 format (RFC 5958 Section 3)
+		der, err := decryptKeyBlock(keyBlock, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load private key from %s: %w", keyPath, err)
+		}
+		privateKey, err = x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse decrypted private key (incorrect passphrase?): %w", err)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported private key type: %s", keyBlock.Type)
 	}
@@ -217,20 +251,312 @@ func LoadFromPEM(certPath, keyPath string) (*CA, error) {
 		return nil, fmt.Errorf("loaded key does not meet strength requirements: %w", err)
 	}
 
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("private key type %T cannot sign", privateKey)
+	}
+
+	ca, err := loadWithSigner(certPath, signer)
+	if err != nil {
+		return nil, err
+	}
+	ca.KeyPEM = keyPEM
+	return ca, nil
+}
+
+// LoadWithSigner loads a CA certificate chain from a PEM file and pairs it with
+// an external signer, e.g. a signing agent holding the key in another process.
+// The signer's public key must match the certificate. KeyPEM is left empty, so
+// the CA cannot be saved with SaveToPEM or have its key encrypted.
+func LoadWithSigner(certPath string, signer crypto.Signer) (*CA, error) {
+	if err := validatePublicKeyStrength(signer.Public()); err != nil {
+		return nil, fmt.Errorf("signer key does not meet strength requirements: %w", err)
+	}
+	return loadWithSigner(certPath, signer)
+}
+
+// loadWithSigner reads and validates the certificate chain and builds the CA
+func loadWithSigner(certPath string, signer crypto.Signer) (*CA, error) {
+	// Read certificate file
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate from %s: %w", certPath, err)
+	}
+
+	// Parse certificate chain: signing certificate first, then its issuers
+	certs, err := parseCertificateChain(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate chain from %s: %w", certPath, err)
+	}
+	cert := certs[0]
+
+	if err := verifyChainLinks(certs); err != nil {
+		return nil, fmt.Errorf("invalid certificate chain in %s: %w", certPath, err)
+	}
+
 	ca := &CA{
-		PrivateKey:  privateKey,
+		PrivateKey:  signer,
 		Certificate: cert,
+		Chain:       certs[1:],
 		CertPEM:     certPEM,
-		KeyPEM:      keyPEM,
+	}
+
+	// Refuse CAs that cannot produce certificates clients would accept
+	if err := ca.Validate(); err != nil {
+		return nil, fmt.Errorf("CA loaded from %s failed validation: %w", certPath, err)
+	}
+	if warning := ca.ExpiryWarning(ExpiryWarningThreshold); warning != "" {
+		packageLogger().Warn(warning)
 	}
 
 	// Log loaded certificate with fingerprint (SR-004)
-	fingerprint := calculateFingerprint(certBlock.Bytes)
-	log.Printf("[CA] Loaded root CA certificate from %s (fingerprint: %s)\n", certPath, fingerprint)
+	fingerprint := calculateFingerprint(cert.Raw)
+	if ca.IsIntermediate() {
+		packageLogger().Info("loaded intermediate CA certificate", "path", certPath,
+			"fingerprint", fingerprint, "chain_length", len(certs))
+	} else {
+		packageLogger().Info("loaded root CA certificate", "path", certPath, "fingerprint", fingerprint)
+	}
 
 	return ca, nil
 }
 
+// GenerateIntermediateCA creates a new intermediate CA signed by this root CA
+// The intermediate is limited to issuing leaf certificates (path length 0) and
+// its validity never extends beyond the root's.
+// Returns an error if this CA is itself an intermediate or key generation fails.
+func (ca *CA) GenerateIntermediateCA(keyType KeySpec) (*CA, error) {
+	if ca.IsIntermediate() {
+		return nil, fmt.Errorf("intermediate CAs can only be issued by a root CA")
+	}
+
+	privateKey, err := GenerateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := publicKeyOf(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	notAfter := time.Now().Add(5 * 365 * 24 * time.Hour) // 5 years
+	if notAfter.After(ca.Certificate.NotAfter) {
+		notAfter = ca.Certificate.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"GoSniffer Intermediate CA"},
+			CommonName:   "GoSniffer Intermediate CA",
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		// RFC 5280 Section 4.2.1.9: intermediate may only sign end-entity certificates
+		MaxPathLen:         0,
+		MaxPathLenZero:     true,
+		SignatureAlgorithm: signatureAlgorithmFor(ca.PrivateKey),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, publicKey, ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create intermediate certificate: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse intermediate certificate: %w", err)
+	}
+
+	keyPEM, err := encodePrivateKeyPEM(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Certificate file holds the full chain so the intermediate can be loaded on its own
+	certPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certDER,
+	})
+	certPEM = append(certPEM, ca.CertPEM...)
+
+	intermediate := &CA{
+		PrivateKey:  privateKey,
+		Certificate: cert,
+		Chain:       append([]*x509.Certificate{ca.Certificate}, ca.Chain...),
+		CertPEM:     certPEM,
+		KeyPEM:      keyPEM,
+	}
+
+	// Log certificate generation with fingerprint (SR-004)
+	fingerprint := calculateFingerprint(certDER)
+	ca.log().Info("generated intermediate CA certificate", "fingerprint", fingerprint)
+
+	return intermediate, nil
+}
+
+// IsIntermediate reports whether the signing certificate is an intermediate CA
+func (ca *CA) IsIntermediate() bool {
+	return len(ca.Chain) > 0
+}
+
+// Root returns the self-signed root certificate clients must trust
+func (ca *CA) Root() *x509.Certificate {
+	if len(ca.Chain) == 0 {
+		return ca.Certificate
+	}
+	return ca.Chain[len(ca.Chain)-1]
+}
+
+// IntermediatesDER returns the DER certificates to present after a leaf so that
+// clients trusting only the root can build the path (RFC 8446 Section 4.4.2).
+// The self-signed root itself is omitted; it is empty for a root CA.
+func (ca *CA) IntermediatesDER() [][]byte {
+	if !ca.IsIntermediate() {
+		return nil
+	}
+
+	chain := [][]byte{ca.Certificate.Raw}
+	for _, cert := range ca.Chain {
+		if isSelfSigned(cert) {
+			continue
+		}
+		chain = append(chain, cert.Raw)
+	}
+	return chain
+}
+
+// LoadCertificates reads a PEM certificate file without its private key
+// Returns the certificates in file order (signing certificate first for a CA chain)
+func LoadCertificates(certPath string) ([]*x509.Certificate, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate from %s: %w", certPath, err)
+	}
+
+	certs, err := parseCertificateChain(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate chain from %s: %w", certPath, err)
+	}
+	return certs, nil
+}
+
+// parseCertificateChain decodes every CERTIFICATE block in PEM data, in order
+func parseCertificateChain(certPEM []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := certPEM
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %w", len(certs)+1, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// verifyChainLinks checks that each certificate is a CA signed by the next one
+// The last certificate must be either a self-signed root or the only certificate
+func verifyChainLinks(certs []*x509.Certificate) error {
+	for i, cert := range certs {
+		if !cert.IsCA {
+			return fmt.Errorf("certificate %d (%s) is not a CA certificate", i+1, cert.Subject.CommonName)
+		}
+		if i == len(certs)-1 {
+			break
+		}
+
+		issuer := certs[i+1]
+		if err := cert.CheckSignatureFrom(issuer); err != nil {
+			return fmt.Errorf("certificate %d (%s) is not signed by certificate %d (%s): %w",
+				i+1, cert.Subject.CommonName, i+2, issuer.Subject.CommonName, err)
+		}
+	}
+	return nil
+}
+
+// isSelfSigned reports whether a certificate is a self-signed root
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// encodePrivateKeyPEM encodes a private key in its conventional PEM form
+// RSA uses PKCS#1, ECDSA uses SEC 1 and Ed25519 uses PKCS#8
+func encodePrivateKeyPEM(privateKey interface{}) ([]byte, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}), nil
+	case *ecdsa.PrivateKey:
+		keyBytes, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal ECDSA private key: %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: keyBytes,
+		}), nil
+	case ed25519.PrivateKey:
+		keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal Ed25519 private key: %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: keyBytes,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+}
+
+// KeySpec returns the spec of the CA signing key
+// Used to pick a matching leaf key type when none is configured
+func (ca *CA) KeySpec() KeySpec {
+	spec, err := KeySpecOfPublicKey(ca.PrivateKey.Public())
+	if err != nil {
+		// Loaded keys are validated, so this only happens for hand-built CAs
+		return KeyRSA2048
+	}
+	return spec
+}
+
+// KeyType returns the key algorithm family of the CA signing key ("rsa", "ecdsa" or "ed25519")
+func (ca *CA) KeyType() string {
+	return ca.KeySpec().Family()
+}
+
+// Fingerprint returns the hex SHA-256 fingerprint of a certificate
+func Fingerprint(cert *x509.Certificate) string {
+	return calculateFingerprint(cert.Raw)
+}
+
 // calculateFingerprint computes the SHA-256 fingerprint of a certificate
 func calculateFingerprint(certDER []byte) string {
 	hash := sha256.Sum256(certDER)
@@ -238,21 +564,19 @@ func calculateFingerprint(certDER []byte) string {
 }
 
 // validateKeyStrength ensures private key meets minimum strength requirements
-// Per constitution SR-001: 2048-bit RSA minimum or P-256 ECDSA
+// Per constitution SR-001: 2048-bit RSA minimum; ECDSA P-256 or P-384; Ed25519
 func validateKeyStrength(privateKey interface{}) error {
-	switch key := privateKey.(type) {
-	case *rsa.PrivateKey:
-		if key.N.BitLen() < 2048 {
-			return fmt.Errorf("RSA key size %d bits is below minimum 2048 bits", key.N.BitLen())
-		}
-		return nil
-	case *ecdsa.PrivateKey:
-		// P-256 curve has 256-bit keys
-		if key.Curve != elliptic.P256() {
-			return fmt.Errorf("ECDSA curve must be P-256, got %s", key.Curve.Params().Name)
-		}
-		return nil
-	default:
-		return fmt.Errorf("unsupported private key type: %T", privateKey)
+	if key, ok := privateKey.(ed25519.PrivateKey); ok && len(key) != ed25519.PrivateKeySize {
+		// Ed25519 has a fixed 256-bit key size (RFC 8032)
+		return fmt.Errorf("Ed25519 key has invalid length %d", len(key))
 	}
+
+	_, err := KeySpecOf(privateKey)
+	return err
+}
+
+// validatePublicKeyStrength applies the SR-001 requirements to a public key
+func validatePublicKeyStrength(publicKey crypto.PublicKey) error {
+	_, err := KeySpecOfPublicKey(publicKey)
+	return err
 }
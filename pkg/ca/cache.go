@@ -3,9 +3,11 @@ package ca
 import (
 	"container/list"
 	"context"
-	"log"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
 )
 
 const (
@@ -34,6 +36,19 @@ type CertificateCache struct {
 	ttl      time.Duration          // Certificate TTL
 	stopChan chan struct{}          // Signal to stop cleanup goroutine
 	wg       sync.WaitGroup         // Wait for cleanup goroutine
+	logger   atomic.Pointer[logger.Logger]
+
+	// Lookup and eviction counts (see Stats)
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// CacheStats counts cache lookups and evictions since the cache was created
+type CacheStats struct {
+	Hits      uint64 // Get calls that returned a certificate
+	Misses    uint64 // Get calls that found nothing or an expired certificate
+	Evictions uint64 // Certificates removed by the LRU limit or the TTL
 }
 
 // cacheEntry wraps a certificate bundle with LRU tracking
@@ -68,6 +83,7 @@ func (c *CertificateCache) Get(hostname string) *CertificateBundle {
 
 	entry, exists := c.cache[hostname]
 	if !exists {
+		c.misses.Add(1)
 		return nil
 	}
 
@@ -75,11 +91,14 @@ func (c *CertificateCache) Get(hostname string) *CertificateBundle {
 	if time.Since(entry.bundle.CreatedAt) > c.ttl {
 		// Remove expired certificate
 		c.removeLocked(hostname)
+		c.evictions.Add(1)
+		c.misses.Add(1)
 		return nil
 	}
 
 	// Update LRU: move to back (most recently used)
 	c.lruList.MoveToBack(entry.lruElement)
+	c.hits.Add(1)
 
 	return entry.bundle
 }
@@ -106,8 +125,8 @@ func (c *CertificateCache) Put(hostname string, bundle *CertificateBundle) {
 		if oldest != nil {
 			oldestHostname := oldest.Value.(string)
 			c.removeLocked(oldestHostname)
-			log.Printf("[CACHE] Evicted certificate for %s (LRU policy, cache size: %d)\n",
-				oldestHostname, c.lruList.Len())
+			c.evictions.Add(1)
+			c.log().Debug("evicted certificate (LRU)", "host", oldestHostname, "size", c.lruList.Len())
 		}
 	}
 
@@ -133,6 +152,16 @@ func (c *CertificateCache) removeLocked(hostname string) {
 	delete(c.cache, hostname)
 }
 
+// Clear removes all certificates from the cache
+// Used when the signing CA changes and cached leaves are no longer valid
+func (c *CertificateCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache = make(map[string]*cacheEntry)
+	c.lruList.Init()
+}
+
 // Size returns the current cache size
 func (c *CertificateCache) Size() int {
 	c.mu.RLock()
@@ -140,6 +169,15 @@ func (c *CertificateCache) Size() int {
 	return len(c.cache)
 }
 
+// Stats returns the cache's lookup and eviction counts
+func (c *CertificateCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
 // Stop stops the TTL cleanup goroutine and waits for it to complete
 func (c *CertificateCache) Stop() {
 	close(c.stopChan)
@@ -160,7 +198,7 @@ func (c *CertificateCache) cleanupExpired() {
 		case <-ticker.C:
 			c.performCleanup()
 		case <-c.stopChan:
-			log.Println("[CACHE] Stopping TTL cleanup goroutine")
+			c.log().Debug("stopping TTL cleanup goroutine")
 			return
 		}
 	}
@@ -187,10 +225,10 @@ func (c *CertificateCache) performCleanup() {
 		c.removeLocked(hostname)
 		expiredCount++
 	}
+	c.evictions.Add(uint64(expiredCount))
 
 	if expiredCount > 0 {
-		log.Printf("[CACHE] Cleaned up %d expired certificates (cache size: %d)\n",
-			expiredCount, len(c.cache))
+		c.log().Info("cleaned up expired certificates", "count", expiredCount, "size", len(c.cache))
 	}
 }
 
@@ -1,8 +1,9 @@
 package ca
 
 import (
+	"crypto"
 	"crypto/ecdsa"
-	"crypto/elliptic"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -10,14 +11,19 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
-	"log"
 	"math/big"
+	"net"
 	"time"
 )
 
+const (
+	// LeafValidity is the lifetime of certificates generated for intercepted hosts
+	LeafValidity = 90 * 24 * time.Hour
+)
+
 // CertificateBundle represents a generated leaf certificate with its private key
 type CertificateBundle struct {
-	PrivateKey  interface{} // *rsa.PrivateKey or *ecdsa.PrivateKey
+	PrivateKey  interface{} // *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
 	Certificate *x509.Certificate
 	TLSCert     *tls.Certificate // Ready-to-use tls.Certificate
 	Hostname    string
@@ -25,34 +31,52 @@ type CertificateBundle struct {
 }
 
 // GenerateCertificate creates a new leaf certificate for the specified hostname
-// signed by the provided CA. Supports both RSA and ECDSA key types.
+// signed by the provided CA. Supports every KeySpec (RSA, ECDSA and Ed25519).
 // Implements:
 // - T022: Leaf certificate generation
 // - T023: SAN (Subject Alternative Name) support
 // - T024: Certificate fingerprint logging
 // - T025: Key strength validation
-func (ca *CA) GenerateCertificate(hostname string, keyType string) (*CertificateBundle, error) {
+func (ca *CA) GenerateCertificate(hostname string, keyType KeySpec) (*CertificateBundle, error) {
 	// Generate private key for leaf certificate
-	var privateKey interface{}
-	var err error
-
-	switch keyType {
-	case "rsa":
-		// Generate 2048-bit RSA key (per constitution SR-001)
-		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate RSA private key for %s: %w", hostname, err)
-		}
-	case "ecdsa":
-		// Generate P-256 ECDSA key (per constitution SR-001)
-		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate ECDSA private key for %s: %w", hostname, err)
-		}
-	default:
-		return nil, fmt.Errorf("unsupported key type: %s (must be 'rsa' or 'ecdsa')", keyType)
+	privateKey, err := GenerateKey(keyType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key for %s: %w", hostname, err)
+	}
+
+	return ca.GenerateCertificateWithKey(hostname, privateKey)
+}
+
+// GenerateCertificateWithKey creates a new leaf certificate for the specified
+// hostname using an existing private key. Only the signing operation is performed,
+// which allows callers to reuse a long-lived key or draw keys from a KeyPool.
+func (ca *CA) GenerateCertificateWithKey(hostname string, privateKey interface{}) (*CertificateBundle, error) {
+	return ca.issueLeaf([]string{hostname}, privateKey, LeafValidity)
+}
+
+// IssueCertificate creates a leaf certificate covering all hosts (DNS names or IP
+// literals) with the given validity, e.g. for local development servers.
+// The first host becomes the Common Name and the bundle's Hostname.
+func (ca *CA) IssueCertificate(hosts []string, keyType KeySpec, validity time.Duration) (*CertificateBundle, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("at least one host is required")
+	}
+	if validity <= 0 {
+		return nil, fmt.Errorf("validity must be positive, got %s", validity)
 	}
 
+	privateKey, err := GenerateKey(keyType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key for %s: %w", hosts[0], err)
+	}
+
+	return ca.issueLeaf(hosts, privateKey, validity)
+}
+
+// issueLeaf signs a leaf certificate for hosts with privateKey
+func (ca *CA) issueLeaf(hosts []string, privateKey interface{}, validity time.Duration) (*CertificateBundle, error) {
+	hostname := hosts[0]
+
 	// T025: Validate leaf certificate key strength
 	if err := validateKeyStrength(privateKey); err != nil {
 		return nil, fmt.Errorf("leaf certificate key strength validation failed for %s: %w", hostname, err)
@@ -65,6 +89,12 @@ func (ca *CA) GenerateCertificate(hostname string, keyType string) (*Certificate
 		return nil, fmt.Errorf("failed to generate serial number for %s: %w", hostname, err)
 	}
 
+	// A leaf must not outlive its issuers, or clients reject it once the CA expires
+	notAfter := time.Now().Add(validity)
+	if caNotAfter := ca.NotAfter(); notAfter.After(caNotAfter) {
+		notAfter = caNotAfter
+	}
+
 	// Create leaf certificate template
 	template := &x509.Certificate{
 		SerialNumber: serialNumber,
@@ -73,26 +103,34 @@ func (ca *CA) GenerateCertificate(hostname string, keyType string) (*Certificate
 			CommonName:   hostname,
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(90 * 24 * time.Hour), // 90 days validity
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 		IsCA:                  false,
+		SignatureAlgorithm:    signatureAlgorithmFor(ca.PrivateKey),
+	}
+
+	// T023: SAN (Subject Alternative Name) support for hostname validation
+	// Required for modern browsers - Common Name alone is deprecated
+	// IP literals go in the iPAddress SAN so clients and name constraints match them (RFC 5280 Section 4.2.1.6)
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
 
-		// T023: SAN (Subject Alternative Name) support for hostname validation
-		// Required for modern browsers - Common Name alone is deprecated
-		DNSNames: []string{hostname},
+	// Key encipherment only applies to RSA key exchange (RFC 5280 Section 4.2.1.3)
+	if _, ok := privateKey.(*rsa.PrivateKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
 	}
 
 	// Extract public key from private key
-	var publicKey interface{}
-	switch key := privateKey.(type) {
-	case *rsa.PrivateKey:
-		publicKey = &key.PublicKey
-	case *ecdsa.PrivateKey:
-		publicKey = &key.PublicKey
-	default:
-		return nil, fmt.Errorf("unsupported private key type for %s", hostname)
+	publicKey, err := publicKeyOf(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported private key type for %s: %w", hostname, err)
 	}
 
 	// Sign the certificate with the CA
@@ -107,48 +145,60 @@ func (ca *CA) GenerateCertificate(hostname string, keyType string) (*Certificate
 		return nil, fmt.Errorf("failed to parse certificate for %s: %w", hostname, err)
 	}
 
-	// Encode certificate to PEM
-	certPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certDER,
-	})
-
-	// Encode private key to PEM
-	var keyPEM []byte
-	switch key := privateKey.(type) {
-	case *rsa.PrivateKey:
-		keyPEM = pem.EncodeToMemory(&pem.Block{
-			Type:  "RSA PRIVATE KEY",
-			Bytes: x509.MarshalPKCS1PrivateKey(key),
-		})
-	case *ecdsa.PrivateKey:
-		keyBytes, err := x509.MarshalECPrivateKey(key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal ECDSA private key for %s: %w", hostname, err)
-		}
-		keyPEM = pem.EncodeToMemory(&pem.Block{
-			Type:  "EC PRIVATE KEY",
-			Bytes: keyBytes,
-		})
-	}
-
 	// Create tls.Certificate for immediate use
-	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create TLS certificate for %s: %w", hostname, err)
+	// Built directly from the DER and key to avoid a PEM encode/decode round trip
+	// Intermediates follow the leaf so clients trusting only the root can build the path
+	tlsCert := &tls.Certificate{
+		Certificate: append([][]byte{certDER}, ca.IntermediatesDER()...),
+		PrivateKey:  privateKey,
+		Leaf:        cert,
 	}
 
 	bundle := &CertificateBundle{
 		PrivateKey:  privateKey,
 		Certificate: cert,
-		TLSCert:     &tlsCert,
+		TLSCert:     tlsCert,
 		Hostname:    hostname,
 		CreatedAt:   time.Now(),
 	}
 
 	// T024: Log certificate generation with fingerprint (SR-004)
 	fingerprint := calculateFingerprint(certDER)
-	log.Printf("[CERT] Generated leaf certificate for %s (fingerprint: %s)\n", hostname, fingerprint)
+	ca.log().Info("generated leaf certificate", "host", hostname, "fingerprint", fingerprint)
 
 	return bundle, nil
 }
+
+// EncodePEM returns the certificate chain (leaf first, then intermediates) and
+// private key in PEM form, ready to be used by a TLS server
+func (b *CertificateBundle) EncodePEM() (certPEM, keyPEM []byte, err error) {
+	for _, der := range b.TLSCert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	keyPEM, err = encodePrivateKeyPEM(b.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certPEM, keyPEM, nil
+}
+
+// GenerateKey generates a new private key for the given spec
+// Supported specs are listed in SupportedKeySpecs; "rsa" and "ecdsa" are accepted as aliases.
+func GenerateKey(keyType KeySpec) (crypto.Signer, error) {
+	return keyType.Generate()
+}
+
+// publicKeyOf returns the public half of a supported private key
+func publicKeyOf(privateKey interface{}) (interface{}, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey, nil
+	case ed25519.PrivateKey:
+		return key.Public(), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+}
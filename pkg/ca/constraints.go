@@ -0,0 +1,130 @@
+package ca
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NameConstraints restricts the names a CA may issue certificates for
+// (X.509 Name Constraints extension, RFC 5280 Section 4.2.1.10).
+// DNS entries match the domain and all subdomains; a leading "." matches
+// subdomains only. An empty permitted list leaves that name type unconstrained.
+type NameConstraints struct {
+	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+	PermittedIPRanges   []*net.IPNet
+	ExcludedIPRanges    []*net.IPNet
+}
+
+// IsEmpty reports whether no constraint is set
+func (nc *NameConstraints) IsEmpty() bool {
+	return nc == nil || (len(nc.PermittedDNSDomains) == 0 && len(nc.ExcludedDNSDomains) == 0 &&
+		len(nc.PermittedIPRanges) == 0 && len(nc.ExcludedIPRanges) == 0)
+}
+
+// validate checks DNS constraints for obviously malformed entries
+func (nc *NameConstraints) validate() error {
+	for _, domain := range append(append([]string{}, nc.PermittedDNSDomains...), nc.ExcludedDNSDomains...) {
+		trimmed := strings.TrimPrefix(domain, ".")
+		if trimmed == "" || strings.ContainsAny(trimmed, " /:*") {
+			return fmt.Errorf("invalid DNS name constraint %q", domain)
+		}
+	}
+	return nil
+}
+
+// apply copies the constraints onto a CA certificate template
+// The extension is marked critical as required by RFC 5280 Section 4.2.1.10
+func (nc *NameConstraints) apply(template *x509.Certificate) {
+	template.PermittedDNSDomainsCritical = true
+	template.PermittedDNSDomains = nc.PermittedDNSDomains
+	template.ExcludedDNSDomains = nc.ExcludedDNSDomains
+	template.PermittedIPRanges = nc.PermittedIPRanges
+	template.ExcludedIPRanges = nc.ExcludedIPRanges
+}
+
+// nameConstraintsOf returns the name constraints carried by cert, or nil if it has none
+func nameConstraintsOf(cert *x509.Certificate) *NameConstraints {
+	nc := &NameConstraints{
+		PermittedDNSDomains: cert.PermittedDNSDomains,
+		ExcludedDNSDomains:  cert.ExcludedDNSDomains,
+		PermittedIPRanges:   cert.PermittedIPRanges,
+		ExcludedIPRanges:    cert.ExcludedIPRanges,
+	}
+	if nc.IsEmpty() {
+		return nil
+	}
+	return nc
+}
+
+// Permits reports whether the CA (and every issuer in its chain) is allowed to
+// issue a certificate for host. Hosts outside the permitted set would produce
+// certificates that clients reject, so callers should not intercept them.
+func (ca *CA) Permits(host string) bool {
+	for _, cert := range append([]*x509.Certificate{ca.Certificate}, ca.Chain...) {
+		if !certificatePermits(cert, host) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasNameConstraints reports whether any certificate in the chain carries name constraints
+func (ca *CA) HasNameConstraints() bool {
+	for _, cert := range append([]*x509.Certificate{ca.Certificate}, ca.Chain...) {
+		if len(cert.PermittedDNSDomains) > 0 || len(cert.ExcludedDNSDomains) > 0 ||
+			len(cert.PermittedIPRanges) > 0 || len(cert.ExcludedIPRanges) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// certificatePermits evaluates a single certificate's name constraints for host
+func certificatePermits(cert *x509.Certificate, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		for _, excluded := range cert.ExcludedIPRanges {
+			if excluded.Contains(ip) {
+				return false
+			}
+		}
+		if len(cert.PermittedIPRanges) == 0 {
+			return true
+		}
+		for _, permitted := range cert.PermittedIPRanges {
+			if permitted.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, excluded := range cert.ExcludedDNSDomains {
+		if matchDNSConstraint(host, excluded) {
+			return false
+		}
+	}
+	if len(cert.PermittedDNSDomains) == 0 {
+		return true
+	}
+	for _, permitted := range cert.PermittedDNSDomains {
+		if matchDNSConstraint(host, permitted) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDNSConstraint matches host against a DNS name constraint
+// "example.com" matches example.com and its subdomains; ".example.com" only subdomains
+func matchDNSConstraint(host, constraint string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	constraint = strings.ToLower(constraint)
+
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+	return host == constraint || strings.HasSuffix(host, "."+constraint)
+}
@@ -0,0 +1,181 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/yourusername/go-mitmproxy/pkg/pkcs12"
+)
+
+// Export file names written by ExportAll (relative to the export directory)
+const (
+	ExportPEMFile          = "gosniffer-ca-cert.pem"
+	ExportDERFile          = "gosniffer-ca-cert.cer"
+	ExportPKCS12File       = "gosniffer-ca-cert.p12"
+	ExportMobileConfigFile = "gosniffer-ca-cert.mobileconfig"
+)
+
+// ExportPEM returns the root certificate in PEM form
+// Only the root is exported: it is the single certificate clients must trust
+func (ca *CA) ExportPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ca.Root().Raw,
+	})
+}
+
+// ExportDER returns the root certificate in DER form (.cer), as expected by
+// Windows and Android certificate installers
+func (ca *CA) ExportDER() []byte {
+	return ca.Root().Raw
+}
+
+// ExportPKCS12 returns the root certificate in a PKCS#12 (.p12) container
+// protected with password. The container holds no private key.
+func (ca *CA) ExportPKCS12(password string) ([]byte, error) {
+	root := ca.Root()
+	data, err := pkcs12.EncodeCertificates([]*x509.Certificate{root}, password, root.Subject.CommonName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PKCS#12: %w", err)
+	}
+	return data, nil
+}
+
+// AndroidFilename returns the file name Android uses for system trust store
+// entries: the OpenSSL "old" subject hash (MD5 of the DER subject, first four
+// bytes little-endian) followed by ".0", e.g. "c8750f0d.0"
+func (ca *CA) AndroidFilename() string {
+	sum := md5.Sum(ca.Root().RawSubject)
+	return fmt.Sprintf("%08x.0", binary.LittleEndian.Uint32(sum[:4]))
+}
+
+// mobileConfigTemplate is an Apple configuration profile installing one root
+// certificate (Apple Configuration Profile Reference, com.apple.security.root)
+var mobileConfigTemplate = template.Must(template.New("mobileconfig").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadCertificateFileName</key>
+			<string>{{.FileName}}</string>
+			<key>PayloadContent</key>
+			<data>{{.Certificate}}</data>
+			<key>PayloadDescription</key>
+			<string>Adds a CA root certificate</string>
+			<key>PayloadDisplayName</key>
+			<string>{{.Name}}</string>
+			<key>PayloadIdentifier</key>
+			<string>com.gosniffer.ca.{{.CertUUID}}</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>{{.CertUUID}}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>PayloadDisplayName</key>
+	<string>{{.Name}}</string>
+	<key>PayloadIdentifier</key>
+	<string>com.gosniffer.ca</string>
+	<key>PayloadRemovalDisallowed</key>
+	<false/>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>{{.ProfileUUID}}</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`))
+
+// ExportMobileConfig returns an Apple .mobileconfig profile that installs the root
+// certificate on iOS, iPadOS and macOS. Payload UUIDs are derived from the
+// certificate fingerprint so re-exporting the same CA yields the same profile.
+func (ca *CA) ExportMobileConfig() ([]byte, error) {
+	root := ca.Root()
+	fingerprint := sha256.Sum256(root.Raw)
+
+	var buf bytes.Buffer
+	err := mobileConfigTemplate.Execute(&buf, map[string]string{
+		"FileName":    ExportDERFile,
+		"Certificate": base64.StdEncoding.EncodeToString(root.Raw),
+		"Name":        xmlEscape(root.Subject.CommonName),
+		"CertUUID":    formatUUID(fingerprint[:16]),
+		"ProfileUUID": formatUUID(fingerprint[16:]),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render mobileconfig: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportAll writes the root certificate to dir in every supported format:
+// PEM, DER (.cer), PKCS#12 (.p12), Android hashed name (<hash>.0) and .mobileconfig
+// Returns the paths of the written files.
+func (ca *CA) ExportAll(dir, p12Password string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	p12, err := ca.ExportPKCS12(p12Password)
+	if err != nil {
+		return nil, err
+	}
+	mobileConfig, err := ca.ExportMobileConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{ExportPEMFile, ca.ExportPEM()},
+		{ExportDERFile, ca.ExportDER()},
+		{ExportPKCS12File, p12},
+		{ca.AndroidFilename(), ca.ExportPEM()},
+		{ExportMobileConfigFile, mobileConfig},
+	}
+
+	var paths []string
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		// Certificates are public, readable by all
+		if err := os.WriteFile(path, f.data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// formatUUID formats 16 bytes as an RFC 9562 version 8 (custom) UUID string
+func formatUUID(b []byte) string {
+	u := make([]byte, 16)
+	copy(u, b)
+	u[6] = (u[6] & 0x0f) | 0x80 // Version 8
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 9562 variant
+	return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// xmlEscape escapes text for inclusion in the plist (text/template does not)
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
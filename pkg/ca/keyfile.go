@@ -0,0 +1,104 @@
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/yourusername/go-mitmproxy/pkg/pkcs8"
+)
+
+const (
+	// keyFileMaxPerm is the broadest permission allowed on a CA private key file
+	keyFileMaxPerm os.FileMode = 0600
+)
+
+// ErrPassphraseRequired is returned when loading an encrypted key without a passphrase
+var ErrPassphraseRequired = errors.New("private key is encrypted; a passphrase is required")
+
+// PassphraseFunc returns the passphrase protecting an encrypted CA private key
+// It is only called when the key file is actually encrypted.
+type PassphraseFunc func() ([]byte, error)
+
+// EncryptKey replaces KeyPEM with the private key encrypted under passphrase
+// as a PKCS#8 EncryptedPrivateKeyInfo; SaveToPEM then writes the encrypted form
+// External signers have no exportable key and return an error.
+func (ca *CA) EncryptKey(passphrase []byte) error {
+	keyPEM, err := encodeEncryptedPrivateKeyPEM(ca.PrivateKey, passphrase)
+	if err != nil {
+		return err
+	}
+	ca.KeyPEM = keyPEM
+	return nil
+}
+
+// IsKeyEncrypted reports whether KeyPEM holds an encrypted private key
+func (ca *CA) IsKeyEncrypted() bool {
+	block, _ := pem.Decode(ca.KeyPEM)
+	return block != nil && block.Type == pkcs8.PEMType
+}
+
+// KeyFileEncrypted reports whether the PEM private key at keyPath is encrypted
+func KeyFileEncrypted(keyPath string) (bool, error) {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read private key from %s: %w", keyPath, err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	return block != nil && block.Type == pkcs8.PEMType, nil
+}
+
+// encodeEncryptedPrivateKeyPEM encodes a private key as an encrypted PKCS#8 PEM block
+func encodeEncryptedPrivateKeyPEM(privateKey interface{}, passphrase []byte) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	encrypted, err := pkcs8.EncryptPrivateKey(der, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pkcs8.PEMType, Bytes: encrypted}), nil
+}
+
+// decryptKeyBlock decrypts an encrypted PKCS#8 PEM block into its PrivateKeyInfo DER
+func decryptKeyBlock(block *pem.Block, passphrase PassphraseFunc) ([]byte, error) {
+	if passphrase == nil {
+		return nil, ErrPassphraseRequired
+	}
+	secret, err := passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if len(secret) == 0 {
+		return nil, ErrPassphraseRequired
+	}
+
+	der, err := pkcs8.DecryptPrivateKey(block.Bytes, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	return der, nil
+}
+
+// checkKeyFilePermissions refuses key files readable or writable by group or others
+// Windows has no Unix permission bits, so the check is skipped there.
+func checkKeyFilePermissions(keyPath string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat private key %s: %w", keyPath, err)
+	}
+	if perm := info.Mode().Perm(); perm&^keyFileMaxPerm != 0 {
+		return fmt.Errorf("private key %s has permissions %#o, must not be broader than %#o (run: chmod 600 %s)",
+			keyPath, perm, keyFileMaxPerm, keyPath)
+	}
+	return nil
+}
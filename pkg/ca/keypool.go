@@ -0,0 +1,120 @@
+package ca
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+const (
+	// DefaultKeyPoolSize is the number of pre-generated leaf keys kept ready
+	// Sized to absorb a burst of first connections to new hosts (e.g. a page load)
+	DefaultKeyPoolSize = 16
+
+	// keyPoolRetryMin and keyPoolRetryMax bound the back-off after a failed key generation
+	keyPoolRetryMin = 100 * time.Millisecond
+	keyPoolRetryMax = 30 * time.Second
+)
+
+// KeyPool pre-generates leaf private keys in a background goroutine so that
+// certificate issuance on the connection path is only a signing operation.
+// Keys are handed out over a buffered channel; when the pool is drained,
+// Get falls back to generating a key synchronously.
+type KeyPool struct {
+	keyType  KeySpec
+	keys     chan interface{} // Buffered channel of ready-to-use keys
+	stopChan chan struct{}    // Signal to stop the generator goroutine
+	stopOnce sync.Once
+	wg       sync.WaitGroup // Wait for generator goroutine
+	logger   atomic.Pointer[logger.Logger]
+}
+
+// NewKeyPool creates a pool holding up to size pre-generated keys of keyType
+// and starts the background generator goroutine.
+// Returns an error if keyType is unsupported or size is not positive.
+func NewKeyPool(keyType KeySpec, size int) (*KeyPool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("key pool size must be positive, got %d", size)
+	}
+
+	// Generate one key up front to validate the key type before starting
+	key, err := GenerateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &KeyPool{
+		keyType:  keyType,
+		keys:     make(chan interface{}, size),
+		stopChan: make(chan struct{}),
+	}
+	pool.keys <- key
+
+	// Constitution Principle I: dedicated, supervised goroutine
+	pool.wg.Add(1)
+	go pool.fill()
+
+	return pool, nil
+}
+
+// KeyType returns the type of keys produced by the pool
+func (p *KeyPool) KeyType() KeySpec {
+	return p.keyType
+}
+
+// Get returns a pre-generated key, or generates one synchronously if the pool is empty
+func (p *KeyPool) Get() (interface{}, error) {
+	select {
+	case key := <-p.keys:
+		return key, nil
+	default:
+		return GenerateKey(p.keyType)
+	}
+}
+
+// Size returns the number of keys currently ready in the pool
+func (p *KeyPool) Size() int {
+	return len(p.keys)
+}
+
+// Stop stops the generator goroutine and waits for it to complete
+func (p *KeyPool) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+	p.wg.Wait()
+}
+
+// fill keeps the key channel topped up until Stop is called
+// Blocks on the channel send while the pool is full, so it costs nothing when idle
+func (p *KeyPool) fill() {
+	defer p.wg.Done()
+
+	// Failures are retried with exponential back-off so the pool recovers from
+	// transient errors (e.g. an exhausted entropy source) instead of staying empty
+	retry := keyPoolRetryMin
+	for {
+		key, err := GenerateKey(p.keyType)
+		if err != nil {
+			p.log().Error("failed to pre-generate key, retrying", "key_type", p.keyType, "error", err, "retry_in", retry)
+			select {
+			case <-time.After(retry):
+			case <-p.stopChan:
+				return
+			}
+			retry = min(retry*2, keyPoolRetryMax)
+			continue
+		}
+		retry = keyPoolRetryMin
+
+		select {
+		case p.keys <- key:
+		case <-p.stopChan:
+			p.log().Debug("stopping key generator goroutine")
+			return
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// KeySpec names a key algorithm and size for CA and leaf keys
+type KeySpec string
+
+const (
+	KeyRSA2048   KeySpec = "rsa-2048"
+	KeyRSA3072   KeySpec = "rsa-3072"
+	KeyRSA4096   KeySpec = "rsa-4096"
+	KeyECDSAP256 KeySpec = "ecdsa-p256"
+	KeyECDSAP384 KeySpec = "ecdsa-p384"
+	KeyEd25519   KeySpec = "ed25519"
+
+	// Short aliases kept for existing configurations
+	KeyRSA   KeySpec = "rsa"   // Same as KeyRSA2048
+	KeyECDSA KeySpec = "ecdsa" // Same as KeyECDSAP256
+
+	// minRSABits is the smallest accepted RSA modulus (constitution SR-001)
+	minRSABits = 2048
+)
+
+// SupportedKeySpecs lists the canonical key specs in order of preference for flag help
+var SupportedKeySpecs = []KeySpec{KeyRSA2048, KeyRSA3072, KeyRSA4096, KeyECDSAP256, KeyECDSAP384, KeyEd25519}
+
+// ParseKeySpec parses a key spec name, case-insensitively, resolving aliases
+// Returns an error listing the supported specs if the name is unknown.
+func ParseKeySpec(name string) (KeySpec, error) {
+	spec := KeySpec(strings.ToLower(strings.TrimSpace(name)))
+	switch spec {
+	case KeyRSA:
+		return KeyRSA2048, nil
+	case KeyECDSA:
+		return KeyECDSAP256, nil
+	}
+	for _, supported := range SupportedKeySpecs {
+		if spec == supported {
+			return spec, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported key type: %q (must be one of %s)", name, keySpecList())
+}
+
+// keySpecList returns the supported specs as a comma-separated list
+func keySpecList() string {
+	names := make([]string, len(SupportedKeySpecs))
+	for i, spec := range SupportedKeySpecs {
+		names[i] = string(spec)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Generate creates a new private key for the spec
+func (s KeySpec) Generate() (crypto.Signer, error) {
+	spec, err := ParseKeySpec(string(s))
+	if err != nil {
+		return nil, err
+	}
+
+	switch spec {
+	case KeyRSA2048, KeyRSA3072, KeyRSA4096:
+		key, err := rsa.GenerateKey(rand.Reader, spec.rsaBits())
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA private key: %w", err)
+		}
+		return key, nil
+	case KeyECDSAP256, KeyECDSAP384:
+		curve := elliptic.P256()
+		if spec == KeyECDSAP384 {
+			curve = elliptic.P384()
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ECDSA private key: %w", err)
+		}
+		return key, nil
+	default:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 private key: %w", err)
+		}
+		return key, nil
+	}
+}
+
+// rsaBits returns the RSA modulus size for an RSA spec
+func (s KeySpec) rsaBits() int {
+	switch s {
+	case KeyRSA3072:
+		return 3072
+	case KeyRSA4096:
+		return 4096
+	default:
+		return 2048
+	}
+}
+
+// KeySpecOf returns the spec describing an existing private key
+// RSA keys of non-standard sizes of at least 2048 bits map to the nearest smaller spec.
+func KeySpecOf(privateKey interface{}) (KeySpec, error) {
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return "", fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+	return KeySpecOfPublicKey(signer.Public())
+}
+
+// KeySpecOfPublicKey returns the spec describing a public key, which also covers
+// external signers whose private key is not available
+func KeySpecOfPublicKey(publicKey crypto.PublicKey) (KeySpec, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		switch bits := key.N.BitLen(); {
+		case bits >= 4096:
+			return KeyRSA4096, nil
+		case bits >= 3072:
+			return KeyRSA3072, nil
+		case bits >= minRSABits:
+			return KeyRSA2048, nil
+		default:
+			return "", fmt.Errorf("RSA key size %d bits is below minimum %d bits", bits, minRSABits)
+		}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return KeyECDSAP256, nil
+		case elliptic.P384():
+			return KeyECDSAP384, nil
+		default:
+			return "", fmt.Errorf("unsupported ECDSA curve %s (must be P-256 or P-384)", key.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		return KeyEd25519, nil
+	default:
+		return "", fmt.Errorf("unsupported public key type: %T", publicKey)
+	}
+}
+
+// Family returns the algorithm family of the spec: "rsa", "ecdsa" or "ed25519"
+func (s KeySpec) Family() string {
+	family, _, _ := strings.Cut(string(s), "-")
+	return family
+}
+
+// signatureAlgorithm picks the signature algorithm for certificates signed by
+// a key of this spec, keeping the hash strength in line with the key strength
+// (NIST SP 800-57 Part 1 Table 3; RFC 5758 Section 3.2 for ECDSA; RFC 8410 for Ed25519)
+func (s KeySpec) signatureAlgorithm() x509.SignatureAlgorithm {
+	switch s {
+	case KeyRSA3072, KeyRSA4096:
+		return x509.SHA384WithRSA
+	case KeyECDSAP256:
+		return x509.ECDSAWithSHA256
+	case KeyECDSAP384:
+		return x509.ECDSAWithSHA384
+	case KeyEd25519:
+		return x509.PureEd25519
+	default:
+		return x509.SHA256WithRSA
+	}
+}
+
+// signatureAlgorithmFor returns the signature algorithm for a signing key
+func signatureAlgorithmFor(signer crypto.Signer) x509.SignatureAlgorithm {
+	spec, err := KeySpecOfPublicKey(signer.Public())
+	if err != nil {
+		// Let x509.CreateCertificate choose; it reports unusable keys itself
+		return x509.UnknownSignatureAlgorithm
+	}
+	return spec.signatureAlgorithm()
+}
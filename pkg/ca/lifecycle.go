@@ -0,0 +1,253 @@
+package ca
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// ExpiryWarningThreshold is how long before NotAfter expiry warnings start
+	ExpiryWarningThreshold = 30 * 24 * time.Hour
+
+	// rotationStateFile records an in-progress rotation next to the CA certificate
+	rotationStateFile = "ca-rotation.json"
+)
+
+// Validate checks that the CA can be used for signing:
+// - the private key matches the signing certificate
+// - every certificate in the chain is a CA with KeyUsageCertSign
+// - every certificate is within its validity period
+// Returns an error describing the first problem found.
+func (ca *CA) Validate() error {
+	if ca.PrivateKey == nil {
+		return fmt.Errorf("CA has no signing key")
+	}
+
+	publicKey, ok := ca.PrivateKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(ca.Certificate.PublicKey) {
+		return fmt.Errorf("private key does not match certificate %q", ca.Certificate.Subject.CommonName)
+	}
+
+	now := time.Now()
+	for _, cert := range append([]*x509.Certificate{ca.Certificate}, ca.Chain...) {
+		name := cert.Subject.CommonName
+		if !cert.BasicConstraintsValid || !cert.IsCA {
+			return fmt.Errorf("certificate %q is not a CA (basic constraints CA flag not set)", name)
+		}
+		if cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+			return fmt.Errorf("certificate %q does not allow certificate signing (KeyUsageCertSign)", name)
+		}
+		if now.Before(cert.NotBefore) {
+			return fmt.Errorf("certificate %q is not valid until %s", name, cert.NotBefore.Format(time.RFC3339))
+		}
+		if now.After(cert.NotAfter) {
+			return fmt.Errorf("certificate %q expired on %s", name, cert.NotAfter.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// NotAfter returns the earliest expiry across the signing certificate and its chain
+func (ca *CA) NotAfter() time.Time {
+	notAfter := ca.Certificate.NotAfter
+	for _, cert := range ca.Chain {
+		if cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+	return notAfter
+}
+
+// ExpiryWarning returns a warning message if the CA expires within threshold,
+// or an empty string otherwise
+func (ca *CA) ExpiryWarning(threshold time.Duration) string {
+	remaining := time.Until(ca.NotAfter())
+	if remaining > threshold {
+		return ""
+	}
+	if remaining <= 0 {
+		return fmt.Sprintf("CA certificate %q has expired", ca.Certificate.Subject.CommonName)
+	}
+	return fmt.Sprintf("CA certificate %q expires in %d days (%s); rotate it with 'gosniffer ca rotate'",
+		ca.Certificate.Subject.CommonName, int(remaining.Hours()/24), ca.NotAfter().Format("2006-01-02"))
+}
+
+// RotationState records a CA rotation whose grace period may still be running
+type RotationState struct {
+	RotatedAt  time.Time `json:"rotated_at"`
+	GraceUntil time.Time `json:"grace_until"`
+}
+
+// InGracePeriod reports whether the previous CA should still be used for signing
+func (s *RotationState) InGracePeriod() bool {
+	return s != nil && time.Now().Before(s.GraceUntil)
+}
+
+// PreviousPaths returns where the previous CA files are kept during rotation,
+// e.g. ca-cert.pem -> ca-cert.previous.pem
+func PreviousPaths(certPath, keyPath string) (string, string) {
+	return previousPath(certPath), previousPath(keyPath)
+}
+
+// previousPath inserts ".previous" before the file extension
+func previousPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".previous" + ext
+}
+
+// rotationStatePath returns the rotation state file for a CA certificate path
+func rotationStatePath(certPath string) string {
+	return filepath.Join(filepath.Dir(certPath), rotationStateFile)
+}
+
+// RotateCA replaces the CA at certPath/keyPath with a newly generated one.
+// The old CA files are kept as *.previous.pem and remain the signing CA for the
+// grace period, giving clients time to install the new root; with a zero grace
+// period the new CA takes over immediately.
+// An empty opts.KeyType keeps the current CA's key spec, and an empty
+// opts.Subject or nil opts.NameConstraints keeps the current CA's. opts.KeyPassphrase,
+// if set, both unlocks the current key and encrypts the new one.
+// Only root CAs can be rotated, and a rotation still in its grace period is
+// not replaced unless force is set, as that would discard the signing CA.
+// Returns the new CA, or an error if the current CA cannot be loaded or files cannot be written;
+// the current CA is left in place on error.
+func RotateCA(certPath, keyPath string, opts CAOptions, grace time.Duration, force bool) (*CA, error) {
+	current, err := LoadFromPEMWithPassphrase(certPath, keyPath, staticPassphrase(opts.KeyPassphrase))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current CA: %w", err)
+	}
+	if current.IsIntermediate() || !isSelfSigned(current.Certificate) {
+		return nil, fmt.Errorf("CA %q is an intermediate; re-issue it from its parent CA instead of rotating",
+			current.Certificate.Subject.CommonName)
+	}
+
+	state, err := LoadRotationState(certPath)
+	if err != nil {
+		return nil, err
+	}
+	if state.InGracePeriod() && !force {
+		return nil, fmt.Errorf("previous rotation is in its grace period until %s; rotating again would discard the signing CA",
+			state.GraceUntil.Format(time.RFC3339))
+	}
+
+	if opts.KeyType == "" {
+		opts.KeyType = current.KeySpec()
+	}
+	if opts.Subject.String() == "" {
+		opts.Subject = current.Certificate.Subject
+	}
+	if opts.NameConstraints == nil {
+		opts.NameConstraints = nameConstraintsOf(current.Certificate)
+	}
+
+	newCA, err := GenerateCAWithOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate new CA: %w", err)
+	}
+
+	// Write the new CA and the rotation state beside the current files before
+	// moving anything, so a failed write leaves the current CA in place
+	now := time.Now()
+	state = &RotationState{RotatedAt: now, GraceUntil: now.Add(grace)}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rotation state: %w", err)
+	}
+	newCert, newKey := certPath+".new", keyPath+".new"
+	statePath := rotationStatePath(certPath)
+	newState := statePath + ".new"
+	cleanup := func() {
+		os.Remove(newCert)
+		os.Remove(newKey)
+		os.Remove(newState)
+	}
+	if err := newCA.SaveToPEM(newCert, newKey); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := os.WriteFile(newState, data, 0644); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to write rotation state: %w", err)
+	}
+
+	// The state is moved last: without it the previous CA would stop signing
+	// at once, so its failure undoes the rotation too
+	prevCert, prevKey := PreviousPaths(certPath, keyPath)
+	renames := [][2]string{{certPath, prevCert}, {keyPath, prevKey}, {newCert, certPath}, {newKey, keyPath}, {newState, statePath}}
+	for i, rename := range renames {
+		if err := os.Rename(rename[0], rename[1]); err != nil {
+			// Undo in reverse order to put the current CA back
+			for j := i - 1; j >= 0; j-- {
+				os.Rename(renames[j][1], renames[j][0])
+			}
+			cleanup()
+			return nil, fmt.Errorf("failed to move %s to %s: %w", rename[0], rename[1], err)
+		}
+	}
+
+	packageLogger().Info("rotated CA", "fingerprint", Fingerprint(newCA.Certificate),
+		"previous_until", state.GraceUntil.Format(time.RFC3339))
+
+	return newCA, nil
+}
+
+// staticPassphrase returns a PassphraseFunc for a known passphrase, or nil if it is empty
+func staticPassphrase(passphrase []byte) PassphraseFunc {
+	if len(passphrase) == 0 {
+		return nil
+	}
+	return func() ([]byte, error) { return passphrase, nil }
+}
+
+// LoadRotationState reads the rotation state for a CA, or returns nil if no rotation was recorded
+func LoadRotationState(certPath string) (*RotationState, error) {
+	data, err := os.ReadFile(rotationStatePath(certPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rotation state: %w", err)
+	}
+
+	var state RotationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse rotation state: %w", err)
+	}
+	return &state, nil
+}
+
+// LoadActive loads the CA that should currently sign certificates.
+// During a rotation grace period this is the previous CA and upcoming is the
+// new CA clients should install; otherwise upcoming is nil.
+// passphrase unlocks encrypted keys and may be nil (see LoadFromPEMWithPassphrase).
+func LoadActive(certPath, keyPath string, passphrase PassphraseFunc) (active *CA, upcoming *CA, state *RotationState, err error) {
+	current, err := LoadFromPEMWithPassphrase(certPath, keyPath, passphrase)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	state, err = LoadRotationState(certPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !state.InGracePeriod() {
+		return current, nil, state, nil
+	}
+
+	prevCert, prevKey := PreviousPaths(certPath, keyPath)
+	previous, err := LoadFromPEMWithPassphrase(prevCert, prevKey, passphrase)
+	if err != nil {
+		// The previous CA is only a convenience for clients; fall back to the new one
+		packageLogger().Warn("previous CA unavailable during grace period, using new CA", "error", err)
+		return current, nil, state, nil
+	}
+
+	return previous, current, state, nil
+}
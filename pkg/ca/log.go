@@ -0,0 +1,56 @@
+package ca
+
+import (
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// Subsystem names of the package's loggers
+const (
+	logSubsystemCA      = "ca"
+	logSubsystemCache   = "cache"
+	logSubsystemKeyPool = "keypool"
+)
+
+// packageLogger returns the logger for package-level CA operations
+func packageLogger() *logger.Logger {
+	return logger.Default().Named(logSubsystemCA)
+}
+
+// SetLogger sets the logger for certificates issued and events reported by ca
+func (ca *CA) SetLogger(l *logger.Logger) {
+	ca.logger = l
+}
+
+// log returns the CA's logger, or the package logger if none was set
+func (ca *CA) log() *logger.Logger {
+	if ca.logger != nil {
+		return ca.logger
+	}
+	return packageLogger()
+}
+
+// SetLogger sets the logger for cache evictions and cleanup
+func (c *CertificateCache) SetLogger(l *logger.Logger) {
+	c.logger.Store(l)
+}
+
+// log returns the cache's logger, or the default logger if none was set
+func (c *CertificateCache) log() *logger.Logger {
+	if l := c.logger.Load(); l != nil {
+		return l
+	}
+	return logger.Default().Named(logSubsystemCache)
+}
+
+// SetLogger sets the logger for key generation failures
+func (p *KeyPool) SetLogger(l *logger.Logger) {
+	p.logger.Store(l)
+}
+
+// log returns the pool's logger, or the default logger if none was set
+func (p *KeyPool) log() *logger.Logger {
+	if l := p.logger.Load(); l != nil {
+		return l
+	}
+	return logger.Default().Named(logSubsystemKeyPool)
+}
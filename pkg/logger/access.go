@@ -0,0 +1,214 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Access log format names accepted by NewAccessLog
+const (
+	// AccessFormatCommon is the Common Log Format:
+	// client - - [time] "METHOD url proto" status bytes
+	AccessFormatCommon = "common"
+
+	// AccessFormatCombined is the Combined Log Format, which adds "referer" "user-agent"
+	AccessFormatCombined = "combined"
+
+	// AccessFormatJSON writes one JSON object per line
+	AccessFormatJSON = "json"
+)
+
+// clfTimeLayout is the timestamp layout of the Common Log Format
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// AccessEntry describes one proxied request for the access log
+type AccessEntry struct {
+	Time        time.Time     // When the request was received
+	ClientAddr  string        // Remote address of the proxy client (host:port)
+	Method      string        // Request method
+	URL         string        // Absolute request URL
+	Proto       string        // Request protocol, e.g. "HTTP/1.1"
+	Host        string        // Target host (host:port for CONNECT)
+	Status      int           // Response status sent to the client
+	Bytes       int64         // Response body bytes sent to the client (tunnel bytes for CONNECT)
+	Duration    time.Duration // Time from request to end of response
+	Referer     string        // Referer request header
+	UserAgent   string        // User-Agent request header
+	Intercepted bool          // Whether the request was decrypted by the MITM (HTTPS)
+	JA3         string        // Client TLS fingerprints (HTTPS only)
+	JA4         string
+	Error       string  // Why the proxy answered instead of the upstream, if it did
+	Timings     Timings // Where the time went
+}
+
+// ClientIP returns the client address without its port
+func (e AccessEntry) ClientIP() string {
+	if host, _, err := net.SplitHostPort(e.ClientAddr); err == nil {
+		return host
+	}
+	return e.ClientAddr
+}
+
+// sanitized returns e with control characters removed from every field so
+// entries cannot break log parsing or forge other lines (SR-008)
+func (e AccessEntry) sanitized() AccessEntry {
+	e.ClientAddr = sanitizeHostname(e.ClientAddr)
+	e.Host = sanitizeHostname(e.Host)
+	e.Method = sanitizeField(e.Method)
+	e.URL = sanitizeField(e.URL)
+	e.Proto = sanitizeField(e.Proto)
+	e.Referer = sanitizeField(e.Referer)
+	e.UserAgent = sanitizeField(e.UserAgent)
+	e.JA3 = sanitizeField(e.JA3)
+	e.JA4 = sanitizeField(e.JA4)
+	e.Error = sanitizeField(e.Error)
+	return e
+}
+
+// AccessLog writes one line per proxied request in a configurable format
+// It is safe for concurrent use.
+type AccessLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+	tmpl   *template.Template // User-defined format (nil for built-in formats)
+}
+
+// NewAccessLog creates an access log writing to w in format, which is one of
+// "common", "combined" or "json", or a text/template over AccessEntry fields
+// such as `{{.ClientIP}} {{.Method}} {{.URL}} {{.Status}} {{.Duration}}`
+func NewAccessLog(w io.Writer, format string) (*AccessLog, error) {
+	a := &AccessLog{w: w, format: format}
+	switch format {
+	case AccessFormatCommon, AccessFormatCombined, AccessFormatJSON:
+		return a, nil
+	}
+	if !strings.Contains(format, "{{") {
+		return nil, fmt.Errorf("unknown access log format %q (expected common, combined, json or a template)", format)
+	}
+
+	tmpl, err := template.New("access").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access log template: %w", err)
+	}
+	// Unknown fields only fail on execution, so catch them now
+	if err := tmpl.Execute(io.Discard, AccessEntry{}); err != nil {
+		return nil, fmt.Errorf("invalid access log template: %w", err)
+	}
+	a.tmpl = tmpl
+	return a, nil
+}
+
+// Log writes an entry
+func (a *AccessLog) Log(e AccessEntry) {
+	line, err := a.encode(e.sanitized())
+	if err != nil {
+		Default().Named("access").Error("failed to format access log entry", "error", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.w.Write(line)
+}
+
+// encode formats a sanitized entry as one line
+func (a *AccessLog) encode(e AccessEntry) ([]byte, error) {
+	var b bytes.Buffer
+	switch {
+	case a.tmpl != nil:
+		if err := a.tmpl.Execute(&b, e); err != nil {
+			return nil, err
+		}
+		// A template cannot produce more than one line per entry
+		line := strings.ReplaceAll(b.String(), "\n", " ")
+		return []byte(strings.TrimRight(line, " ") + "\n"), nil
+	case a.format == AccessFormatJSON:
+		return encodeAccessJSON(e)
+	}
+
+	fmt.Fprintf(&b, "%s - - [%s] \"%s %s %s\" %d %s",
+		dash(e.ClientIP()), e.Time.Format(clfTimeLayout),
+		escapeQuoted(e.Method), escapeQuoted(e.URL), escapeQuoted(e.Proto),
+		e.Status, clfBytes(e.Bytes))
+	if a.format == AccessFormatCombined {
+		fmt.Fprintf(&b, " \"%s\" \"%s\"", escapeQuoted(dash(e.Referer)), escapeQuoted(dash(e.UserAgent)))
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// accessJSON is the JSON encoding of an AccessEntry
+type accessJSON struct {
+	Time        string      `json:"time"`
+	ClientIP    string      `json:"client_ip"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	Proto       string      `json:"proto"`
+	Host        string      `json:"host"`
+	Status      int         `json:"status"`
+	Bytes       int64       `json:"bytes"`
+	DurationMS  float64     `json:"duration_ms"`
+	Referer     string      `json:"referer,omitempty"`
+	UserAgent   string      `json:"user_agent,omitempty"`
+	Intercepted bool        `json:"intercepted"`
+	JA3         string      `json:"ja3,omitempty"`
+	JA4         string      `json:"ja4,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	Timings     timingsJSON `json:"timings"`
+}
+
+// encodeAccessJSON formats an entry as a JSON object on one line
+func encodeAccessJSON(e AccessEntry) ([]byte, error) {
+	data, err := json.Marshal(accessJSON{
+		Time:        e.Time.Format(time.RFC3339Nano),
+		ClientIP:    e.ClientIP(),
+		Method:      e.Method,
+		URL:         e.URL,
+		Proto:       e.Proto,
+		Host:        e.Host,
+		Status:      e.Status,
+		Bytes:       e.Bytes,
+		DurationMS:  milliseconds(e.Duration),
+		Referer:     e.Referer,
+		UserAgent:   e.UserAgent,
+		Intercepted: e.Intercepted,
+		JA3:         e.JA3,
+		JA4:         e.JA4,
+		Error:       e.Error,
+		Timings:     e.Timings.toJSON(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// dash returns "-" for empty values, as in the Common Log Format
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// clfBytes formats a response size, using "-" for an empty body
+func clfBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// escapeQuoted escapes backslashes and double quotes inside a quoted CLF field
+func escapeQuoted(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
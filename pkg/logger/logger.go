@@ -1,68 +1,314 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
-// Logger handles request logging with hostname and status code
-// Output format: [timestamp] hostname - statusCode
+// Level is the severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the lower-case level name
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel parses "debug", "info", "warn" (or "warning") and "error"
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", name)
+}
+
+// Format selects how log entries are encoded
+type Format string
+
+const (
+	// FormatText writes "2006/01/02 15:04:05 [INFO] [subsystem] message key=value" lines
+	FormatText Format = "text"
+
+	// FormatJSON writes one JSON object per line
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses "text" or "json"
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(name))); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown log format %q (expected text or json)", name)
+}
+
+// output is the destination shared by a logger and its subsystem loggers
+type output struct {
+	mu     sync.Mutex // Serialises writes so entries are never interleaved
+	w      io.Writer
+	level  Level
+	format Format
+}
+
+// Logger writes leveled, structured log entries
+// Loggers for subsystems (see Named) share their parent's output.
 type Logger struct {
-	// Could add configuration fields here if needed (log level, output writer, etc.)
+	out       *output
+	subsystem string
+}
+
+// defaultLogger is returned by Default (text at info level to stderr unless replaced)
+var defaultLogger atomic.Pointer[Logger]
+
+func init() {
+	defaultLogger.Store(New(os.Stderr, LevelInfo, FormatText))
+}
+
+// New creates a logger writing entries at level and above to w
+func New(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{out: &output{w: w, level: level, format: format}}
 }
 
-// NewLogger creates a new Logger instance
+// NewLogger returns the default logger
 func NewLogger() *Logger {
-	return &Logger{}
+	return Default()
+}
+
+// Default returns the process-wide logger used by packages without an injected one
+func Default() *Logger {
+	return defaultLogger.Load()
+}
+
+// SetDefault replaces the process-wide logger
+// Loggers already obtained from Default keep their output.
+func SetDefault(l *Logger) {
+	defaultLogger.Store(l)
+}
+
+// Named returns a logger for a subsystem, sharing this logger's output
+// Nested names are joined with ".", e.g. "proxy.mitm".
+func (l *Logger) Named(subsystem string) *Logger {
+	if l.subsystem != "" {
+		subsystem = l.subsystem + "." + subsystem
+	}
+	return &Logger{out: l.out, subsystem: subsystem}
+}
+
+// Enabled reports whether entries at level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+// Debug logs a message with alternating key/value pairs at debug level
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LevelDebug, msg, keyvals)
+}
+
+// Info logs a message with alternating key/value pairs at info level
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LevelInfo, msg, keyvals)
+}
+
+// Warn logs a message with alternating key/value pairs at warn level
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LevelWarn, msg, keyvals)
+}
+
+// Error logs a message with alternating key/value pairs at error level
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+}
+
+// log encodes and writes one entry
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	// An odd trailing value has no key
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals[:len(keyvals)-1:len(keyvals)-1], "!BADKEY", keyvals[len(keyvals)-1])
+	}
+
+	var line []byte
+	if l.out.format == FormatJSON {
+		line = l.encodeJSON(time.Now(), level, msg, keyvals)
+	} else {
+		line = l.encodeText(time.Now(), level, msg, keyvals)
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(line)
+}
+
+// encodeText formats an entry as a single text line
+// Control characters are escaped so entries cannot forge other lines (SR-008).
+func (l *Logger) encodeText(t time.Time, level Level, msg string, keyvals []interface{}) []byte {
+	var b strings.Builder
+	b.WriteString(t.Format("2006/01/02 15:04:05"))
+	b.WriteString(" [")
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteString("] ")
+	if l.subsystem != "" {
+		b.WriteString("[")
+		b.WriteString(l.subsystem)
+		b.WriteString("] ")
+	}
+	b.WriteString(stripControl(msg))
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteString(" ")
+		b.WriteString(stripControl(fmt.Sprint(keyvals[i])))
+		b.WriteString("=")
+		b.WriteString(quoteValue(formatValue(keyvals[i+1])))
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// encodeJSON formats an entry as a JSON object on one line
+func (l *Logger) encodeJSON(t time.Time, level Level, msg string, keyvals []interface{}) []byte {
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	writeJSON(&b, t.Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	if l.subsystem != "" {
+		b.WriteString(`,"subsystem":`)
+		writeJSON(&b, l.subsystem)
+	}
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteString(",")
+		writeJSON(&b, fmt.Sprint(keyvals[i]))
+		b.WriteString(":")
+		value := keyvals[i+1]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		if _, ok := value.(fmt.Stringer); ok {
+			value = formatValue(value)
+		}
+		writeJSON(&b, value)
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// writeJSON appends the JSON encoding of v, or of its string form if v cannot be encoded
+func writeJSON(b *strings.Builder, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+// formatValue returns the text form of a log value
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case error:
+		return v.Error()
+	case string:
+		return v
+	case time.Duration:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// quoteValue quotes s if it is empty or contains spaces, quotes, "=" or
+// non-printable characters
+func quoteValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r == ' ' || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// stripControl removes control characters
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
 }
 
 // LogRequest logs a proxied request with hostname and HTTP status code
 // Sanitizes hostname to prevent log injection attacks (SR-008)
 func (l *Logger) LogRequest(hostname string, statusCode int) {
-	// Sanitize hostname to prevent log injection
-	sanitizedHostname := sanitizeHostname(hostname)
+	l.Info("request", "host", sanitizeHostname(hostname), "status", statusCode)
+}
 
-	// Format: [timestamp] hostname - statusCode
-	timestamp := time.Now().Format(time.RFC3339)
-	log.Printf("[%s] %s - %d\n", timestamp, sanitizedHostname, statusCode)
+// LogTLSRequest logs an intercepted HTTPS request with the client's TLS fingerprints
+func (l *Logger) LogTLSRequest(hostname string, statusCode int, ja3, ja4 string) {
+	l.Info("request", "host", sanitizeHostname(hostname), "status", statusCode, "ja3", ja3, "ja4", ja4)
 }
 
 // LogInfo logs an informational message
 func (l *Logger) LogInfo(message string) {
-	log.Printf("[INFO] %s\n", message)
+	l.Info(message)
 }
 
 // LogError logs an error message with context
 func (l *Logger) LogError(context string, err error) {
-	log.Printf("[ERROR] %s: %v\n", context, err)
+	l.Error(context, "error", err)
 }
 
 // LogCertGeneration logs certificate generation events with fingerprint (SR-004)
 func (l *Logger) LogCertGeneration(hostname, fingerprint string) {
-	sanitizedHostname := sanitizeHostname(hostname)
-	log.Printf("[CERT] Generated certificate for %s (fingerprint: %s)\n", sanitizedHostname, fingerprint)
+	l.Info("generated certificate", "host", sanitizeHostname(hostname), "fingerprint", fingerprint)
 }
 
 // sanitizeHostname removes potentially dangerous characters that could
 // cause log injection attacks (newlines, carriage returns, control characters)
 // This prevents malicious hostnames from breaking log parsing or injecting fake log entries
 func sanitizeHostname(hostname string) string {
-	// Remove newlines, carriage returns, and tabs
-	hostname = strings.ReplaceAll(hostname, "\n", "")
-	hostname = strings.ReplaceAll(hostname, "\r", "")
-	hostname = strings.ReplaceAll(hostname, "\t", " ")
-
-	// Remove other control characters (ASCII 0-31 and 127)
-	var sanitized strings.Builder
-	for _, ch := range hostname {
-		if ch >= 32 && ch != 127 {
-			sanitized.WriteRune(ch)
-		}
-	}
-
-	result := sanitized.String()
+	result := sanitizeField(hostname)
 
 	// Truncate if too long (prevent log flooding)
 	const maxHostnameLen = 253 // RFC 1035 maximum DNS hostname length
@@ -73,6 +319,22 @@ func sanitizeHostname(hostname string) string {
 	return result
 }
 
+// sanitizeField removes newlines, carriage returns and other control characters
+// (ASCII 0-31 and 127) from a logged value; tabs become spaces
+func sanitizeField(value string) string {
+	value = strings.ReplaceAll(value, "\n", "")
+	value = strings.ReplaceAll(value, "\r", "")
+	value = strings.ReplaceAll(value, "\t", " ")
+
+	var sanitized strings.Builder
+	for _, ch := range value {
+		if ch >= 32 && ch != 127 {
+			sanitized.WriteRune(ch)
+		}
+	}
+	return sanitized.String()
+}
+
 // FormatLogEntry creates a formatted log entry string (for testing or custom output)
 func FormatLogEntry(hostname string, statusCode int) string {
 	sanitizedHostname := sanitizeHostname(hostname)
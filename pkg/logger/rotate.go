@@ -0,0 +1,294 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeLayout is the timestamp suffix of rotated files, e.g. gosniffer.log.20260102-150405
+const rotatedTimeLayout = "20060102-150405"
+
+// compressedSuffix is appended to rotated files compressed with gzip
+const compressedSuffix = ".gz"
+
+// RotateOptions controls when a RotatingFile is rotated and how many rotated files are kept
+type RotateOptions struct {
+	MaxSize    int64 // Rotate before a write would exceed this many bytes (0 disables)
+	Daily      bool  // Rotate on the first write of each local calendar day
+	MaxBackups int   // Rotated files to keep, oldest removed first (0 keeps all)
+	Compress   bool  // Gzip rotated files
+}
+
+// RotatingFile is a log file that is rotated by size and by day
+// Rotated files are renamed to path.YYYYMMDD-HHMMSS (with .gz if compressed).
+// It is safe for concurrent use; Reopen supports external tools such as logrotate.
+type RotatingFile struct {
+	mu   sync.Mutex
+	path string
+	opts RotateOptions
+	file *os.File
+	size int64     // Bytes in the current file
+	day  time.Time // Local day the current file was started
+
+	maint   sync.WaitGroup // Background compression and cleanup
+	maintMu sync.Mutex     // Serialises maintenance so cleanup never races compression
+}
+
+// OpenRotatingFile opens path for appending, creating it if needed
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at f.path; the caller holds f.mu or has exclusive access
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	// An existing file belongs to the day it was last written
+	f.day = startOfDay(time.Now())
+	if f.size > 0 {
+		f.day = startOfDay(info.ModTime())
+	}
+	return nil
+}
+
+// Write appends p, rotating first if the file is due for rotation
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.dueLocked(int64(len(p))) {
+		if err := f.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// dueLocked reports whether the file must be rotated before writing n bytes
+func (f *RotatingFile) dueLocked(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size+n > f.opts.MaxSize {
+		return true
+	}
+	return f.opts.Daily && startOfDay(time.Now()).After(f.day)
+}
+
+// Rotate renames the current file and starts a new one
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotateLocked()
+}
+
+// rotateLocked renames the current file, opens a new one and starts
+// compression and cleanup of rotated files in the background
+func (f *RotatingFile) rotateLocked() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	f.file = nil
+
+	rotated := f.rotatedName(time.Now())
+	if err := os.Rename(f.path, rotated); err != nil {
+		// Keep writing to the current file rather than losing entries
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.maint.Add(1)
+	go func() {
+		defer f.maint.Done()
+		f.maintain(rotated)
+	}()
+	return nil
+}
+
+// rotatedName returns an unused name for the file rotated at t
+func (f *RotatingFile) rotatedName(t time.Time) string {
+	base := f.path + "." + t.Format(rotatedTimeLayout)
+	name := base
+	for i := 1; ; i++ {
+		_, err := os.Stat(name)
+		_, errGz := os.Stat(name + compressedSuffix)
+		if os.IsNotExist(err) && os.IsNotExist(errGz) {
+			return name
+		}
+		name = fmt.Sprintf("%s.%d", base, i)
+	}
+}
+
+// maintain compresses a newly rotated file and removes rotated files beyond MaxBackups
+// Errors are reported to the default logger, as the file itself may be the log.
+func (f *RotatingFile) maintain(rotated string) {
+	f.maintMu.Lock()
+	defer f.maintMu.Unlock()
+
+	if f.opts.Compress {
+		if err := compressFile(rotated); err != nil {
+			Default().Named("logfile").Error("failed to compress rotated log", "path", rotated, "error", err)
+		}
+	}
+	if f.opts.MaxBackups > 0 {
+		if err := f.removeOldBackups(f.opts.MaxBackups); err != nil {
+			Default().Named("logfile").Error("failed to remove old logs", "path", f.path, "error", err)
+		}
+	}
+}
+
+// Backups returns the rotated files of path, oldest first
+func (f *RotatingFile) Backups() ([]string, error) {
+	matches, err := filepath.Glob(globEscape(f.path) + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	prefix := f.path + "."
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, prefix), compressedSuffix)
+		if stamp, _, _ = strings.Cut(stamp, "."); len(stamp) != len(rotatedTimeLayout) {
+			continue
+		}
+		if _, err := time.Parse(rotatedTimeLayout, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	// The timestamp and counter suffixes sort chronologically within a second
+	sort.Slice(backups, func(i, j int) bool {
+		return trimGz(backups[i]) < trimGz(backups[j])
+	})
+	return backups, nil
+}
+
+// removeOldBackups removes all but the newest keep rotated files
+func (f *RotatingFile) removeOldBackups(keep int) error {
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Reopen closes and reopens the file at its path, so entries go to a new file
+// after an external tool has moved the old one away (e.g. on SIGHUP)
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	return f.open()
+}
+
+// Close closes the file after background compression and cleanup have finished
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.maint.Wait()
+	return err
+}
+
+// compressFile gzips path to path.gz and removes path
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+compressedSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	return os.Remove(path)
+}
+
+// startOfDay returns midnight of t's local day
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// trimGz removes the compressed suffix for ordering rotated files
+func trimGz(name string) string {
+	return strings.TrimSuffix(name, compressedSuffix)
+}
+
+// globEscape escapes glob metacharacters in a literal path
+func globEscape(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
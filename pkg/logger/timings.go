@@ -0,0 +1,57 @@
+package logger
+
+import "time"
+
+// Timings breaks down where the time of one proxied exchange went
+// Connection phases are zero when the exchange reused an existing connection.
+type Timings struct {
+	DNSLookup       time.Duration // Resolving the upstream host
+	TCPConnect      time.Duration // Connecting to the upstream server
+	UpstreamTLS     time.Duration // TLS handshake with the upstream server
+	ClientTLS       time.Duration // TLS handshake with the client (intercepted HTTPS only)
+	RequestSend     time.Duration // Reading the request from the client and sending it upstream
+	TimeToFirstByte time.Duration // From the request being sent to the first response byte
+	BodyTransfer    time.Duration // Relaying the response to the client
+}
+
+// KeyValues returns the timings as alternating key/value pairs for Logger methods
+func (t Timings) KeyValues() []interface{} {
+	return []interface{}{
+		"dns", t.DNSLookup,
+		"connect", t.TCPConnect,
+		"upstream_tls", t.UpstreamTLS,
+		"client_tls", t.ClientTLS,
+		"send", t.RequestSend,
+		"ttfb", t.TimeToFirstByte,
+		"transfer", t.BodyTransfer,
+	}
+}
+
+// timingsJSON is the JSON encoding of Timings in milliseconds
+type timingsJSON struct {
+	DNSLookup       float64 `json:"dns_ms"`
+	TCPConnect      float64 `json:"connect_ms"`
+	UpstreamTLS     float64 `json:"upstream_tls_ms"`
+	ClientTLS       float64 `json:"client_tls_ms"`
+	RequestSend     float64 `json:"send_ms"`
+	TimeToFirstByte float64 `json:"ttfb_ms"`
+	BodyTransfer    float64 `json:"transfer_ms"`
+}
+
+// toJSON converts the timings to milliseconds
+func (t Timings) toJSON() timingsJSON {
+	return timingsJSON{
+		DNSLookup:       milliseconds(t.DNSLookup),
+		TCPConnect:      milliseconds(t.TCPConnect),
+		UpstreamTLS:     milliseconds(t.UpstreamTLS),
+		ClientTLS:       milliseconds(t.ClientTLS),
+		RequestSend:     milliseconds(t.RequestSend),
+		TimeToFirstByte: milliseconds(t.TimeToFirstByte),
+		BodyTransfer:    milliseconds(t.BodyTransfer),
+	}
+}
+
+// milliseconds returns d in fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,342 @@
+// Package metrics implements counters, histograms and gauges exposed in the
+// Prometheus text exposition format (version 0.0.4) using only the standard library
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are histogram bucket upper bounds in seconds, suited to network latencies
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector writes the samples of one metric family
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics and serves them to Prometheus
+// Registering a name again replaces the earlier metric.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// register adds c, replacing any metric of the same name
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors[c.name()] = c
+}
+
+// WriteTo writes all metrics in the text exposition format, sorted by name
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	collectors := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP serves the metrics for scraping
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+// desc is the name, help text and label names shared by a metric family
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (d *desc) name() string { return d.metricName }
+
+// writeHeader writes the HELP and TYPE lines of the family
+func (d *desc) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, typ)
+}
+
+// writeSample writes one sample line; extra is an additional label such as le
+func (d *desc) writeSample(w *bufio.Writer, suffix string, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(d.metricName)
+	w.WriteString(suffix)
+	if len(values) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, value := range values {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", d.labels[i], escapeLabel(value))
+		}
+		if extraName != "" {
+			if len(values) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	bits atomic.Uint64 // float64 bits
+}
+
+// Inc adds one
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v, which must not be negative
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	addFloat(&c.bits, v)
+}
+
+// Value returns the current value
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// CounterVec is a family of counters partitioned by label values
+type CounterVec struct {
+	desc
+	mu       sync.RWMutex
+	counters map[string]*labeled[*Counter]
+}
+
+// labeled is one member of a vector with its label values
+type labeled[T any] struct {
+	values []string
+	metric T
+}
+
+// NewCounterVec registers a counter family with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{desc: desc{name, help, labels}, counters: make(map[string]*labeled[*Counter])}
+	r.register(v)
+	return v
+}
+
+// NewCounter registers a counter without labels
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// With returns the counter for the label values, given in label name order
+func (v *CounterVec) With(values ...string) *Counter {
+	return withLabels(&v.mu, v.counters, &v.desc, values, func() *Counter { return &Counter{} })
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.writeHeader(w, "counter")
+	for _, c := range sortedMembers(&v.mu, v.counters) {
+		v.writeSample(w, "", c.values, "", "", c.metric.Value())
+	}
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	upperBounds []float64
+	counts      []atomic.Uint64 // Per bucket, not cumulative; last is +Inf
+	count       atomic.Uint64
+	sum         atomic.Uint64 // float64 bits
+}
+
+// Observe records one observation
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	h.counts[i].Add(1)
+	addFloat(&h.sum, v)
+	h.count.Add(1)
+}
+
+// HistogramVec is a family of histograms partitioned by label values
+type HistogramVec struct {
+	desc
+	buckets    []float64
+	mu         sync.RWMutex
+	histograms map[string]*labeled[*Histogram]
+}
+
+// NewHistogramVec registers a histogram family with bucket upper bounds (sorted
+// ascending; +Inf is implicit) and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{
+		desc:       desc{name, help, labels},
+		buckets:    buckets,
+		histograms: make(map[string]*labeled[*Histogram]),
+	}
+	r.register(v)
+	return v
+}
+
+// NewHistogram registers a histogram without labels
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	return r.NewHistogramVec(name, help, buckets).With()
+}
+
+// With returns the histogram for the label values, given in label name order
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return withLabels(&v.mu, v.histograms, &v.desc, values, func() *Histogram {
+		return &Histogram{upperBounds: v.buckets, counts: make([]atomic.Uint64, len(v.buckets)+1)}
+	})
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.writeHeader(w, "histogram")
+	for _, h := range sortedMembers(&v.mu, v.histograms) {
+		var cumulative uint64
+		for i, bound := range v.buckets {
+			cumulative += h.metric.counts[i].Load()
+			v.writeSample(w, "_bucket", h.values, "le", formatFloat(bound), float64(cumulative))
+		}
+		cumulative += h.metric.counts[len(v.buckets)].Load()
+		v.writeSample(w, "_bucket", h.values, "le", "+Inf", float64(cumulative))
+		v.writeSample(w, "_sum", h.values, "", "", math.Float64frombits(h.metric.sum.Load()))
+		v.writeSample(w, "_count", h.values, "", "", float64(h.metric.count.Load()))
+	}
+}
+
+// funcMetric is a gauge or counter whose value is read when scraped
+type funcMetric struct {
+	desc
+	typ   string
+	value func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by f at scrape time
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(&funcMetric{desc: desc{metricName: name, help: help}, typ: "gauge", value: f})
+}
+
+// NewCounterFunc registers a counter whose value is returned by f at scrape time
+func (r *Registry) NewCounterFunc(name, help string, f func() float64) {
+	r.register(&funcMetric{desc: desc{metricName: name, help: help}, typ: "counter", value: f})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	m.writeHeader(w, m.typ)
+	m.writeSample(w, "", nil, "", "", m.value())
+}
+
+// withLabels returns the member of a vector for values, creating it if needed
+func withLabels[T any](mu *sync.RWMutex, members map[string]*labeled[T], d *desc, values []string, create func() T) T {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.metricName, len(d.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	mu.RLock()
+	member, ok := members[key]
+	mu.RUnlock()
+	if ok {
+		return member.metric
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if member, ok := members[key]; ok {
+		return member.metric
+	}
+	member = &labeled[T]{values: append([]string(nil), values...), metric: create()}
+	members[key] = member
+	return member.metric
+}
+
+// sortedMembers returns the members of a vector ordered by label values
+func sortedMembers[T any](mu *sync.RWMutex, members map[string]*labeled[T]) []*labeled[T] {
+	mu.RLock()
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]*labeled[T], len(keys))
+	for i, key := range keys {
+		sorted[i] = members[key]
+	}
+	mu.RUnlock()
+	return sorted
+}
+
+// addFloat atomically adds v to the float64 stored as bits
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// formatFloat formats a sample value as Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp escapes backslashes and newlines in HELP text
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabel escapes backslashes, double quotes and newlines in label values
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,339 @@
+package pkcs12
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/yourusername/go-mitmproxy/pkg/pkcs8"
+)
+
+const (
+	// Diversifier IDs for legacy PBE key and IV material (RFC 7292 Appendix B.3)
+	keyDerivationKey = 1
+	keyDerivationIV  = 2
+)
+
+var (
+	// ErrIncorrectPassword is returned when the integrity MAC does not verify,
+	// which almost always means the password is wrong
+	ErrIncorrectPassword = errors.New("pkcs12: incorrect password")
+
+	// RFC 2315 / RFC 7292 / RFC 8018 object identifiers used when decoding
+	oidEncryptedDataContentType      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag                        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd128BitRC2CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 5}
+	oidPBEWithSHAAnd40BitRC2CBC      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidSHA1                          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA384                        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512                        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// encryptedData is the PKCS#7 EncryptedData content (RFC 2315 Section 13)
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+// encryptedContentInfo holds the algorithm and ciphertext of an EncryptedData
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+// encryptedPrivateKeyInfo is a pkcs8ShroudedKeyBag (RFC 5958 Section 3)
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbeParams parameterises the legacy PKCS#12 PBE schemes (RFC 7292 Appendix C)
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// Decode extracts a private key and its certificate chain from a PKCS#12 file,
+// as exported by OpenSSL, browsers and Windows. The certificate matching the
+// private key is returned as certificate; the remaining certificates (usually
+// intermediates) are returned in caCerts.
+// Supports the PBES2 (PBKDF2 with AES-CBC) encryption used by OpenSSL 3 and the
+// legacy 3DES and RC2 schemes. Returns ErrIncorrectPassword if the MAC check fails.
+func Decode(data []byte, password string) (privateKey interface{}, certificate *x509.Certificate, caCerts []*x509.Certificate, err error) {
+	var p pfx
+	if rest, err := asn1.Unmarshal(data, &p); err != nil {
+		return nil, nil, nil, fmt.Errorf("pkcs12: failed to parse PFX: %w", err)
+	} else if len(rest) > 0 {
+		return nil, nil, nil, fmt.Errorf("pkcs12: trailing data after PFX")
+	}
+	if p.Version != pfxVersion {
+		return nil, nil, nil, fmt.Errorf("pkcs12: unsupported version %d", p.Version)
+	}
+	if !p.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, nil, nil, fmt.Errorf("pkcs12: public-key integrity mode is not supported")
+	}
+
+	var authenticatedSafe []byte
+	if _, err := asn1.Unmarshal(p.AuthSafe.Content.Bytes, &authenticatedSafe); err != nil {
+		return nil, nil, nil, fmt.Errorf("pkcs12: failed to parse authenticated safe: %w", err)
+	}
+	if len(p.MacData.Mac.Digest) > 0 {
+		if err := verifyMAC(&p.MacData, authenticatedSafe, password); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	var contents []contentInfo
+	if _, err := asn1.Unmarshal(authenticatedSafe, &contents); err != nil {
+		return nil, nil, nil, fmt.Errorf("pkcs12: failed to parse content infos: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for _, ci := range contents {
+		bags, err := decodeSafeContents(ci, password)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				cert, err := decodeCertBag(bag.Value.Bytes)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				certs = append(certs, cert)
+			case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				if privateKey != nil {
+					return nil, nil, nil, fmt.Errorf("pkcs12: file contains more than one private key")
+				}
+				if privateKey, err = decodeKeyBag(bag, password); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			// Other bag types (CRLs, secrets) are ignored
+		}
+	}
+
+	if privateKey == nil {
+		return nil, nil, nil, fmt.Errorf("pkcs12: no private key found")
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("pkcs12: unsupported private key type %T", privateKey)
+	}
+	type publicKey interface{ Equal(crypto.PublicKey) bool }
+	for _, cert := range certs {
+		if certificate == nil {
+			if pub, ok := cert.PublicKey.(publicKey); ok && pub.Equal(signer.Public()) {
+				certificate = cert
+				continue
+			}
+		}
+		caCerts = append(caCerts, cert)
+	}
+	if certificate == nil {
+		return nil, nil, nil, fmt.Errorf("pkcs12: no certificate matches the private key")
+	}
+	return privateKey, certificate, caCerts, nil
+}
+
+// verifyMAC checks the integrity MAC over the authenticated safe (RFC 7292 Section 5)
+func verifyMAC(mac *macData, content []byte, password string) error {
+	var newHash func() hash.Hash
+	switch alg := mac.Mac.Algorithm.Algorithm; {
+	case alg.Equal(oidSHA1):
+		newHash = sha1.New
+	case alg.Equal(oidSHA256):
+		newHash = sha256.New
+	case alg.Equal(oidSHA384):
+		newHash = sha512.New384
+	case alg.Equal(oidSHA512):
+		newHash = sha512.New
+	default:
+		return fmt.Errorf("pkcs12: unsupported MAC algorithm %s", alg)
+	}
+	if err := checkIterations(mac.Iterations); err != nil {
+		return err
+	}
+
+	// An empty password is encoded as an empty BMPString by some tools and as
+	// no password at all by others; OpenSSL accepts both
+	candidates := [][]byte{bmpString(password, true)}
+	if password == "" {
+		candidates = append(candidates, nil)
+	}
+	for _, pw := range candidates {
+		key := deriveKey(newHash, pw, mac.MacSalt, keyDerivationMACKey, mac.Iterations, newHash().Size())
+		h := hmac.New(newHash, key)
+		h.Write(content)
+		if hmac.Equal(h.Sum(nil), mac.Mac.Digest) {
+			return nil
+		}
+	}
+	return ErrIncorrectPassword
+}
+
+// decodeSafeContents returns the bags of one authenticated safe entry,
+// decrypting it first if necessary
+func decodeSafeContents(ci contentInfo, password string) ([]safeBag, error) {
+	var data []byte
+	switch {
+	case ci.ContentType.Equal(oidDataContentType):
+		if _, err := asn1.Unmarshal(ci.Content.Bytes, &data); err != nil {
+			return nil, fmt.Errorf("pkcs12: failed to parse safe contents: %w", err)
+		}
+	case ci.ContentType.Equal(oidEncryptedDataContentType):
+		var ed encryptedData
+		if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+			return nil, fmt.Errorf("pkcs12: failed to parse encrypted data: %w", err)
+		}
+		var err error
+		data, err = decrypt(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, password)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("pkcs12: unsupported content type %s", ci.ContentType)
+	}
+
+	var bags []safeBag
+	if _, err := asn1.Unmarshal(data, &bags); err != nil {
+		return nil, fmt.Errorf("pkcs12: failed to parse safe bags: %w", err)
+	}
+	return bags, nil
+}
+
+// decodeCertBag parses the X.509 certificate in a certBag
+func decodeCertBag(der []byte) (*x509.Certificate, error) {
+	var bag certBag
+	if _, err := asn1.Unmarshal(der, &bag); err != nil {
+		return nil, fmt.Errorf("pkcs12: failed to parse certificate bag: %w", err)
+	}
+	if !bag.ID.Equal(oidCertTypeX509) {
+		return nil, fmt.Errorf("pkcs12: unsupported certificate type %s", bag.ID)
+	}
+	var certDER []byte
+	if _, err := asn1.Unmarshal(bag.Data.Bytes, &certDER); err != nil {
+		return nil, fmt.Errorf("pkcs12: failed to parse certificate data: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs12: failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// decodeKeyBag parses a plain or shrouded (encrypted) PKCS#8 key bag
+func decodeKeyBag(bag safeBag, password string) (interface{}, error) {
+	keyDER := bag.Value.Bytes
+	if bag.ID.Equal(oidPKCS8ShroudedKeyBag) {
+		var info encryptedPrivateKeyInfo
+		if _, err := asn1.Unmarshal(bag.Value.Bytes, &info); err != nil {
+			return nil, fmt.Errorf("pkcs12: failed to parse shrouded key bag: %w", err)
+		}
+		var err error
+		if keyDER, err = decrypt(info.Algorithm, info.EncryptedData, password); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(keyDER)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs12: failed to parse private key: %w", err)
+	}
+	return key, nil
+}
+
+// decrypt decrypts data encrypted with a PKCS#12 or PKCS#5 password-based scheme
+func decrypt(alg pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	if alg.Algorithm.Equal(oidPBES2) {
+		// PBES2 takes the password as UTF-8 bytes, not a BMPString;
+		// EncryptedPrivateKeyInfo carries exactly the algorithm and ciphertext
+		der, err := asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: alg, EncryptedData: data})
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := pkcs8.DecryptPrivateKey(der, []byte(password))
+		if err != nil {
+			return nil, fmt.Errorf("pkcs12: %w", err)
+		}
+		return plaintext, nil
+	}
+
+	var params pbeParams
+	if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("pkcs12: failed to parse PBE parameters: %w", err)
+	}
+	if err := checkIterations(params.Iterations); err != nil {
+		return nil, err
+	}
+	pw := bmpString(password, true)
+
+	var block cipher.Block
+	switch {
+	case alg.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC):
+		key := deriveKey(sha1.New, pw, params.Salt, keyDerivationKey, params.Iterations, 24)
+		var err error
+		if block, err = des.NewTripleDESCipher(key); err != nil {
+			return nil, err
+		}
+	case alg.Algorithm.Equal(oidPBEWithSHAAnd128BitRC2CBC), alg.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2CBC):
+		keyLength := 16
+		if alg.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2CBC) {
+			keyLength = 5
+		}
+		key := deriveKey(sha1.New, pw, params.Salt, keyDerivationKey, params.Iterations, keyLength)
+		var err error
+		if block, err = newRC2Cipher(key, keyLength*8); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("pkcs12: unsupported encryption algorithm %s", alg.Algorithm)
+	}
+
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("pkcs12: encrypted data is not a multiple of the block size")
+	}
+	iv := deriveKey(sha1.New, pw, params.Salt, keyDerivationIV, params.Iterations, block.BlockSize())
+	plaintext := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, data)
+
+	return unpad(plaintext, block.BlockSize())
+}
+
+// checkIterations refuses key derivation iteration counts outside the range
+// real tools write, so a crafted file cannot stall decoding
+func checkIterations(n int) error {
+	if n < 1 || n > pkcs8.MaxIterations {
+		return fmt.Errorf("pkcs12: iteration count %d out of range (1 to %d)", n, pkcs8.MaxIterations)
+	}
+	return nil
+}
+
+// unpad removes PKCS#7 padding; invalid padding means the password is wrong
+func unpad(data []byte, blockSize int) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize || n > len(data) {
+		return nil, ErrIncorrectPassword
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, ErrIncorrectPassword
+		}
+	}
+	return data[:len(data)-n], nil
+}
@@ -0,0 +1,288 @@
+// Package pkcs12 implements the subset of PKCS#12 (RFC 7292) needed to hand
+// certificates to devices and browsers and to read client certificates with
+// their private keys, using only the standard library.
+package pkcs12
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+)
+
+const (
+	// macIterations matches the OpenSSL 3 default for PKCS#12 MAC key derivation
+	macIterations = 2048
+
+	// macSaltLength is the salt size in bytes for the MAC key derivation
+	macSaltLength = 16
+
+	// pfxVersion is the only version defined by RFC 7292 Section 4
+	pfxVersion = 3
+
+	// keyDerivationMACKey is the diversifier ID for MAC key material (RFC 7292 Appendix B.3)
+	keyDerivationMACKey = 3
+
+	// ASN.1 class and universal tags not covered by encoding/asn1 helpers (X.680)
+	classContextSpecific = 2
+	tagBMPString         = 30
+	tagSet               = 17
+)
+
+var (
+	// RFC 2315 / RFC 7292 / RFC 2985 object identifiers
+	oidDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCertBag         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// pfx is the top-level PKCS#12 structure (RFC 7292 Section 4)
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+// contentInfo is the PKCS#7 ContentInfo wrapper (RFC 2315 Section 7)
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+// macData holds the integrity MAC over the authenticated safe (RFC 7292 Section 4)
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+// digestInfo is the PKCS#1 DigestInfo structure
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+// safeBag is a single PKCS#12 bag (RFC 7292 Section 4.2)
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+// certBag carries one X.509 certificate (RFC 7292 Section 4.2.3)
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data asn1.RawValue `asn1:"tag:0,explicit"`
+}
+
+// pkcs12Attribute is a bag attribute such as friendlyName
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+// EncodeCertificates builds a PKCS#12 file containing only certificates (no private
+// keys), as used to install a CA into device trust stores. The file is integrity
+// protected with an HMAC-SHA256 keyed from password; an empty password is allowed.
+// friendlyName is shown by most importers and may be empty.
+// Returns an error if certs is empty or ASN.1 encoding fails.
+func EncodeCertificates(certs []*x509.Certificate, password, friendlyName string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates to encode")
+	}
+
+	var bags []safeBag
+	for _, cert := range certs {
+		bag, err := newCertBag(cert, friendlyName)
+		if err != nil {
+			return nil, err
+		}
+		bags = append(bags, bag)
+	}
+
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode safe contents: %w", err)
+	}
+
+	dataInfo, err := newDataContentInfo(safeContents)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticatedSafe, err := asn1.Marshal([]contentInfo{dataInfo})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode authenticated safe: %w", err)
+	}
+
+	authSafe, err := newDataContentInfo(authenticatedSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := computeMAC(authenticatedSafe, password)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := asn1.Marshal(pfx{
+		Version:  pfxVersion,
+		AuthSafe: authSafe,
+		MacData:  mac,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PFX: %w", err)
+	}
+	return encoded, nil
+}
+
+// newCertBag wraps a certificate in a SafeBag with an optional friendlyName attribute
+func newCertBag(cert *x509.Certificate, friendlyName string) (safeBag, error) {
+	certOctets, err := asn1.Marshal(cert.Raw)
+	if err != nil {
+		return safeBag{}, fmt.Errorf("failed to encode certificate: %w", err)
+	}
+
+	bagBytes, err := asn1.Marshal(certBag{
+		ID:   oidCertTypeX509,
+		Data: explicitTag0(certOctets),
+	})
+	if err != nil {
+		return safeBag{}, fmt.Errorf("failed to encode certificate bag: %w", err)
+	}
+
+	bag := safeBag{
+		ID:    oidCertBag,
+		Value: explicitTag0(bagBytes),
+	}
+
+	if friendlyName != "" {
+		nameBytes, err := asn1.Marshal(asn1.RawValue{Tag: tagBMPString, Bytes: bmpString(friendlyName, false)})
+		if err != nil {
+			return safeBag{}, fmt.Errorf("failed to encode friendly name: %w", err)
+		}
+		bag.Attributes = append(bag.Attributes, pkcs12Attribute{
+			ID:    oidFriendlyName,
+			Value: asn1.RawValue{Tag: tagSet, IsCompound: true, Bytes: nameBytes},
+		})
+	}
+
+	return bag, nil
+}
+
+// newDataContentInfo wraps raw bytes in an id-data ContentInfo
+func newDataContentInfo(data []byte) (contentInfo, error) {
+	octets, err := asn1.Marshal(data)
+	if err != nil {
+		return contentInfo{}, fmt.Errorf("failed to encode content: %w", err)
+	}
+	return contentInfo{
+		ContentType: oidDataContentType,
+		Content:     explicitTag0(octets),
+	}, nil
+}
+
+// computeMAC derives the MAC key from password and returns the MacData over content
+func computeMAC(content []byte, password string) (macData, error) {
+	salt := make([]byte, macSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return macData{}, fmt.Errorf("failed to generate MAC salt: %w", err)
+	}
+
+	key := deriveKey(sha256.New, bmpString(password, true), salt, keyDerivationMACKey, macIterations, sha256.Size)
+	h := hmac.New(sha256.New, key)
+	h.Write(content)
+
+	return macData{
+		Mac: digestInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			Digest:    h.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: macIterations,
+	}, nil
+}
+
+// deriveKey implements the PKCS#12 key derivation function (RFC 7292 Appendix B.2)
+// password must already be a NUL-terminated BMPString
+func deriveKey(newHash func() hash.Hash, password, salt []byte, id byte, iterations, size int) []byte {
+	h := newHash()
+	v := h.BlockSize()
+
+	// Step 1: diversifier D is v copies of the ID byte
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+
+	// Steps 2-4: I = S || P, each repeated to a multiple of v bytes
+	i := append(fillBlocks(salt, v), fillBlocks(password, v)...)
+
+	var out []byte
+	for len(out) < size {
+		// Step 6a: A = H^iterations(D || I)
+		h.Reset()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// Steps 6b-6c: I_j = (I_j + B + 1) mod 2^(v*8) for each v-byte block
+		b := fillBlocks(a, v)[:v]
+		for j := 0; j < len(i); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(i[j+k]) + int(b[k]) + carry
+				i[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+
+	return out[:size]
+}
+
+// fillBlocks repeats data to the smallest multiple of v bytes that holds it
+func fillBlocks(data []byte, v int) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	n := v * ((len(data) + v - 1) / v)
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = data[i%len(data)]
+	}
+	return out
+}
+
+// bmpString encodes s as big-endian UTF-16, optionally with the two-byte NUL
+// terminator required for PKCS#12 passwords (RFC 7292 Appendix B.1)
+func bmpString(s string, terminate bool) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, 0, 2*len(units)+2)
+	for _, unit := range units {
+		out = append(out, byte(unit>>8), byte(unit))
+	}
+	if terminate {
+		out = append(out, 0, 0)
+	}
+	return out
+}
+
+// explicitTag0 wraps DER bytes in a [0] EXPLICIT context-specific tag
+// encoding/asn1 writes RawValue fields verbatim, so the tag is set here
+func explicitTag0(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: classContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
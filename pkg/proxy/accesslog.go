@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// SetAccessLog writes an access log entry for every proxied request (nil disables it)
+// Plain HTTP requests and, if configured, intercepted HTTPS requests and
+// tunnelled CONNECTs are logged.
+func (p *ProxyServer) SetAccessLog(accessLog *logger.AccessLog) {
+	p.accessLog = accessLog
+	if p.mitmHandler != nil {
+		p.mitmHandler.SetAccessLog(accessLog)
+	}
+}
+
+// SetAccessLog writes an access log entry for every intercepted request and every
+// CONNECT that is not intercepted (nil disables it)
+func (m *MITMHandler) SetAccessLog(accessLog *logger.AccessLog) {
+	m.accessLog = accessLog
+}
+
+// logAccess writes an access log entry for an intercepted exchange on conn
+func (m *MITMHandler) logAccess(conn *Flow, req *http.Request, statusCode int, bytes int64, start time.Time, timings logger.Timings) {
+	if m.accessLog == nil {
+		return
+	}
+
+	m.accessLog.Log(logger.AccessEntry{
+		Time:        start,
+		ClientAddr:  conn.ClientAddr,
+		Method:      req.Method,
+		URL:         interceptedURL(conn.Host, req.URL),
+		Proto:       req.Proto,
+		Host:        conn.Host,
+		Status:      statusCode,
+		Bytes:       bytes,
+		Duration:    time.Since(start),
+		Referer:     req.Referer(),
+		UserAgent:   req.UserAgent(),
+		Intercepted: true,
+		JA3:         conn.JA3,
+		JA4:         conn.JA4,
+		Error:       conn.Error,
+		Timings:     timings,
+	})
+}
+
+// logTunnel writes an access log entry for a CONNECT request that was not
+// intercepted: tunnelled, rejected or blocked. reason is set if the proxy
+// refused the tunnel or could not relay it
+func (m *MITMHandler) logTunnel(r *http.Request, statusCode int, bytes int64, start time.Time, ja3, ja4, reason string) {
+	if m.accessLog == nil {
+		return
+	}
+
+	m.accessLog.Log(logger.AccessEntry{
+		Time:       start,
+		ClientAddr: r.RemoteAddr,
+		Method:     r.Method,
+		URL:        r.Host,
+		Proto:      r.Proto,
+		Host:       r.Host,
+		Status:     statusCode,
+		Bytes:      bytes,
+		Duration:   time.Since(start),
+		UserAgent:  r.UserAgent(),
+		JA3:        ja3,
+		JA4:        ja4,
+		Error:      reason,
+	})
+}
+
+// interceptedURL returns the absolute URL of an intercepted request to the CONNECT
+// target host (host:port), omitting the default HTTPS port
+func interceptedURL(host string, u *url.URL) string {
+	abs := *u
+	abs.Scheme = "https"
+	abs.Host = strings.TrimSuffix(host, ":443")
+	return abs.String()
+}
+
+// serveRecordedHTTP forwards a plain HTTP request, recording it in the access log and metrics
+func (p *ProxyServer) serveRecordedHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &accessRecorder{ResponseWriter: w}
+	body := countRequestBody(r)
+	timings := handleHTTPRequest(rec, r, p.logger, p.forwardOptions())
+
+	// The response headers are written as soon as the upstream has answered
+	if !rec.headerAt.IsZero() {
+		p.metrics.observeUpstreamLatency("http", rec.headerAt.Sub(start))
+	}
+	p.metrics.observeExchange("http", r.Method, rec.status, body.n, rec.bytes)
+	if p.accessLog == nil {
+		return
+	}
+
+	p.accessLog.Log(logger.AccessEntry{
+		Time:       start,
+		ClientAddr: r.RemoteAddr,
+		Method:     r.Method,
+		URL:        r.URL.String(),
+		Proto:      r.Proto,
+		Host:       getHostname(r),
+		Status:     rec.status,
+		Bytes:      rec.bytes,
+		Duration:   time.Since(start),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		Timings:    timings,
+	})
+}
+
+// accessRecorder records the status, body size and header time of a response
+type accessRecorder struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	headerAt time.Time
+}
+
+func (r *accessRecorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+		r.headerAt = time.Now()
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Unwrap returns the recorded ResponseWriter, for http.ResponseController
+func (r *accessRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *accessRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from a response body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countRequestBody wraps req.Body, unless empty, to count the bytes read from it
+func countRequestBody(req *http.Request) *countingReader {
+	body := &countingReader{ReadCloser: req.Body}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = body
+	}
+	return body
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxBreakpointEditSize limits the size of request bodies sent to the API
+const maxBreakpointEditSize = 64 << 20
+
+// newAPI returns the routes of the breakpoint API:
+//
+//	GET    /flows                 paused flows, oldest first
+//	GET    /flows/{id}            one paused flow
+//	POST   /flows/{id}/resume     continue, applying an optional BreakpointEdit
+//	POST   /flows/{id}/drop       close the client connection
+//	POST   /flows/{id}/replace    answer with a BreakpointEdit as the response
+//	GET    /filters               filters
+//	POST   /filters               add a BreakpointFilter
+//	DELETE /filters/{id}          remove a filter
+func (b *Breakpoints) newAPI() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /flows", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string][]PausedFlow{"flows": b.Paused()})
+	})
+	mux.HandleFunc("GET /flows/{id}", func(w http.ResponseWriter, r *http.Request) {
+		flow, ok := b.Get(r.PathValue("id"))
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Errorf("%w %q", errNoPausedFlow, r.PathValue("id")))
+			return
+		}
+		writeJSON(w, http.StatusOK, flow)
+	})
+	for _, action := range []string{BreakpointResume, BreakpointDrop, BreakpointReplace} {
+		mux.HandleFunc("POST /flows/{id}/"+action, func(w http.ResponseWriter, r *http.Request) {
+			b.serveResolve(w, r, action)
+		})
+	}
+	mux.HandleFunc("GET /filters", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string][]BreakpointFilter{"filters": b.Filters()})
+	})
+	mux.HandleFunc("POST /filters", func(w http.ResponseWriter, r *http.Request) {
+		var filter BreakpointFilter
+		if err := decodeJSONBody(r, &filter); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		filter, err := b.AddFilter(filter)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, filter)
+	})
+	mux.HandleFunc("DELETE /filters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil || !b.RemoveFilter(id) {
+			writeJSONError(w, http.StatusNotFound, fmt.Errorf("no filter %q", r.PathValue("id")))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// ServeHTTP serves the breakpoint API
+func (b *Breakpoints) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.api.ServeHTTP(w, r)
+}
+
+// serveResolve resolves the paused flow named in the path with action and the
+// edit in the request body, if any
+func (b *Breakpoints) serveResolve(w http.ResponseWriter, r *http.Request, action string) {
+	var edit BreakpointEdit
+	if err := decodeJSONBody(r, &edit); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := b.Resolve(r.PathValue("id"), action, edit); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errNoPausedFlow) {
+			status = http.StatusNotFound
+		}
+		writeJSONError(w, status, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeJSONBody decodes the JSON request body into v, rejecting unknown fields;
+// an empty body yields io.EOF
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBreakpointEditSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return err
+		}
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes v as a JSON response with status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeJSONError writes err as a JSON error response with status
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
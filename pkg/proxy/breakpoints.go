@@ -0,0 +1,746 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
+)
+
+// Breakpoint phases
+const (
+	BreakpointPhaseRequest  = "request"  // Before the request is sent upstream
+	BreakpointPhaseResponse = "response" // Before the response is relayed to the client
+)
+
+// Breakpoint actions resolving a paused flow
+const (
+	BreakpointResume  = "resume"  // Continue, with any edits applied
+	BreakpointDrop    = "drop"    // Close the client connection without answering
+	BreakpointReplace = "replace" // Answer with the edit as the complete response
+)
+
+// DefaultBreakpointTimeout is how long a flow stays paused before the timeout
+// action is taken, unless changed with SetTimeout
+const DefaultBreakpointTimeout = 5 * time.Minute
+
+// bodyEncodingBase64 marks bodies that are not valid UTF-8
+const bodyEncodingBase64 = "base64"
+
+// maxBreakpointBodySize is the largest body held in memory at a breakpoint;
+// longer bodies are shown truncated and relayed unchanged
+const maxBreakpointBodySize = 8 << 20
+
+var (
+	// errBreakpointDrop is reported for exchanges dropped at a breakpoint
+	errBreakpointDrop = errors.New("dropped at breakpoint")
+
+	// errNoPausedFlow is returned by Resolve for unknown or already resolved flows
+	errNoPausedFlow = errors.New("no paused flow")
+)
+
+// BreakpointFilter selects the flows paused at the request and/or response phase
+type BreakpointFilter struct {
+	ID       int      `json:"id"`      // Assigned by AddFilter
+	Pattern  string   `json:"pattern"` // URL glob as for MapLocal, e.g. "https://api.example.com/*"
+	Methods  []string `json:"methods,omitempty"`
+	Request  bool     `json:"request"`
+	Response bool     `json:"response"`
+}
+
+// compiledBreakpointFilter is a validated filter with its pattern compiled
+type compiledBreakpointFilter struct {
+	BreakpointFilter
+	pattern *regexp.Regexp
+	origin  *regexp.Regexp  // The scheme://host part of pattern
+	methods map[string]bool // nil matches every method
+}
+
+// PausedFlow is a request or response waiting at a breakpoint
+type PausedFlow struct {
+	ID     string      `json:"id"`
+	Phase  string      `json:"phase"` // BreakpointPhaseRequest or BreakpointPhaseResponse
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status,omitempty"` // Response phase only
+	Header http.Header `json:"header"`
+
+	// Body is the request or response body; BodyEncoding is "base64" if the body
+	// is not valid UTF-8 and empty otherwise. Bodies longer than 8 MiB are
+	// truncated to that size and cannot be edited, only replaced.
+	Body          string `json:"body"`
+	BodyEncoding  string `json:"body_encoding,omitempty"`
+	BodyTruncated bool   `json:"body_truncated,omitempty"`
+
+	Paused   time.Time `json:"paused"`
+	Deadline time.Time `json:"deadline"` // When the timeout action is taken
+
+	fixedOrigin bool // The URL's scheme and host cannot be edited (intercepted HTTPS)
+}
+
+// BreakpointEdit changes a paused flow as it is resumed, or is the response a
+// flow is replaced with. Fields left empty are not changed.
+type BreakpointEdit struct {
+	Method string      `json:"method,omitempty"` // Request phase only
+	URL    string      `json:"url,omitempty"`    // Request phase only; the host is fixed for intercepted HTTPS
+	Status int         `json:"status,omitempty"` // Response phase or replace (default 200)
+	Header http.Header `json:"header,omitempty"` // Replaces all headers
+
+	// Body replaces the body, base64-encoded if BodyEncoding is "base64"
+	Body         *string `json:"body,omitempty"`
+	BodyEncoding string  `json:"body_encoding,omitempty"`
+}
+
+// breakpointDecision is a validated resolution of a paused flow
+type breakpointDecision struct {
+	action string
+	edit   BreakpointEdit
+	url    *url.URL // Parsed edit.URL (nil if unchanged)
+	body   []byte   // Decoded edit.Body (nil if unchanged)
+}
+
+// pausedEntry is a paused flow and the channel its decision is sent on
+type pausedEntry struct {
+	flow     PausedFlow
+	decision chan breakpointDecision
+}
+
+// Breakpoints pauses flows matching its filters until they are resumed, dropped
+// or replaced through its HTTP API (see ServeHTTP) or the timeout action is taken.
+// A nil *Breakpoints pauses nothing.
+type Breakpoints struct {
+	mu            sync.Mutex
+	filters       []compiledBreakpointFilter
+	nextFilter    int
+	paused        map[string]*pausedEntry
+	nextFlow      uint64
+	timeout       time.Duration
+	timeoutAction string
+	closed        bool
+
+	logger *logger.Logger // Reports paused flows (nil if none)
+	api    *http.ServeMux
+}
+
+// NewBreakpoints creates breakpoints without filters, resuming flows unchanged
+// after DefaultBreakpointTimeout
+func NewBreakpoints() *Breakpoints {
+	b := &Breakpoints{
+		paused:        make(map[string]*pausedEntry),
+		timeout:       DefaultBreakpointTimeout,
+		timeoutAction: BreakpointResume,
+	}
+	b.api = b.newAPI()
+	return b
+}
+
+// SetLogger sets the logger reporting paused flows; it must be called before
+// the breakpoints are in use by a proxy
+func (b *Breakpoints) SetLogger(l *logger.Logger) {
+	b.logger = l
+}
+
+// SetTimeout sets how long flows stay paused and whether they are then resumed
+// unchanged or dropped
+func (b *Breakpoints) SetTimeout(timeout time.Duration, action string) error {
+	if timeout <= 0 {
+		return fmt.Errorf("breakpoint timeout must be positive, got %v", timeout)
+	}
+	if action != BreakpointResume && action != BreakpointDrop {
+		return fmt.Errorf("unknown breakpoint timeout action %q (expected resume or drop)", action)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timeout = timeout
+	b.timeoutAction = action
+	return nil
+}
+
+// AddFilter adds a filter and returns it with its ID
+func (b *Breakpoints) AddFilter(filter BreakpointFilter) (BreakpointFilter, error) {
+	if !strings.Contains(filter.Pattern, "://") {
+		return filter, fmt.Errorf("invalid breakpoint pattern %q: expected scheme://host/path", filter.Pattern)
+	}
+	if !filter.Request && !filter.Response {
+		return filter, fmt.Errorf("breakpoint %q pauses neither requests nor responses", filter.Pattern)
+	}
+
+	compiled := compiledBreakpointFilter{
+		pattern: compileGlob(filter.Pattern),
+		origin:  compileGlob(globOrigin(filter.Pattern)),
+	}
+	if len(filter.Methods) > 0 {
+		compiled.methods = make(map[string]bool)
+		for i, method := range filter.Methods {
+			filter.Methods[i] = strings.ToUpper(method)
+			compiled.methods[filter.Methods[i]] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextFilter++
+	filter.ID = b.nextFilter
+	compiled.BreakpointFilter = filter
+	b.filters = append(b.filters, compiled)
+	return filter, nil
+}
+
+// RemoveFilter removes the filter with the given ID, reporting whether it existed
+// Flows already paused by it stay paused.
+func (b *Breakpoints) RemoveFilter(id int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.filters {
+		if b.filters[i].ID == id {
+			b.filters = append(b.filters[:i], b.filters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Filters returns the filters in the order they were added
+func (b *Breakpoints) Filters() []BreakpointFilter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	filters := make([]BreakpointFilter, len(b.filters))
+	for i := range b.filters {
+		filters[i] = b.filters[i].BreakpointFilter
+	}
+	return filters
+}
+
+// Paused returns the flows currently paused, oldest first
+func (b *Breakpoints) Paused() []PausedFlow {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	flows := make([]PausedFlow, 0, len(b.paused))
+	for _, entry := range b.paused {
+		flows = append(flows, entry.flow)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].Paused.Before(flows[j].Paused)
+	})
+	return flows
+}
+
+// Get returns the paused flow with the given ID
+func (b *Breakpoints) Get(id string) (PausedFlow, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry, ok := b.paused[id]
+	if !ok {
+		return PausedFlow{}, false
+	}
+	return entry.flow, true
+}
+
+// Resolve resumes, drops or replaces the paused flow with the given ID
+// The edit is validated against the flow's phase before the flow is released.
+func (b *Breakpoints) Resolve(id, action string, edit BreakpointEdit) error {
+	b.mu.Lock()
+	entry, ok := b.paused[id]
+	b.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", errNoPausedFlow, id)
+	}
+
+	decision, err := newBreakpointDecision(entry.flow, action, edit)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.paused[id]; !ok {
+		return fmt.Errorf("%w %q", errNoPausedFlow, id)
+	}
+	delete(b.paused, id)
+	entry.decision <- decision
+	return nil
+}
+
+// Close releases all paused flows with the timeout action and stops pausing
+// new ones, so that the proxy can shut down
+func (b *Breakpoints) Close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for id, entry := range b.paused {
+		delete(b.paused, id)
+		entry.decision <- breakpointDecision{action: b.timeoutAction}
+	}
+}
+
+// newBreakpointDecision validates edit as the resolution of flow
+func newBreakpointDecision(flow PausedFlow, action string, edit BreakpointEdit) (breakpointDecision, error) {
+	phase := flow.Phase
+	decision := breakpointDecision{action: action, edit: edit}
+	switch action {
+	case BreakpointResume, BreakpointReplace:
+	case BreakpointDrop:
+		return decision, nil
+	default:
+		return decision, fmt.Errorf("unknown breakpoint action %q (expected resume, drop or replace)", action)
+	}
+
+	if edit.Method != "" || edit.URL != "" {
+		if phase != BreakpointPhaseRequest || action == BreakpointReplace {
+			return decision, fmt.Errorf("method and URL can only be changed when resuming a request")
+		}
+	}
+	if edit.Method != "" && strings.ContainsAny(edit.Method, " \t\r\n") {
+		return decision, fmt.Errorf("invalid method %q", edit.Method)
+	}
+	if edit.URL != "" {
+		u, err := url.Parse(edit.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return decision, fmt.Errorf("invalid URL %q: expected an absolute http or https URL", edit.URL)
+		}
+		// Intercepted HTTPS requests are sent over the connection to their host
+		if original, err := url.Parse(flow.URL); flow.fixedOrigin && (err != nil || !sameOrigin(u, original)) {
+			return decision, fmt.Errorf("the scheme and host of intercepted HTTPS requests cannot be changed")
+		}
+		decision.url = u
+	}
+	if edit.Status != 0 {
+		if phase == BreakpointPhaseRequest && action == BreakpointResume {
+			return decision, fmt.Errorf("status can only be set for responses")
+		}
+		if edit.Status < 100 || edit.Status > 999 {
+			return decision, fmt.Errorf("invalid status %d", edit.Status)
+		}
+	}
+	if edit.Body != nil {
+		if flow.BodyTruncated && action == BreakpointResume {
+			return decision, fmt.Errorf("the body is too large to edit; replace the flow instead")
+		}
+		switch edit.BodyEncoding {
+		case "":
+			decision.body = []byte(*edit.Body)
+		case bodyEncodingBase64:
+			body, err := base64.StdEncoding.DecodeString(*edit.Body)
+			if err != nil {
+				return decision, fmt.Errorf("invalid base64 body: %w", err)
+			}
+			decision.body = body
+		default:
+			return decision, fmt.Errorf("unknown body encoding %q (expected base64 or none)", edit.BodyEncoding)
+		}
+		if decision.body == nil {
+			decision.body = []byte{}
+		}
+	}
+	return decision, nil
+}
+
+// sameOrigin reports whether a and b have the same scheme and host, ignoring default ports
+func sameOrigin(a, b *url.URL) bool {
+	return urlMatchKey(&url.URL{Scheme: a.Scheme, Host: a.Host}) == urlMatchKey(&url.URL{Scheme: b.Scheme, Host: b.Host})
+}
+
+// match reports whether a filter pauses the request to u with method at phase
+func (b *Breakpoints) match(phase, method string, u *url.URL) bool {
+	if b == nil {
+		return false
+	}
+	key := urlMatchKey(u)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	for i := range b.filters {
+		filter := &b.filters[i]
+		if phase == BreakpointPhaseRequest && !filter.Request || phase == BreakpointPhaseResponse && !filter.Response {
+			continue
+		}
+		if filter.methods != nil && !filter.methods[method] {
+			continue
+		}
+		if filter.pattern.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversHost reports whether flows to host (host:port) over scheme may match a
+// filter, so their upstream connection has to be made after any pause
+func (b *Breakpoints) coversHost(scheme, host string) bool {
+	if b == nil {
+		return false
+	}
+
+	key := urlMatchKey(&url.URL{Scheme: scheme, Host: host})
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	for i := range b.filters {
+		if b.filters[i].origin.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// pause holds flow until it is resolved or times out and returns the decision
+func (b *Breakpoints) pause(flow PausedFlow) breakpointDecision {
+	entry := &pausedEntry{decision: make(chan breakpointDecision, 1)}
+
+	b.mu.Lock()
+	b.nextFlow++
+	flow.ID = strconv.FormatUint(b.nextFlow, 10)
+	flow.Paused = time.Now()
+	flow.Deadline = flow.Paused.Add(b.timeout)
+	entry.flow = flow
+	b.paused[flow.ID] = entry
+	timeout, timeoutAction := b.timeout, b.timeoutAction
+	b.mu.Unlock()
+
+	if b.logger != nil {
+		b.logger.LogInfo(fmt.Sprintf("Breakpoint %s: paused %s %s %s (%s after %v)",
+			flow.ID, flow.Phase, flow.Method, flow.URL, timeoutAction, timeout))
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case decision := <-entry.decision:
+		return decision
+	case <-timer.C:
+	}
+
+	// A decision may have been sent just as the timer fired
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.paused[flow.ID]; !ok {
+		return <-entry.decision
+	}
+	delete(b.paused, flow.ID)
+	return breakpointDecision{action: timeoutAction}
+}
+
+// interceptRequest pauses req, to the absolute URL u, if a filter matches and
+// applies the decision to it. It returns the response to answer with instead of
+// forwarding the request, if it was replaced, or whether it was dropped.
+// fixedOrigin rejects edits to the scheme and host of u.
+func (b *Breakpoints) interceptRequest(req *http.Request, u *url.URL, fixedOrigin bool) (*http.Response, bool, error) {
+	if b == nil {
+		return nil, false, nil
+	}
+	if !b.match(BreakpointPhaseRequest, req.Method, u) {
+		return nil, false, nil
+	}
+
+	body, truncated, full, err := peekBody(req.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read request body at breakpoint: %w", err)
+	}
+	if truncated {
+		req.Body = full
+	} else {
+		setRequestBody(req, body)
+	}
+
+	flow := newPausedFlow(BreakpointPhaseRequest, req.Method, u.String(), 0, req.Header, body, truncated)
+	flow.fixedOrigin = fixedOrigin
+	decision := b.pause(flow)
+	switch decision.action {
+	case BreakpointDrop:
+		return nil, true, nil
+	case BreakpointReplace:
+		// The rest of a long body must be consumed before the next request is read
+		if truncated {
+			io.Copy(io.Discard, req.Body)
+			req.Body.Close()
+		}
+		return decision.response(req), false, nil
+	}
+
+	edit := decision.edit
+	if edit.Method != "" {
+		req.Method = edit.Method
+	}
+	if decision.url != nil {
+		req.URL = decision.url
+		req.Host = ""
+	}
+	if edit.Header != nil {
+		req.Header = edit.Header
+	}
+	if decision.body != nil {
+		setRequestBody(req, decision.body)
+	}
+	return nil, false, nil
+}
+
+// interceptResponse pauses resp to req, to the absolute URL u, if a filter
+// matches and applies the decision to it, reporting whether it was dropped
+func (b *Breakpoints) interceptResponse(resp *http.Response, req *http.Request, u *url.URL) (bool, error) {
+	if b == nil {
+		return false, nil
+	}
+	if !b.match(BreakpointPhaseResponse, req.Method, u) {
+		return false, nil
+	}
+
+	body, truncated, full, err := peekBody(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read response body at breakpoint: %w", err)
+	}
+	resp.Body = full
+
+	decision := b.pause(newPausedFlow(BreakpointPhaseResponse, req.Method, u.String(), resp.StatusCode, resp.Header, body, truncated))
+	if decision.action == BreakpointDrop {
+		return true, nil
+	}
+
+	// A replacement keeps nothing of the upstream response. Closing the body of
+	// a response read from a connection that is reused drains it first.
+	if decision.action == BreakpointReplace {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		replacement := decision.response(req)
+		replacement.Close = resp.Close
+		*resp = *replacement
+		return false, nil
+	}
+
+	edit := decision.edit
+	if edit.Status != 0 {
+		resp.StatusCode = edit.Status
+		resp.Status = fmt.Sprintf("%d %s", edit.Status, http.StatusText(edit.Status))
+	}
+	if edit.Header != nil {
+		resp.Header = edit.Header
+	}
+	if decision.body != nil {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(decision.body))
+		resp.ContentLength = int64(len(decision.body))
+		resp.TransferEncoding = nil
+		resp.Header.Set("Content-Length", strconv.Itoa(len(decision.body)))
+	}
+	return false, nil
+}
+
+// response returns the replacement response to req described by the decision
+func (d breakpointDecision) response(req *http.Request) *http.Response {
+	status := d.edit.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := d.edit.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	body := d.body
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// newPausedFlow describes a flow paused at phase; truncated marks a body cut
+// short at maxBreakpointBodySize
+func newPausedFlow(phase, method, rawURL string, status int, header http.Header, body []byte, truncated bool) PausedFlow {
+	flow := PausedFlow{
+		Phase:         phase,
+		Method:        method,
+		URL:           rawURL,
+		Status:        status,
+		Header:        header.Clone(),
+		Body:          string(body),
+		BodyTruncated: truncated,
+	}
+	if !utf8.Valid(body) {
+		flow.Body = base64.StdEncoding.EncodeToString(body)
+		flow.BodyEncoding = bodyEncodingBase64
+	}
+	return flow
+}
+
+// peekBody reads body, which may be nil, up to maxBreakpointBodySize bytes. It
+// returns the bytes read, whether body is longer, and the body to send on in
+// its place, which for long bodies continues with the part not yet read.
+func peekBody(body io.ReadCloser) ([]byte, bool, io.ReadCloser, error) {
+	if body == nil || body == http.NoBody {
+		return nil, false, body, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBreakpointBodySize+1))
+	if err != nil {
+		body.Close()
+		return nil, false, nil, err
+	}
+	if len(data) <= maxBreakpointBodySize {
+		body.Close()
+		return data, false, io.NopCloser(bytes.NewReader(data)), nil
+	}
+	full := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), body), body}
+	return data[:maxBreakpointBodySize], true, full, nil
+}
+
+// setRequestBody replaces the body of req, sent with a Content-Length
+func setRequestBody(req *http.Request, body []byte) {
+	req.Body = http.NoBody
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	req.ContentLength = int64(len(body))
+	req.TransferEncoding = nil
+}
+
+// SetBreakpoints pauses flows matching the filters of breakpoints (nil disables it)
+func (p *ProxyServer) SetBreakpoints(breakpoints *Breakpoints) {
+	p.breakpoints = breakpoints
+	if p.mitmHandler != nil {
+		p.mitmHandler.SetBreakpoints(breakpoints)
+	}
+}
+
+// SetBreakpoints pauses intercepted flows matching the filters of breakpoints
+// (nil disables it). Connections to hosts that filters may match are made per
+// request, so a pause cannot outlast an idle upstream connection.
+func (m *MITMHandler) SetBreakpoints(breakpoints *Breakpoints) {
+	m.breakpoints = breakpoints
+}
+
+// breakRequest pauses req at a matching request breakpoint. It reports whether
+// the exchange ended there, dropped or answered with a replacement, and if so
+// whether the client connection can carry further requests; span is then ended.
+func (m *MITMHandler) breakRequest(clientConn net.Conn, req *http.Request, hostname string, conn *Flow, start time.Time, timings logger.Timings, span *tracing.Span) (done, reuse bool) {
+	u := &url.URL{Scheme: "https", Host: conn.Host, Path: req.URL.Path, RawPath: req.URL.RawPath, RawQuery: req.URL.RawQuery}
+	replacement, drop, err := m.breakpoints.interceptRequest(req, u, true)
+	switch {
+	case err != nil:
+		m.logger.LogError(fmt.Sprintf("breakpoint failed for %s", hostname), err)
+		endRequestSpan(span, 0, err)
+		return true, false
+	case drop:
+		m.logger.LogInfo(fmt.Sprintf("Dropped request %s %s at breakpoint", req.Method, u))
+		endRequestSpan(span, 0, errBreakpointDrop)
+		return true, false
+	case replacement == nil:
+		return false, false
+	}
+
+	body := &countingReader{ReadCloser: replacement.Body}
+	replacement.Body = body
+	transferStart := time.Now()
+	err = replacement.Write(clientConn)
+	timings.BodyTransfer = time.Since(transferStart)
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to write replacement response to client for %s", hostname), err)
+	}
+
+	m.completeFlow(conn, hostname, req, replacement.StatusCode, timings)
+	m.logAccess(conn, req, replacement.StatusCode, body.n, start, timings)
+	endRequestSpan(span, replacement.StatusCode, err)
+	m.metrics.observeExchange("https", req.Method, replacement.StatusCode, 0, body.n)
+	return true, err == nil
+}
+
+// breakResponse pauses resp to req at a matching response breakpoint and
+// reports whether it was dropped; span is then ended
+func (m *MITMHandler) breakResponse(resp *http.Response, req *http.Request, hostname string, conn *Flow, span *tracing.Span) bool {
+	u := &url.URL{Scheme: "https", Host: conn.Host, Path: req.URL.Path, RawPath: req.URL.RawPath, RawQuery: req.URL.RawQuery}
+	drop, err := m.breakpoints.interceptResponse(resp, req, u)
+	switch {
+	case err != nil:
+		m.logger.LogError(fmt.Sprintf("breakpoint failed for %s", hostname), err)
+	case drop:
+		m.logger.LogInfo(fmt.Sprintf("Dropped response to %s %s at breakpoint", req.Method, u))
+		err = errBreakpointDrop
+	default:
+		return false
+	}
+	endRequestSpan(span, resp.StatusCode, err)
+	return true
+}
+
+// breakHTTPRequest pauses a plain HTTP request at a matching request breakpoint
+// and reports whether the exchange ended there, dropped or answered with a
+// replacement
+func breakHTTPRequest(w http.ResponseWriter, r *http.Request, log *logger.Logger, breakpoints *Breakpoints) bool {
+	hostname := getHostname(r)
+	if breakpoints.match(BreakpointPhaseRequest, r.Method, r.URL) {
+		clearDeadlines(w)
+	}
+	replacement, drop, err := breakpoints.interceptRequest(r, r.URL, false)
+	switch {
+	case err != nil:
+		log.LogError(fmt.Sprintf("breakpoint failed for %s", hostname), err)
+		http.Error(w, "Bad Request: failed to read request body", http.StatusBadRequest)
+		return true
+	case drop:
+		log.LogInfo(fmt.Sprintf("Dropped request %s %s at breakpoint", r.Method, r.URL))
+		dropConnection(w)
+		return true
+	case replacement == nil:
+		return false
+	}
+
+	copyHeaders(w.Header(), replacement.Header)
+	w.WriteHeader(replacement.StatusCode)
+	if _, err := io.Copy(w, replacement.Body); err != nil {
+		log.LogError(fmt.Sprintf("failed to write replacement response to client for %s", hostname), err)
+	}
+	log.LogRequest(hostname, replacement.StatusCode)
+	return true
+}
+
+// clearDeadlines lifts the server's read and write timeouts for the exchange on
+// w, which would otherwise end it while it is paused at a breakpoint
+func clearDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
+
+// dropConnection closes the client connection of w without a response
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// Aborting the handler makes the server close the connection
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/yourusername/go-mitmproxy/pkg/pkcs12"
+)
+
+// ClientCertificates selects the client certificate presented to upstream servers
+// that request one (mutual TLS, RFC 8446 Section 4.3.2)
+// Patterns are exact hostnames, "*.example.com" for any subdomain of example.com,
+// or "*" for every host; the most specific pattern wins.
+type ClientCertificates struct {
+	hosts hostMatcher[*tls.Certificate]
+}
+
+// NewClientCertificates creates an empty client certificate table
+func NewClientCertificates() *ClientCertificates {
+	return &ClientCertificates{}
+}
+
+// Add configures cert for hosts matching pattern
+// Returns an error if the pattern is malformed or already configured.
+func (c *ClientCertificates) Add(pattern string, cert *tls.Certificate) error {
+	if err := c.hosts.add(pattern, cert); err != nil {
+		return fmt.Errorf("client certificate: %w", err)
+	}
+	return nil
+}
+
+// ForHost returns the client certificate for host (without port), or nil
+func (c *ClientCertificates) ForHost(host string) *tls.Certificate {
+	return c.hosts.get(host)
+}
+
+// LoadClientCertificate loads a PEM certificate chain and private key
+// keyPath may be empty when the key is in the same file as the certificates.
+func LoadClientCertificate(certPath, keyPath string) (*tls.Certificate, error) {
+	if keyPath == "" {
+		keyPath = certPath
+	}
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate %s: %w", certPath, err)
+	}
+	return &cert, nil
+}
+
+// LoadClientCertificatePKCS12 loads a certificate, its chain and private key
+// from a PKCS#12 (.p12/.pfx) file
+func LoadClientCertificatePKCS12(path, password string) (*tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate %s: %w", path, err)
+	}
+
+	key, leaf, chain, err := pkcs12.Decode(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode client certificate %s: %w", path, err)
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range chain {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+	}
+	return cert, nil
+}
+
+// certificateSubject returns the subject of the leaf in cert, parsing it if needed
+func certificateSubject(cert *tls.Certificate) string {
+	if cert.Leaf != nil {
+		return cert.Leaf.Subject.String()
+	}
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return ""
+	}
+	return leaf.Subject.String()
+}
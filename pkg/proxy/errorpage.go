@@ -0,0 +1,310 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
+)
+
+const (
+	// StatusUpstreamCertificateError reports an upstream server certificate that
+	// failed verification (nginx's non-standard "495 SSL Certificate Error")
+	StatusUpstreamCertificateError = 495
+
+	// Time allowed for the client to send its request before an error page
+	errorPageReadTimeout = 10 * time.Second
+)
+
+// ErrorPage describes why the proxy answered a request itself instead of
+// relaying it upstream. It is the data passed to error page templates.
+type ErrorPage struct {
+	StatusCode int                    `json:"status_code"`
+	Status     string                 `json:"status"`  // Reason phrase, e.g. "Bad Gateway"
+	Title      string                 `json:"title"`   // Short description of the failure
+	Message    string                 `json:"message"` // One-sentence explanation for the user
+	Host       string                 `json:"host"`    // Upstream host:port
+	Error      string                 `json:"error"`   // Underlying error text
+	Chain      []ErrorPageCertificate `json:"chain,omitempty"`
+}
+
+// ErrorPageCertificate summarises one upstream certificate on an error page
+type ErrorPageCertificate struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	DNSNames    []string  `json:"dns_names,omitempty"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"sha256_fingerprint"`
+	Pin         string    `json:"spki_pin"`
+}
+
+// ErrorPages renders error pages as HTML, or as JSON for clients that ask for it
+type ErrorPages struct {
+	html *htmltemplate.Template
+	json *texttemplate.Template // nil renders the ErrorPage as JSON directly
+}
+
+// NewErrorPages creates error pages using the built-in templates
+func NewErrorPages() *ErrorPages {
+	return &ErrorPages{html: defaultErrorTemplate}
+}
+
+// LoadHTMLTemplate replaces the HTML error page with the html/template in path
+// The template is executed with an *ErrorPage.
+func (e *ErrorPages) LoadHTMLTemplate(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read error template: %w", err)
+	}
+	tmpl, err := htmltemplate.New("error").Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse error template %s: %w", path, err)
+	}
+	e.html = tmpl
+	return nil
+}
+
+// LoadJSONTemplate replaces the JSON error body with the text/template in path
+// The template is executed with an *ErrorPage; the "json" function encodes a value.
+func (e *ErrorPages) LoadJSONTemplate(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read JSON error template: %w", err)
+	}
+	funcs := texttemplate.FuncMap{"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	}}
+	tmpl, err := texttemplate.New("error").Funcs(funcs).Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse JSON error template %s: %w", path, err)
+	}
+	e.json = tmpl
+	return nil
+}
+
+// render returns the content type and body of page
+func (e *ErrorPages) render(page *ErrorPage, asJSON bool) (string, []byte, error) {
+	var buf bytes.Buffer
+	switch {
+	case asJSON && e.json != nil:
+		if err := e.json.Execute(&buf, page); err != nil {
+			return "", nil, err
+		}
+	case asJSON:
+		if err := json.NewEncoder(&buf).Encode(page); err != nil {
+			return "", nil, err
+		}
+	default:
+		if err := e.html.Execute(&buf, page); err != nil {
+			return "", nil, err
+		}
+		return "text/html; charset=utf-8", buf.Bytes(), nil
+	}
+	return "application/json", buf.Bytes(), nil
+}
+
+// SetErrorPages sets the pages served when the upstream cannot be reached
+func (m *MITMHandler) SetErrorPages(pages *ErrorPages) {
+	m.errorPages = pages
+}
+
+// newUpstreamErrorPage classifies an upstream dial error for host (host:port)
+func newUpstreamErrorPage(host string, err error) *ErrorPage {
+	page := &ErrorPage{
+		StatusCode: http.StatusBadGateway,
+		Title:      "Upstream connection failed",
+		Message:    fmt.Sprintf("The proxy could not connect to %s.", host),
+		Host:       host,
+		Error:      err.Error(),
+	}
+
+	var certErr *UpstreamCertificateError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var alert tls.AlertError
+	var recordErr tls.RecordHeaderError
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &certErr):
+		page.StatusCode = StatusUpstreamCertificateError
+		page.Title = "Upstream certificate rejected"
+		page.Message = fmt.Sprintf("The certificate presented by %s could not be verified.", host)
+		for _, cert := range certErr.Verification.Chain {
+			page.Chain = append(page.Chain, summarizeCertificate(cert))
+		}
+	case errors.As(err, &dnsErr):
+		page.Title = "Host not found"
+		page.Message = fmt.Sprintf("The proxy could not resolve %s.", dnsErr.Name)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		page.StatusCode = http.StatusGatewayTimeout
+		page.Title = "Upstream timed out"
+		page.Message = fmt.Sprintf("%s did not respond within %s.", host, upstreamDialTimeout)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		page.Title = "Connection refused"
+		page.Message = fmt.Sprintf("%s refused the connection.", host)
+	case errors.As(err, &alert), errors.As(err, &recordErr),
+		errors.As(err, &opErr) && opErr.Op == "remote error": // TLS alert from the server
+		page.Title = "TLS handshake failed"
+		page.Message = fmt.Sprintf("The TLS handshake with %s failed.", host)
+	}
+
+	page.Status = statusText(page.StatusCode)
+	return page
+}
+
+// summarizeCertificate describes cert for an error page
+func summarizeCertificate(cert *x509.Certificate) ErrorPageCertificate {
+	return ErrorPageCertificate{
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		DNSNames:    cert.DNSNames,
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+		Fingerprint: ca.Fingerprint(cert),
+		Pin:         SPKIPin(cert),
+	}
+}
+
+// statusText returns the reason phrase for code, including non-standard codes
+func statusText(code int) string {
+	if code == StatusUpstreamCertificateError {
+		return "SSL Certificate Error"
+	}
+	return http.StatusText(code)
+}
+
+// wantsJSON reports whether the Accept header lists JSON before HTML
+func wantsJSON(req *http.Request) bool {
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			return true
+		case mediaType == "text/html":
+			return false
+		}
+	}
+	return false
+}
+
+// serveErrorPage reads the client's request from the intercepted connection and
+// answers it with an error page for dialErr, since the upstream is unreachable
+// after "200 Connection Established" has already been sent.
+func (m *MITMHandler) serveErrorPage(clientConn *tls.Conn, hostname string, dialErr error, conn *Flow) {
+	clientConn.SetReadDeadline(time.Now().Add(errorPageReadTimeout))
+	req, err := http.ReadRequest(bufio.NewReader(clientConn))
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to read HTTPS request from client for %s", hostname), err)
+		return
+	}
+	clientConn.SetReadDeadline(time.Time{})
+	start := time.Now()
+	span := startRequestSpan(m.tracer, req, "https", conn.Host, start)
+	traceConnection(span, conn, dialErr)
+
+	// Map local rules work without the upstream
+	if handled, _ := m.serveLocal(clientConn, req, hostname, conn, start, conn.Timings, span); handled {
+		return
+	}
+
+	// The failed dial's phases are kept; nothing was sent upstream
+	m.writeErrorPage(clientConn, req, hostname, dialErr, conn, start, conn.Timings, span)
+}
+
+// writeErrorPage answers req on clientConn with the error page for a failed
+// connection to hostname and closes the exchange; span is ended
+func (m *MITMHandler) writeErrorPage(clientConn net.Conn, req *http.Request, hostname string, dialErr error, conn *Flow, start time.Time, timings logger.Timings, span *tracing.Span) {
+	page := newUpstreamErrorPage(hostname, dialErr)
+	contentType, body, err := m.errorPages.render(page, wantsJSON(req))
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to render error page for %s", hostname), err)
+		contentType, body = "text/plain; charset=utf-8", []byte(page.Status+"\n")
+	}
+
+	resp := &http.Response{
+		StatusCode:    page.StatusCode,
+		Status:        fmt.Sprintf("%d %s", page.StatusCode, page.Status),
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Close:         true,
+		Request:       req,
+	}
+	resp.Header.Set("Content-Type", contentType)
+	resp.Header.Set("Cache-Control", "no-store")
+	m.headerRules.ApplyResponse(resp.Header, req, hostname)
+
+	flow := *conn
+	flow.Error = page.Error
+
+	writeStart := time.Now()
+	if err := resp.Write(clientConn); err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to write error page to client for %s", hostname), err)
+	}
+	timings.BodyTransfer = time.Since(writeStart)
+	m.completeFlow(&flow, hostname, req, page.StatusCode, timings)
+	m.logAccess(&flow, req, page.StatusCode, int64(len(body)), start, timings)
+	endRequestSpan(span, page.StatusCode, dialErr)
+	m.metrics.observeExchange("https", req.Method, page.StatusCode, 0, int64(len(body)))
+}
+
+// defaultErrorTemplate is the built-in HTML error page
+var defaultErrorTemplate = htmltemplate.Must(htmltemplate.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.StatusCode}} {{.Status}} - GoSniffer</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+code { background: #f3f3f3; padding: 0 .25em; word-break: break-all; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td { border: 1px solid #ddd; padding: .25em .5em; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+<p>Error: <code>{{.Error}}</code></p>
+{{if .Chain}}
+<h2>Certificate chain presented by {{.Host}}</h2>
+{{range $i, $cert := .Chain}}
+<table>
+<tr><td>#{{$i}} Subject</td><td>{{$cert.Subject}}</td></tr>
+<tr><td>Issuer</td><td>{{$cert.Issuer}}</td></tr>
+{{if $cert.DNSNames}}<tr><td>DNS names</td><td>{{range $j, $name := $cert.DNSNames}}{{if $j}}, {{end}}{{$name}}{{end}}</td></tr>{{end}}
+<tr><td>Valid</td><td>{{$cert.NotBefore.Format "2006-01-02"}} to {{$cert.NotAfter.Format "2006-01-02"}}</td></tr>
+<tr><td>SHA-256</td><td><code>{{$cert.Fingerprint}}</code></td></tr>
+<tr><td>SPKI pin</td><td><code>{{$cert.Pin}}</code></td></tr>
+</table>
+{{end}}
+{{end}}
+<p><small>{{.StatusCode}} {{.Status}} &middot; GoSniffer</small></p>
+</body>
+</html>
+`))
@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TLS extension IDs used by the fingerprints (IANA TLS ExtensionType registry)
+const (
+	extensionServerName        = 0x0000 // RFC 6066
+	extensionALPN              = 0x0010 // RFC 7301
+	extensionSupportedVersions = 0x002b // RFC 8446 Section 4.2.1
+)
+
+// ClientHello holds the fields of a client's TLS ClientHello used for fingerprinting
+// Values are kept in the order the client sent them, including GREASE values
+// (RFC 8701), which the fingerprints ignore.
+type ClientHello struct {
+	Version           uint16 // legacy_version (0x0303 when supported_versions is sent)
+	CipherSuites      []uint16
+	Extensions        []uint16
+	Curves            []tls.CurveID
+	PointFormats      []uint8
+	SignatureSchemes  []tls.SignatureScheme
+	ALPN              []string
+	ServerName        string
+	SupportedVersions []uint16
+}
+
+// NewClientHello captures the fingerprinted fields of a ClientHello
+func NewClientHello(info *tls.ClientHelloInfo) *ClientHello {
+	hello := &ClientHello{
+		CipherSuites:      info.CipherSuites,
+		Extensions:        info.Extensions,
+		Curves:            info.SupportedCurves,
+		PointFormats:      info.SupportedPoints,
+		SignatureSchemes:  info.SignatureSchemes,
+		ALPN:              info.SupportedProtos,
+		ServerName:        info.ServerName,
+		SupportedVersions: info.SupportedVersions,
+	}
+
+	// crypto/tls does not expose legacy_version: RFC 8446 fixes it at TLS 1.2 when
+	// supported_versions is present, otherwise SupportedVersions is derived from it
+	if slices.Contains(hello.Extensions, extensionSupportedVersions) {
+		hello.Version = tls.VersionTLS12
+	} else {
+		for _, v := range hello.SupportedVersions {
+			hello.Version = max(hello.Version, v)
+		}
+	}
+	return hello
+}
+
+// isGREASE reports whether v is a GREASE value (RFC 8701 Section 2)
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// withoutGREASE returns values as uint16s, dropping GREASE values
+func withoutGREASE[T ~uint16](values []T) []uint16 {
+	out := make([]uint16, 0, len(values))
+	for _, v := range values {
+		if !isGREASE(uint16(v)) {
+			out = append(out, uint16(v))
+		}
+	}
+	return out
+}
+
+// JA3String returns the JA3 fingerprint string:
+// SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats
+func (h *ClientHello) JA3String() string {
+	points := make([]uint16, len(h.PointFormats))
+	for i, p := range h.PointFormats {
+		points[i] = uint16(p)
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(h.Version)),
+		joinDecimal(withoutGREASE(h.CipherSuites)),
+		joinDecimal(withoutGREASE(h.Extensions)),
+		joinDecimal(withoutGREASE(h.Curves)),
+		joinDecimal(points),
+	}, ",")
+}
+
+// JA3 returns the JA3 fingerprint: the MD5 hash of JA3String in hex
+func (h *ClientHello) JA3() string {
+	hash := md5.Sum([]byte(h.JA3String()))
+	return hex.EncodeToString(hash[:])
+}
+
+// JA4 returns the JA4 fingerprint (JA4_a_JA4_b_JA4_c) of a ClientHello received over TCP
+func (h *ClientHello) JA4() string {
+	ciphers := withoutGREASE(h.CipherSuites)
+	extensions := withoutGREASE(h.Extensions)
+
+	// JA4_a: protocol, version, SNI, cipher and extension counts, ALPN
+	sni := "i"
+	if slices.Contains(extensions, extensionServerName) {
+		sni = "d"
+	}
+	a := fmt.Sprintf("t%s%s%02d%02d%s", h.ja4Version(), sni,
+		min(len(ciphers), 99), min(len(extensions), 99), ja4ALPN(h.ALPN))
+
+	// JA4_b: sorted cipher suites
+	slices.Sort(ciphers)
+	b := ja4Hash(joinHex(ciphers))
+
+	// JA4_c: sorted extensions without SNI and ALPN, then signature algorithms as sent
+	var sorted []uint16
+	for _, ext := range extensions {
+		if ext != extensionServerName && ext != extensionALPN {
+			sorted = append(sorted, ext)
+		}
+	}
+	slices.Sort(sorted)
+	c := joinHex(sorted)
+	if sigs := withoutGREASE(h.SignatureSchemes); len(sigs) > 0 {
+		c += "_" + joinHex(sigs)
+	}
+	if len(sorted) == 0 {
+		c = ""
+	}
+
+	return a + "_" + b + "_" + ja4Hash(c)
+}
+
+// ja4Version returns the highest TLS version offered, as used in JA4_a
+func (h *ClientHello) ja4Version() string {
+	version := h.Version
+	if slices.Contains(h.Extensions, extensionSupportedVersions) {
+		version = 0
+		for _, v := range withoutGREASE(h.SupportedVersions) {
+			version = max(version, v)
+		}
+	}
+	switch version {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	}
+	return "00"
+}
+
+// ja4ALPN returns the first and last characters of the first ALPN protocol,
+// or of its hex encoding if either is not alphanumeric; "00" without ALPN
+func ja4ALPN(protos []string) string {
+	if len(protos) == 0 || protos[0] == "" {
+		return "00"
+	}
+	p := protos[0]
+	first, last := p[0], p[len(p)-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+	encoded := hex.EncodeToString([]byte(p))
+	return string([]byte{encoded[0], encoded[len(encoded)-1]})
+}
+
+// isAlphanumeric reports whether c is an ASCII letter or digit
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// ja4Hash returns the first 12 hex characters of the SHA-256 of s,
+// or all zeros if s is empty
+func ja4Hash(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+// joinDecimal joins values as decimal numbers separated by "-"
+func joinDecimal(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(parts, "-")
+}
+
+// joinHex joins values as 4-digit lowercase hex separated by ","
+func joinHex(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%04x", v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// FingerprintAction decides how a connection matching a fingerprint rule is handled
+type FingerprintAction string
+
+const (
+	// FingerprintIntercept intercepts the connection as usual
+	FingerprintIntercept FingerprintAction = "intercept"
+
+	// FingerprintTunnel relays the connection to the upstream without interception,
+	// for clients that pin certificates
+	FingerprintTunnel FingerprintAction = "tunnel"
+
+	// FingerprintBlock aborts the TLS handshake
+	FingerprintBlock FingerprintAction = "block"
+)
+
+// Fingerprint rule fields
+const (
+	FingerprintFieldJA3 = "ja3"
+	FingerprintFieldJA4 = "ja4"
+)
+
+var (
+	errFingerprintBlocked = errors.New("client blocked by fingerprint rule")
+	errFingerprintTunnel  = errors.New("client tunnelled by fingerprint rule")
+)
+
+// FingerprintRule applies an action to clients whose fingerprint matches a pattern
+// Patterns use path.Match syntax, e.g. "t13d*_8daaf6152771_*" for JA4.
+type FingerprintRule struct {
+	Field   string // FingerprintFieldJA3 (the MD5 hash) or FingerprintFieldJA4
+	Pattern string
+	Action  FingerprintAction
+}
+
+// FingerprintRules is an ordered list of fingerprint rules; the first match wins
+type FingerprintRules struct {
+	rules []FingerprintRule
+}
+
+// NewFingerprintRules creates an empty rule list
+func NewFingerprintRules() *FingerprintRules {
+	return &FingerprintRules{}
+}
+
+// Add appends a rule
+// Returns an error if the field, pattern or action is invalid.
+func (r *FingerprintRules) Add(field, pattern string, action FingerprintAction) error {
+	field = strings.ToLower(field)
+	if field != FingerprintFieldJA3 && field != FingerprintFieldJA4 {
+		return fmt.Errorf("unknown fingerprint field %q (expected ja3 or ja4)", field)
+	}
+	if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+		return fmt.Errorf("invalid fingerprint pattern %q", pattern)
+	}
+	switch action {
+	case FingerprintIntercept, FingerprintTunnel, FingerprintBlock:
+	default:
+		return fmt.Errorf("unknown fingerprint action %q (expected intercept, tunnel or block)", action)
+	}
+
+	r.rules = append(r.rules, FingerprintRule{Field: field, Pattern: pattern, Action: action})
+	return nil
+}
+
+// Match returns the action of the first rule matching the fingerprints
+func (r *FingerprintRules) Match(ja3, ja4 string) (FingerprintAction, bool) {
+	for _, rule := range r.rules {
+		value := ja3
+		if rule.Field == FingerprintFieldJA4 {
+			value = ja4
+		}
+		if matched, _ := path.Match(rule.Pattern, value); matched {
+			return rule.Action, true
+		}
+	}
+	return FingerprintIntercept, false
+}
+
+// SetFingerprintRules sets the rules applied to client TLS fingerprints
+func (m *MITMHandler) SetFingerprintRules(rules *FingerprintRules) {
+	m.fingerprintRules = rules
+}
+
+// helloRecorder records the bytes read from a client connection during the TLS
+// handshake, so that a connection routed by its ClientHello can still be relayed
+// upstream unmodified
+type helloRecorder struct {
+	net.Conn
+	recorded  bytes.Buffer
+	recording bool
+	tunnel    bool // Drop writes (the handshake alert) once routed to a tunnel
+}
+
+// newHelloRecorder starts recording reads from conn
+func newHelloRecorder(conn net.Conn) *helloRecorder {
+	return &helloRecorder{Conn: conn, recording: true}
+}
+
+// Read reads from the connection, recording the data until recording stops
+func (h *helloRecorder) Read(p []byte) (int, error) {
+	n, err := h.Conn.Read(p)
+	if h.recording {
+		h.recorded.Write(p[:n])
+	}
+	return n, err
+}
+
+// Write writes to the connection unless it is being handed to a tunnel
+func (h *helloRecorder) Write(p []byte) (int, error) {
+	if h.tunnel {
+		return len(p), nil
+	}
+	return h.Conn.Write(p)
+}
+
+// stopRecording discards the recorded bytes; the connection is intercepted
+func (h *helloRecorder) stopRecording() {
+	h.recording = false
+	h.recorded = bytes.Buffer{}
+}
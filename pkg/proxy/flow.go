@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// Flow records one intercepted HTTPS request/response exchange together with
+// details of the TLS connections it was carried on
+type Flow struct {
+	ClientAddr string        // Remote address of the proxy client
+	Host       string        // CONNECT target (host:port)
+	Request    *http.Request // Request as forwarded upstream
+	StatusCode int           // Upstream response status
+
+	// ClientCertSubject is the subject of the certificate the client presented to
+	// the proxy (see SetRequestClientCert); empty if none. It is not verified.
+	ClientCertSubject string
+
+	// UpstreamClientCertSubject is the subject of the client certificate the proxy
+	// presented to the upstream server; empty if the server did not ask for one
+	UpstreamClientCertSubject string
+
+	// UpstreamTLS records the upstream certificate chain and how it was verified;
+	// nil for hosts covered by map local or map remote rules, whose upstream varies per request
+	UpstreamTLS *UpstreamVerification
+
+	// ClientHello and its JA3 (MD5) and JA4 fingerprints identify the client's TLS stack
+	ClientHello *ClientHello
+	JA3         string
+	JA4         string
+
+	// Negotiated TLS versions with the client and the upstream server
+	ClientTLSVersion   uint16
+	UpstreamTLSVersion uint16
+
+	// Timings breaks down the duration of the exchange; connection phases are
+	// only set for the first exchange on a connection
+	Timings logger.Timings
+
+	// Error explains why the proxy answered with an error page instead of
+	// relaying the request upstream; empty for relayed exchanges
+	Error string
+
+	// LocalFile is the file a map local rule answered the request with instead
+	// of the upstream; empty for relayed exchanges
+	LocalFile string
+
+	// MappedURL is the URL a map remote rule sent the request to instead of the
+	// CONNECT target; empty for unmapped exchanges
+	MappedURL string
+
+	// When the client handshake and upstream connect began and the upstream
+	// connect ended, for tracing the connection
+	clientTLSStart time.Time
+	upstreamStart  time.Time
+	upstreamEnd    time.Time
+}
+
+// FlowHandler is called once for every completed flow
+// It runs on the connection's goroutine, so it must not block for long.
+type FlowHandler func(*Flow)
+
+// SetFlowHandler registers a function called for every completed flow
+func (m *MITMHandler) SetFlowHandler(handler FlowHandler) {
+	m.flowHandler = handler
+}
+
+// completeFlow logs an exchange on conn with the client's TLS fingerprints and
+// timings and passes it to the flow handler
+func (m *MITMHandler) completeFlow(conn *Flow, hostname string, req *http.Request, statusCode int, timings logger.Timings) {
+	m.logger.LogTLSRequest(hostname, statusCode, conn.JA3, conn.JA4)
+	m.logger.Debug("exchange timings", append([]interface{}{"host", hostname}, timings.KeyValues()...)...)
+	m.emitFlow(conn, req, statusCode, timings)
+}
+
+// emitFlow passes a completed exchange on conn to the flow handler, if any
+func (m *MITMHandler) emitFlow(conn *Flow, req *http.Request, statusCode int, timings logger.Timings) {
+	if m.flowHandler == nil {
+		return
+	}
+
+	flow := *conn
+	flow.Request = req
+	flow.StatusCode = statusCode
+	flow.Timings = timings
+	m.flowHandler(&flow)
+}
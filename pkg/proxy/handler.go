@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
 
 	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
 )
 
 const (
@@ -14,6 +18,18 @@ const (
 	ProxyHeaderValue = "GoSniffer"
 )
 
+// defaultHeaderRules are the header rules of HandleHTTPRequest
+var defaultHeaderRules = DefaultHeaderRules()
+
+// forwardOptions configures how plain HTTP requests are forwarded
+type forwardOptions struct {
+	tracer      *tracing.Tracer // Trace context and spans (nil if disabled)
+	headerRules *HeaderRules    // Header rewriting (nil rewrites nothing)
+	mapLocal    *MapLocal       // Requests answered from local files (nil if disabled)
+	mapRemote   *MapRemote      // Requests sent to other upstreams (nil if disabled)
+	breakpoints *Breakpoints    // Flows paused for editing (nil if disabled)
+}
+
 // HandleHTTPRequest handles HTTP proxy requests (not HTTPS CONNECT)
 // Implements:
 // - FR-002: Intercept HTTP requests and forward to upstream
@@ -21,33 +37,83 @@ const (
 // - FR-007: Inject custom header
 // Constitution Principle II: Rigorous error handling for all network operations
 func HandleHTTPRequest(w http.ResponseWriter, r *http.Request, log *logger.Logger) {
+	handleHTTPRequest(w, r, log, forwardOptions{headerRules: defaultHeaderRules})
+}
+
+// handleHTTPRequest forwards a plain HTTP request as configured by opts and
+// returns where its time went
+func handleHTTPRequest(w http.ResponseWriter, r *http.Request, log *logger.Logger, opts forwardOptions) logger.Timings {
 	// Extract hostname from request
 	hostname := getHostname(r)
 
 	// Note: CONNECT method is handled by MITMHandler at proxy level
 	// This function only handles regular HTTP methods (GET, POST, etc.)
 
-	// Inject custom header into request (FR-007)
-	r.Header.Set(ProxyHeaderName, ProxyHeaderValue)
+	// Answer from a local file instead of the upstream if a map local rule matches
+	if name, ok := opts.mapLocal.Match(r.URL); ok {
+		return serveLocalHTTP(w, r, log, opts.headerRules, name)
+	}
 
 	// Remove hop-by-hop headers (per HTTP proxy spec RFC 2616)
 	removeHopByHopHeaders(r.Header)
 
+	// Rewrite headers; the default rule injects the custom header (FR-007)
+	opts.headerRules.ApplyRequest(r, hostname)
+
+	// Pause at a matching breakpoint; the exchange may end there
+	if breakHTTPRequest(w, r, log, opts.breakpoints) {
+		return logger.Timings{}
+	}
+
+	// Send the request elsewhere if a map remote rule matches
+	upstreamURL := r.URL
+	if mapped, ok := opts.mapRemote.Match(r.URL); ok {
+		log.Debug("mapped request", "host", hostname, "from", r.URL.String(), "to", mapped.String())
+		upstreamURL = mapped
+	}
+
 	// Forward request to upstream server using http.DefaultTransport
-	statusCode, err := forwardRequest(w, r)
+	span := startRequestSpan(opts.tracer, r, "http", hostname, time.Now())
+	timer := &phaseTimer{}
+	statusCode, err := forwardRequest(w, r, upstreamURL, timer, opts)
+	timings := timer.result()
+	traceUpstreamDial(span, timer, upstreamURL.Host)
+	endRequestSpan(span, statusCode, err)
 	if err != nil {
 		// Log error with context (constitution Principle II)
 		log.LogError(fmt.Sprintf("forwarding request to %s", hostname), err)
-		return
+		return timings
 	}
 
 	// Log successful request with hostname and status code (FR-006)
 	log.LogRequest(hostname, statusCode)
+	log.Debug("exchange timings", append([]interface{}{"host", hostname}, timings.KeyValues()...)...)
+	return timings
 }
 
-// forwardRequest forwards the HTTP request to the upstream server and relays the response
-// Returns the HTTP status code and any error encountered
-func forwardRequest(w http.ResponseWriter, r *http.Request) (int, error) {
+// serveLocalHTTP answers a plain HTTP request with the local file name
+func serveLocalHTTP(w http.ResponseWriter, r *http.Request, log *logger.Logger, rules *HeaderRules, name string) logger.Timings {
+	hostname := getHostname(r)
+	resp := localResponse(r, name)
+	defer resp.Body.Close()
+	rules.ApplyResponse(resp.Header, r, hostname)
+	log.Debug("serving local file", "host", hostname, "path", r.URL.Path, "file", name, "status", resp.StatusCode)
+
+	copyHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	start := time.Now()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.LogError(fmt.Sprintf("failed to write local file to client for %s", hostname), err)
+	}
+
+	log.LogRequest(hostname, resp.StatusCode)
+	return logger.Timings{BodyTransfer: time.Since(start)}
+}
+
+// forwardRequest forwards the HTTP request to upstreamURL and relays the response
+// with its headers rewritten and paused at breakpoints as configured by opts
+// Returns the HTTP status code and any error encountered; phases are timed by timer
+func forwardRequest(w http.ResponseWriter, r *http.Request, upstreamURL *url.URL, timer *phaseTimer, opts forwardOptions) (int, error) {
 	// Create HTTP client with default transport
 	client := &http.Client{
 		// Disable automatic redirect following (proxy should forward as-is)
@@ -59,7 +125,8 @@ func forwardRequest(w http.ResponseWriter, r *http.Request) (int, error) {
 	}
 
 	// Create new request to upstream (copying original request)
-	upstreamReq, err := http.NewRequest(r.Method, r.URL.String(), r.Body)
+	ctx := httptrace.WithClientTrace(r.Context(), timer.trace())
+	upstreamReq, err := http.NewRequestWithContext(ctx, r.Method, upstreamURL.String(), r.Body)
 	if err != nil {
 		// Wrap error with context (constitution Principle II)
 		http.Error(w, "Failed to create upstream request", http.StatusInternalServerError)
@@ -79,6 +146,21 @@ func forwardRequest(w http.ResponseWriter, r *http.Request) (int, error) {
 	}
 	defer resp.Body.Close()
 
+	// Rewrite the response headers and pause at a matching breakpoint
+	opts.headerRules.ApplyResponse(resp.Header, r, getHostname(r))
+	if opts.breakpoints.match(BreakpointPhaseResponse, r.Method, r.URL) {
+		clearDeadlines(w)
+	}
+	drop, err := opts.breakpoints.interceptResponse(resp, r, r.URL)
+	if err != nil {
+		http.Error(w, "Bad Gateway: failed to read upstream response", http.StatusBadGateway)
+		return http.StatusBadGateway, err
+	}
+	if drop {
+		dropConnection(w)
+		return resp.StatusCode, errBreakpointDrop
+	}
+
 	// Copy response headers to client
 	copyHeaders(w.Header(), resp.Header)
 
@@ -86,7 +168,9 @@ func forwardRequest(w http.ResponseWriter, r *http.Request) (int, error) {
 	w.WriteHeader(resp.StatusCode)
 
 	// Copy response body to client
+	timer.startBody()
 	_, err = io.Copy(w, resp.Body)
+	timer.endBody()
 	if err != nil {
 		// Log error but don't return it (response already started)
 		return resp.StatusCode, fmt.Errorf("failed to copy response body: %w", err)
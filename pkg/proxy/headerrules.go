@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Header rule directions
+const (
+	HeaderDirectionRequest  = "request"  // Headers sent to the upstream server
+	HeaderDirectionResponse = "response" // Headers relayed to the client
+)
+
+// Header rule actions
+const (
+	HeaderActionAdd     = "add"     // Add a value, keeping existing ones
+	HeaderActionSet     = "set"     // Replace all values
+	HeaderActionRemove  = "remove"  // Delete the header
+	HeaderActionReplace = "replace" // Rewrite each value with a regular expression
+)
+
+// HeaderRule rewrites one request or response header of matching exchanges
+// Scope fields left empty match everything.
+type HeaderRule struct {
+	Direction string `json:"direction"` // HeaderDirectionRequest or HeaderDirectionResponse
+	Action    string `json:"action"`
+	Header    string `json:"header"`
+
+	// Value is the value to add or set, or the replacement for Pattern, which may
+	// refer to submatches as $1 or ${name}
+	Value   string `json:"value,omitempty"`
+	Pattern string `json:"pattern,omitempty"` // Regular expression (replace only)
+
+	// Hosts are host patterns as for TLS profiles: "example.com", "*.example.com" or "*"
+	Hosts []string `json:"hosts,omitempty"`
+	// Path is a glob for the URL path in which * matches any characters, including /
+	Path    string   `json:"path,omitempty"`
+	Methods []string `json:"methods,omitempty"`
+}
+
+// headerRulesFile is the JSON layout of a header rules file
+type headerRulesFile struct {
+	Rules []HeaderRule `json:"rules"`
+}
+
+// compiledHeaderRule is a validated rule with its patterns compiled
+type compiledHeaderRule struct {
+	HeaderRule
+	hosts   *hostMatcher[struct{}] // nil matches every host
+	path    *regexp.Regexp         // nil matches every path
+	pattern *regexp.Regexp
+	methods map[string]bool // nil matches every method
+}
+
+// HeaderRules is an ordered list of header rules, applied in order
+// Rules must not be added once the list is in use by a proxy; a nil *HeaderRules
+// rewrites nothing.
+type HeaderRules struct {
+	rules []compiledHeaderRule
+}
+
+// NewHeaderRules creates an empty rule list
+func NewHeaderRules() *HeaderRules {
+	return &HeaderRules{}
+}
+
+// DefaultHeaderRules returns the rules proxies start with: setting
+// X-Proxied-By: GoSniffer (FR-007) on every request sent upstream
+func DefaultHeaderRules() *HeaderRules {
+	rules := NewHeaderRules()
+	rules.Add(HeaderRule{
+		Direction: HeaderDirectionRequest,
+		Action:    HeaderActionSet,
+		Header:    ProxyHeaderName,
+		Value:     ProxyHeaderValue,
+	})
+	return rules
+}
+
+// ReadHeaderRules reads rules from a JSON file of the form {"rules": [...]}
+// The rules are validated when they are added to a HeaderRules.
+func ReadHeaderRules(path string) ([]HeaderRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header rules: %w", err)
+	}
+
+	var file headerRulesFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse header rules %s: %w", path, err)
+	}
+	return file.Rules, nil
+}
+
+// Add appends a rule
+// Returns an error if the direction, action, header or a pattern is invalid.
+func (r *HeaderRules) Add(rule HeaderRule) error {
+	rule.Direction = strings.ToLower(rule.Direction)
+	rule.Action = strings.ToLower(rule.Action)
+	if rule.Direction != HeaderDirectionRequest && rule.Direction != HeaderDirectionResponse {
+		return fmt.Errorf("unknown header rule direction %q (expected request or response)", rule.Direction)
+	}
+	if rule.Header == "" || strings.ContainsAny(rule.Header, " \t\r\n:") {
+		return fmt.Errorf("invalid header name %q", rule.Header)
+	}
+	if strings.ContainsAny(rule.Value, "\r\n") {
+		return fmt.Errorf("header %s: value must not contain line breaks", rule.Header)
+	}
+	rule.Header = http.CanonicalHeaderKey(rule.Header)
+
+	compiled := compiledHeaderRule{HeaderRule: rule}
+	switch rule.Action {
+	case HeaderActionAdd, HeaderActionSet, HeaderActionRemove:
+		if rule.Pattern != "" {
+			return fmt.Errorf("header %s: pattern is only used by replace", rule.Header)
+		}
+	case HeaderActionReplace:
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil || rule.Pattern == "" {
+			return fmt.Errorf("header %s: invalid replace pattern %q", rule.Header, rule.Pattern)
+		}
+		compiled.pattern = pattern
+	default:
+		return fmt.Errorf("unknown header rule action %q (expected add, set, remove or replace)", rule.Action)
+	}
+
+	if len(rule.Hosts) > 0 {
+		compiled.hosts = &hostMatcher[struct{}]{}
+		for _, host := range rule.Hosts {
+			if err := compiled.hosts.add(host, struct{}{}); err != nil {
+				return fmt.Errorf("header %s: %w", rule.Header, err)
+			}
+		}
+	}
+	if rule.Path != "" {
+		compiled.path = compileGlob(rule.Path)
+	}
+	if len(rule.Methods) > 0 {
+		compiled.methods = make(map[string]bool)
+		for _, method := range rule.Methods {
+			compiled.methods[strings.ToUpper(method)] = true
+		}
+	}
+
+	r.rules = append(r.rules, compiled)
+	return nil
+}
+
+// Len returns the number of rules
+func (r *HeaderRules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// ApplyRequest rewrites the headers of req, about to be sent to host
+func (r *HeaderRules) ApplyRequest(req *http.Request, host string) {
+	r.apply(HeaderDirectionRequest, req.Header, req, host)
+}
+
+// ApplyResponse rewrites the response headers h of req, received from host
+func (r *HeaderRules) ApplyResponse(h http.Header, req *http.Request, host string) {
+	r.apply(HeaderDirectionResponse, h, req, host)
+}
+
+// apply runs the rules for direction that match the exchange on h
+func (r *HeaderRules) apply(direction string, h http.Header, req *http.Request, host string) {
+	if r == nil {
+		return
+	}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if rule.Direction != direction || !rule.matches(req, host) {
+			continue
+		}
+
+		switch rule.Action {
+		case HeaderActionAdd:
+			h.Add(rule.Header, rule.Value)
+		case HeaderActionSet:
+			h.Set(rule.Header, rule.Value)
+		case HeaderActionRemove:
+			h.Del(rule.Header)
+			// net/http sends its own User-Agent unless the header is present but empty
+			if direction == HeaderDirectionRequest && rule.Header == "User-Agent" {
+				h[rule.Header] = []string{""}
+			}
+		case HeaderActionReplace:
+			for j, value := range h[rule.Header] {
+				h[rule.Header][j] = rule.pattern.ReplaceAllString(value, rule.Value)
+			}
+		}
+	}
+}
+
+// compileGlob converts a glob in which * matches any characters, including /,
+// to an anchored regular expression with one capturing group per *
+func compileGlob(glob string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(glob)
+	return regexp.MustCompile("^" + strings.ReplaceAll(quoted, `\*`, "(.*)") + "$")
+}
+
+// matches reports whether the rule's scope includes the request to host
+func (rule *compiledHeaderRule) matches(req *http.Request, host string) bool {
+	if rule.methods != nil && !rule.methods[req.Method] {
+		return false
+	}
+	if rule.path != nil && !rule.path.MatchString(req.URL.Path) {
+		return false
+	}
+	if rule.hosts != nil {
+		if _, ok := rule.hosts.lookup(host); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// hostMatcher maps host patterns to values
+// Patterns are exact hostnames, "*.example.com" for any subdomain of example.com,
+// or "*" for every host; lookups return the value of the most specific match.
+type hostMatcher[V any] struct {
+	exact    map[string]V
+	wildcard []wildcardEntry[V] // Sorted longest suffix first
+	fallback *V
+}
+
+// wildcardEntry is a value configured for all subdomains of a domain
+type wildcardEntry[V any] struct {
+	suffix string // ".example.com"
+	value  V
+}
+
+// add configures value for hosts matching pattern
+// Returns an error if the pattern is malformed or already configured.
+func (h *hostMatcher[V]) add(pattern string, value V) error {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	switch {
+	case pattern == "":
+		return fmt.Errorf("empty host pattern")
+	case pattern == "*":
+		if h.fallback != nil {
+			return fmt.Errorf("duplicate entry for %q", pattern)
+		}
+		h.fallback = &value
+	case strings.HasPrefix(pattern, "*."):
+		suffix := pattern[1:]
+		if strings.Contains(suffix, "*") {
+			return fmt.Errorf("invalid host pattern %q: only a leading *. is allowed", pattern)
+		}
+		for _, w := range h.wildcard {
+			if w.suffix == suffix {
+				return fmt.Errorf("duplicate entry for %q", pattern)
+			}
+		}
+		h.wildcard = append(h.wildcard, wildcardEntry[V]{suffix: suffix, value: value})
+		sort.SliceStable(h.wildcard, func(i, j int) bool {
+			return len(h.wildcard[i].suffix) > len(h.wildcard[j].suffix)
+		})
+	default:
+		if strings.Contains(pattern, "*") {
+			return fmt.Errorf("invalid host pattern %q: only a leading *. is allowed", pattern)
+		}
+		if _, exists := h.exact[pattern]; exists {
+			return fmt.Errorf("duplicate entry for %q", pattern)
+		}
+		if h.exact == nil {
+			h.exact = make(map[string]V)
+		}
+		h.exact[pattern] = value
+	}
+	return nil
+}
+
+// lookup returns the value for host (without port) and whether any pattern matched
+func (h *hostMatcher[V]) lookup(host string) (V, bool) {
+	host = strings.ToLower(host)
+	if value, ok := h.exact[host]; ok {
+		return value, true
+	}
+	for _, w := range h.wildcard {
+		if strings.HasSuffix(host, w.suffix) {
+			return w.value, true
+		}
+	}
+	if h.fallback != nil {
+		return *h.fallback, true
+	}
+	var zero V
+	return zero, false
+}
+
+// get returns the value for host, or the zero value if no pattern matches
+func (h *hostMatcher[V]) get(host string) V {
+	value, _ := h.lookup(host)
+	return value
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// KeyLogEnv is the environment variable naming the TLS key log file, as used by
+// browsers, curl and Wireshark
+const KeyLogEnv = "SSLKEYLOGFILE"
+
+// KeyLog appends TLS secrets in the NSS key log format to a file so packet
+// captures of both legs of the MITM can be decrypted. It is safe for
+// concurrent use by many connections.
+type KeyLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenKeyLog opens path for appending, creating it readable only by the owner
+func OpenKeyLog(path string) (*KeyLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key log file: %w", err)
+	}
+	return &KeyLog{file: file}, nil
+}
+
+// Write appends one or more complete key log lines
+func (k *KeyLog) Write(p []byte) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.file.Write(p)
+}
+
+// Close closes the key log file
+func (k *KeyLog) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.file.Close()
+}
+
+// SetKeyLogWriter writes the TLS secrets of client and upstream connections to w
+// (see tls.Config.KeyLogWriter). w must be safe for concurrent use.
+func (m *MITMHandler) SetKeyLogWriter(w io.Writer) {
+	m.keyLogWriter = w
+}
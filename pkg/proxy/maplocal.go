@@ -0,0 +1,293 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
+)
+
+// mapLocalIndex is served for requests mapped to a directory
+const mapLocalIndex = "index.html"
+
+// mapLocalRule serves requests whose URL matches pattern from target
+type mapLocalRule struct {
+	pattern *regexp.Regexp
+	origin  *regexp.Regexp // The scheme://host part of pattern
+	target  string
+}
+
+// MapLocal serves matching requests from local files instead of the upstream
+// Rules must not be added once the list is in use by a proxy; a nil *MapLocal
+// matches nothing.
+type MapLocal struct {
+	rules []mapLocalRule
+}
+
+// NewMapLocal creates an empty rule list
+func NewMapLocal() *MapLocal {
+	return &MapLocal{}
+}
+
+// Add maps URLs matching pattern to target, a local file or directory
+// Patterns are globs over scheme://host/path (without the query or default port)
+// in which * matches any characters, e.g. "https://cdn.example.com/app/*.js".
+// For a directory, the part of the path matched by the last * names the file.
+func (m *MapLocal) Add(pattern, target string) error {
+	if !strings.Contains(pattern, "://") {
+		return fmt.Errorf("invalid map local pattern %q: expected scheme://host/path", pattern)
+	}
+	if target == "" {
+		return fmt.Errorf("map local pattern %q: empty local path", pattern)
+	}
+
+	m.rules = append(m.rules, mapLocalRule{
+		pattern: compileGlob(pattern),
+		origin:  compileGlob(globOrigin(pattern)),
+		target:  target,
+	})
+	return nil
+}
+
+// Len returns the number of rules
+func (m *MapLocal) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.rules)
+}
+
+// Match returns the local file for the absolute URL u of the first matching rule
+// The file need not exist; requests for missing files are answered with 404.
+func (m *MapLocal) Match(u *url.URL) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+
+	key := urlMatchKey(u)
+	for _, rule := range m.rules {
+		groups := rule.pattern.FindStringSubmatch(key)
+		if groups == nil {
+			continue
+		}
+
+		info, err := os.Stat(rule.target)
+		if err != nil || !info.IsDir() {
+			return rule.target, true
+		}
+		// Directories receive the path matched by the last *, cleaned so it
+		// cannot escape the directory
+		var rest string
+		if len(groups) > 1 {
+			rest = groups[len(groups)-1]
+		}
+		name := filepath.Join(rule.target, filepath.FromSlash(path.Clean("/"+rest)))
+		return name, true
+	}
+	return "", false
+}
+
+// coversHost reports whether requests to host (host:port) over scheme may match
+// a rule, so the host may be served without contacting its upstream
+func (m *MapLocal) coversHost(scheme, host string) bool {
+	if m == nil {
+		return false
+	}
+
+	key := urlMatchKey(&url.URL{Scheme: scheme, Host: host})
+	for _, rule := range m.rules {
+		if rule.origin.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// globOrigin returns the scheme://host part of a scheme://host/path pattern
+func globOrigin(pattern string) string {
+	schemeEnd := strings.Index(pattern, "://") + len("://")
+	if i := strings.Index(pattern[schemeEnd:], "/"); i >= 0 {
+		return pattern[:schemeEnd+i]
+	}
+	return pattern
+}
+
+// urlMatchKey formats u as scheme://host/path for matching, dropping default ports
+func urlMatchKey(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	if name, port, err := net.SplitHostPort(host); err == nil &&
+		(u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443") {
+		host = name
+	}
+	return u.Scheme + "://" + host + u.Path
+}
+
+// SetMapLocal serves requests matching rules from local files (nil disables it)
+func (p *ProxyServer) SetMapLocal(rules *MapLocal) {
+	p.mapLocal = rules
+	if p.mitmHandler != nil {
+		p.mitmHandler.SetMapLocal(rules)
+	}
+}
+
+// SetMapLocal serves intercepted requests matching rules from local files (nil
+// disables it). Connections to hosts that rules may match are made per request,
+// so fully mapped hosts need no reachable upstream.
+func (m *MITMHandler) SetMapLocal(rules *MapLocal) {
+	m.mapLocal = rules
+}
+
+// serveLocal answers an intercepted request from a map local rule, if one matches,
+// reporting whether it did and any error writing to the client. timings holds
+// the connection phases to report with the exchange; span is ended.
+func (m *MITMHandler) serveLocal(clientConn net.Conn, req *http.Request, hostname string, conn *Flow, start time.Time, timings logger.Timings, span *tracing.Span) (bool, error) {
+	u := *req.URL
+	u.Scheme = "https"
+	u.Host = conn.Host
+	name, ok := m.mapLocal.Match(&u)
+	if !ok {
+		return false, nil
+	}
+
+	// Consume the request body so the next request on the connection can be read
+	reqBody := countRequestBody(req)
+	io.Copy(io.Discard, req.Body)
+
+	resp := localResponse(req, name)
+	m.headerRules.ApplyResponse(resp.Header, req, hostname)
+	m.logger.Debug("serving local file", "host", hostname, "path", req.URL.Path, "file", name,
+		"status", resp.StatusCode)
+
+	flow := *conn
+	flow.LocalFile = name
+	body := &countingReader{ReadCloser: resp.Body}
+	resp.Body = body
+	transferStart := time.Now()
+	err := resp.Write(clientConn)
+	resp.Body.Close()
+	timings.BodyTransfer = time.Since(transferStart)
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to write local file to client for %s", hostname), err)
+	}
+
+	m.completeFlow(&flow, hostname, req, resp.StatusCode, timings)
+	m.logAccess(&flow, req, resp.StatusCode, body.n, start, timings)
+	endRequestSpan(span, resp.StatusCode, err)
+	m.metrics.observeExchange("https", req.Method, resp.StatusCode, reqBody.n, body.n)
+	return true, err
+}
+
+// localResponse answers req with the local file name
+// Content-Type follows the file extension (or content), and Range, If-Range,
+// If-None-Match and If-Modified-Since are honoured against an ETag derived from
+// the file's size and modification time. The file is streamed through the
+// response body, which must be closed.
+func localResponse(req *http.Request, name string) *http.Response {
+	body, pw := io.Pipe()
+	w := &pipeResponse{header: make(http.Header), body: pw, ready: make(chan struct{})}
+	go func() {
+		serveLocalFile(w, req, name)
+		w.WriteHeader(http.StatusOK)
+		pw.Close()
+	}()
+	<-w.ready
+	return w.response(req, body)
+}
+
+// serveLocalFile writes the local file name, or its directory's index, to w
+func serveLocalFile(w http.ResponseWriter, req *http.Request, name string) {
+	f, err := os.Open(name)
+	if err == nil {
+		var info fs.FileInfo
+		if info, err = f.Stat(); err == nil && info.IsDir() {
+			f.Close()
+			name = filepath.Join(name, mapLocalIndex)
+			f, err = os.Open(name)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "Not Found: no local file for this URL", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Forbidden: local file cannot be read", http.StatusForbidden)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "Not Found: no local file for this URL", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, req, info.Name(), info.ModTime(), f)
+}
+
+// pipeResponse is an http.ResponseWriter streaming the body it is given through
+// a pipe, for relaying to intercepted clients with http.Response.Write
+type pipeResponse struct {
+	header http.Header
+	status int
+	sent   http.Header // Header as of WriteHeader
+	body   *io.PipeWriter
+	ready  chan struct{} // Closed by WriteHeader
+}
+
+func (p *pipeResponse) Header() http.Header {
+	return p.header
+}
+
+func (p *pipeResponse) WriteHeader(statusCode int) {
+	if p.status != 0 {
+		return
+	}
+	p.status = statusCode
+	p.sent = p.header.Clone()
+	close(p.ready)
+}
+
+func (p *pipeResponse) Write(b []byte) (int, error) {
+	p.WriteHeader(http.StatusOK)
+	return p.body.Write(b)
+}
+
+// response returns the response to req whose body is read from body
+func (p *pipeResponse) response(req *http.Request, body *io.PipeReader) *http.Response {
+	resp := &http.Response{
+		StatusCode: p.status,
+		Status:     fmt.Sprintf("%d %s", p.status, http.StatusText(p.status)),
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     p.sent,
+		Body:       body,
+		Request:    req,
+	}
+
+	// Responses to HEAD carry the length of the body that was not sent; bodies
+	// of unknown length are chunked so the connection can be reused
+	switch n, err := strconv.ParseInt(p.sent.Get("Content-Length"), 10, 64); {
+	case err == nil:
+		resp.ContentLength = n
+	case p.status == http.StatusNoContent || p.status == http.StatusNotModified || p.status < 200:
+		resp.ContentLength = 0
+	default:
+		resp.ContentLength = -1
+		resp.TransferEncoding = []string{"chunked"}
+	}
+	return resp
+}
@@ -0,0 +1,354 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
+)
+
+// mapRemoteRule sends requests whose URL matches pattern to target
+type mapRemoteRule struct {
+	pattern *regexp.Regexp
+	origin  *regexp.Regexp // The scheme://host part of pattern
+	target  string
+	stars   int // Number of * in target
+}
+
+// MapRemote sends matching requests to another upstream, rewriting their
+// scheme, host, port and path
+// Rules must not be added once the list is in use by a proxy; a nil *MapRemote
+// matches nothing.
+type MapRemote struct {
+	rules []mapRemoteRule
+}
+
+// NewMapRemote creates an empty rule list
+func NewMapRemote() *MapRemote {
+	return &MapRemote{}
+}
+
+// Add sends URLs matching pattern to target
+// Patterns are globs over scheme://host/path as for MapLocal. The target is an
+// http or https URL whose * are replaced, in order, by the parts matched by the
+// last * of the pattern, e.g. "https://api.example.com/v2/*" to
+// "http://localhost:9000/v2/*". The query is kept unless target has its own.
+func (m *MapRemote) Add(pattern, target string) error {
+	if !strings.Contains(pattern, "://") {
+		return fmt.Errorf("invalid map remote pattern %q: expected scheme://host/path", pattern)
+	}
+
+	stars := strings.Count(target, "*")
+	if stars > strings.Count(pattern, "*") {
+		return fmt.Errorf("map remote target %q has more * than pattern %q", target, pattern)
+	}
+	u, err := url.Parse(strings.ReplaceAll(target, "*", "x"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid map remote target %q: expected an http or https URL", target)
+	}
+
+	m.rules = append(m.rules, mapRemoteRule{
+		pattern: compileGlob(pattern),
+		origin:  compileGlob(globOrigin(pattern)),
+		target:  target,
+		stars:   stars,
+	})
+	return nil
+}
+
+// Len returns the number of rules
+func (m *MapRemote) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.rules)
+}
+
+// Match returns the URL the absolute URL u is sent to by the first matching rule
+func (m *MapRemote) Match(u *url.URL) (*url.URL, bool) {
+	if m == nil {
+		return nil, false
+	}
+
+	key := urlMatchKey(u)
+	for _, rule := range m.rules {
+		groups := rule.pattern.FindStringSubmatch(key)
+		if groups == nil {
+			continue
+		}
+
+		target := rule.target
+		for _, group := range groups[len(groups)-rule.stars:] {
+			escaped := (&url.URL{Path: group}).EscapedPath()
+			target = strings.Replace(target, "*", escaped, 1)
+		}
+		mapped, err := url.Parse(target)
+		if err != nil {
+			continue
+		}
+		if mapped.RawQuery == "" {
+			mapped.RawQuery = u.RawQuery
+		}
+		return mapped, true
+	}
+	return nil, false
+}
+
+// coversHost reports whether requests to host (host:port) over scheme may match
+// a rule, so their upstream has to be chosen per request
+func (m *MapRemote) coversHost(scheme, host string) bool {
+	if m == nil {
+		return false
+	}
+
+	key := urlMatchKey(&url.URL{Scheme: scheme, Host: host})
+	for _, rule := range m.rules {
+		if rule.origin.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMapRemote sends requests matching rules to other upstreams (nil disables it)
+func (p *ProxyServer) SetMapRemote(rules *MapRemote) {
+	p.mapRemote = rules
+	if p.mitmHandler != nil {
+		p.mitmHandler.SetMapRemote(rules)
+	}
+}
+
+// SetMapRemote sends intercepted requests matching rules to other upstreams
+// (nil disables it). Connections to hosts that rules may match are made per
+// request rather than to the CONNECT target.
+func (m *MITMHandler) SetMapRemote(rules *MapRemote) {
+	m.mapRemote = rules
+}
+
+// mappedTransport returns the transport for hosts whose upstream is chosen per
+// request; TLS connections are made as for CONNECT targets
+func (m *MITMHandler) mappedTransport() *http.Transport {
+	m.mappedOnce.Do(func() {
+		dialer := &net.Dialer{Timeout: upstreamDialTimeout}
+		m.mapped = &http.Transport{
+			DialContext: dialer.DialContext,
+			DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				rawConn, err := dialer.DialContext(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				host, _, _ := net.SplitHostPort(addr)
+				conn := tls.Client(rawConn, m.upstreamTLSConfig(host, nil))
+
+				// The transport only reports handshakes it performs itself
+				trace := httptrace.ContextClientTrace(ctx)
+				if trace != nil && trace.TLSHandshakeStart != nil {
+					trace.TLSHandshakeStart()
+				}
+				ctx, cancel := context.WithTimeout(ctx, upstreamDialTimeout)
+				defer cancel()
+				err = conn.HandshakeContext(ctx)
+				if trace != nil && trace.TLSHandshakeDone != nil {
+					trace.TLSHandshakeDone(conn.ConnectionState(), err)
+				}
+				if err != nil {
+					rawConn.Close()
+					m.metrics.handshakeFailed(handshakeSideUpstream)
+					return nil, err
+				}
+				return conn, nil
+			},
+			// Responses are relayed as received
+			DisableCompression: true,
+			IdleConnTimeout:    90 * time.Second,
+		}
+	})
+	return m.mapped
+}
+
+// proxyMappedTraffic relays the requests read from clientConn, a connection for
+// a host covered by map local, map remote or breakpoint rules, to the local file
+// or upstream each of them is mapped to (or to the CONNECT target) until the
+// client stops sending requests
+func (m *MITMHandler) proxyMappedTraffic(clientConn *tls.Conn, hostname string, conn *Flow) {
+	clientReader := bufio.NewReader(clientConn)
+	for first := true; ; first = false {
+		req, err := http.ReadRequest(clientReader)
+		if err != nil {
+			// Connection closed or no more requests
+			if err != io.EOF && !strings.Contains(err.Error(), "timeout") {
+				m.logger.LogError(fmt.Sprintf("failed to read HTTPS request from client for %s", hostname), err)
+			}
+			return
+		}
+		clientConn.SetReadDeadline(time.Time{})
+		start := time.Now()
+
+		m.logger.Debug("intercepted request", "method", req.Method, "host", hostname,
+			"path", req.URL.Path, "content_length", req.ContentLength)
+		span := startRequestSpan(m.tracer, req, "https", conn.Host, start)
+
+		// The first exchange also reports the client handshake
+		var timings logger.Timings
+		if first {
+			traceConnection(span, conn, nil)
+			timings.ClientTLS = conn.Timings.ClientTLS
+		}
+
+		if handled, err := m.serveLocal(clientConn, req, hostname, conn, start, timings, span); handled {
+			if err != nil {
+				return
+			}
+		} else if !m.forwardMapped(clientConn, clientReader, req, hostname, conn, start, timings, span) {
+			return
+		}
+
+		// Set deadline for next request
+		clientConn.SetReadDeadline(time.Now().Add(1 * time.Second))
+	}
+}
+
+// forwardMapped sends req to the upstream a map remote rule maps it to, or to
+// the CONNECT target, and relays the response. It reports whether the client
+// connection can carry further requests; span is ended.
+func (m *MITMHandler) forwardMapped(clientConn *tls.Conn, clientReader *bufio.Reader, req *http.Request, hostname string, conn *Flow, start time.Time, timings logger.Timings, span *tracing.Span) bool {
+	// WebSocket upgrades need their Connection and Upgrade headers
+	upgrade := isWebSocketUpgrade(req)
+	if !upgrade {
+		removeHopByHopHeaders(req.Header)
+	}
+	m.headerRules.ApplyRequest(req, hostname)
+
+	// Pause at a matching breakpoint before the destination is chosen, so edits
+	// to the path are mapped too
+	if !upgrade {
+		if done, reuse := m.breakRequest(clientConn, req, hostname, conn, start, timings, span); done {
+			return reuse
+		}
+	}
+
+	flow := *conn
+	target := &url.URL{Scheme: "https", Host: conn.Host, Path: req.URL.Path, RawPath: req.URL.RawPath, RawQuery: req.URL.RawQuery}
+	if mapped, ok := m.mapRemote.Match(target); ok {
+		m.logger.Debug("mapped request", "host", hostname, "from", target.String(), "to", mapped.String())
+		target = mapped
+		flow.MappedURL = mapped.String()
+	}
+
+	timer := &phaseTimer{}
+	ctx := httptrace.WithClientTrace(context.Background(), timer.trace())
+	reqBody := countRequestBody(req)
+	upstreamReq, err := http.NewRequestWithContext(ctx, req.Method, target.String(), req.Body)
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to create mapped request for %s", hostname), err)
+		endRequestSpan(span, 0, err)
+		return false
+	}
+	upstreamReq.Header = req.Header
+	upstreamReq.ContentLength = req.ContentLength
+
+	upstreamStart := time.Now()
+	resp, err := m.mappedTransport().RoundTrip(upstreamReq)
+	traceUpstreamDial(span, timer, target.Host)
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("upstream request failed for %s (sent to %s)", hostname, target.Host), err)
+		m.writeErrorPage(clientConn, req, target.Host, err, &flow, start, mergeTimings(timer.result(), timings), span)
+		return false
+	}
+	defer resp.Body.Close()
+	m.metrics.observeUpstreamLatency("https", time.Since(upstreamStart))
+	if resp.TLS != nil {
+		flow.UpstreamTLSVersion = resp.TLS.Version
+	}
+
+	m.logger.Debug("upstream response", "host", hostname, "status", resp.StatusCode,
+		"content_length", resp.ContentLength)
+	m.headerRules.ApplyResponse(resp.Header, req, hostname)
+
+	if upgrade && resp.StatusCode == http.StatusSwitchingProtocols {
+		m.relayMappedUpgrade(clientConn, clientReader, upstreamReq, resp, hostname, &flow, start, mergeTimings(timer.result(), timings), span)
+		return false
+	}
+
+	if m.breakResponse(resp, req, hostname, conn, span) {
+		return false
+	}
+
+	clientConn.SetWriteDeadline(time.Time{})
+	body := &countingReader{ReadCloser: resp.Body}
+	resp.Body = body
+	timer.startBody()
+	err = resp.Write(clientConn)
+	timer.endBody()
+
+	timings = mergeTimings(timer.result(), timings)
+	m.completeFlow(&flow, hostname, upstreamReq, resp.StatusCode, timings)
+	m.logAccess(&flow, req, resp.StatusCode, body.n, start, timings)
+	endRequestSpan(span, resp.StatusCode, err)
+	m.metrics.observeExchange("https", req.Method, resp.StatusCode, reqBody.n, body.n)
+	if err != nil {
+		// Don't log client-initiated disconnections as errors
+		if !strings.Contains(err.Error(), "broken pipe") &&
+			!strings.Contains(err.Error(), "connection reset") {
+			m.logger.LogError(fmt.Sprintf("failed to write response to client for %s", hostname), err)
+		}
+		return false
+	}
+	return true
+}
+
+// relayMappedUpgrade forwards a 101 response from a mapped upstream and relays
+// the upgraded connection until either side closes it
+func (m *MITMHandler) relayMappedUpgrade(clientConn *tls.Conn, clientReader *bufio.Reader, req *http.Request, resp *http.Response, hostname string, flow *Flow, start time.Time, timings logger.Timings, span *tracing.Span) {
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		err := fmt.Errorf("upgraded response body is not writable")
+		m.logger.LogError(fmt.Sprintf("failed to relay WebSocket upgrade for %s", hostname), err)
+		endRequestSpan(span, resp.StatusCode, err)
+		return
+	}
+
+	// Only the status line and headers precede the upgraded stream
+	resp.Body = nil
+	err := resp.Write(clientConn)
+	m.completeFlow(flow, hostname, req, resp.StatusCode, timings)
+	m.logAccess(flow, req, resp.StatusCode, 0, start, timings)
+	endRequestSpan(span, resp.StatusCode, err)
+	m.metrics.observeExchange("https", req.Method, resp.StatusCode, 0, 0)
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to send WebSocket upgrade response to client for %s", hostname), err)
+		return
+	}
+
+	m.logger.Debug("websocket tunnel established", "host", hostname)
+	clientConn.SetDeadline(time.Time{})
+	done := make(chan struct{}, 2)
+	go func() {
+		// Frames the client sent early may already be buffered
+		io.Copy(upstream, clientReader)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(clientConn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+	m.logger.Debug("websocket tunnel closed", "host", hostname)
+}
+
+// mergeTimings returns the upstream phases t with the client handshake of client
+func mergeTimings(t, client logger.Timings) logger.Timings {
+	t.ClientTLS = client.ClientTLS
+	return t
+}
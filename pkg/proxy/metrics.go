@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/metrics"
+)
+
+// Values of the direction label of gosniffer_transferred_bytes_total
+const (
+	directionUpstream   = "upstream"   // Request bodies sent to upstream servers
+	directionDownstream = "downstream" // Response bodies sent to clients
+)
+
+// Values of the side label of gosniffer_tls_handshake_failures_total
+const (
+	handshakeSideClient   = "client"
+	handshakeSideUpstream = "upstream"
+)
+
+// knownMethods are reported as the method label; others are reported as OTHER
+// so clients cannot create unbounded label values
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodConnect: true,
+	http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// Metrics instruments the proxy (see ProxyServer.SetMetrics)
+// A nil *Metrics records nothing.
+type Metrics struct {
+	registry          *metrics.Registry
+	requests          *metrics.CounterVec   // scheme, method, status
+	upstreamLatency   *metrics.HistogramVec // scheme
+	transferredBytes  *metrics.CounterVec   // scheme, direction
+	certGeneration    *metrics.Histogram
+	handshakeFailures *metrics.CounterVec // side
+}
+
+// NewMetrics registers the proxy's metrics in registry
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		registry: registry,
+		requests: registry.NewCounterVec("gosniffer_requests_total",
+			"Proxied requests by scheme, method and response status.", "scheme", "method", "status"),
+		upstreamLatency: registry.NewHistogramVec("gosniffer_upstream_latency_seconds",
+			"Time from sending a request upstream to receiving the response headers.", metrics.DefaultBuckets, "scheme"),
+		transferredBytes: registry.NewCounterVec("gosniffer_transferred_bytes_total",
+			"Body bytes relayed upstream (requests) and downstream (responses).", "scheme", "direction"),
+		certGeneration: registry.NewHistogram("gosniffer_certificate_generation_seconds",
+			"Time to obtain a leaf key and sign a leaf certificate.", metrics.DefaultBuckets),
+		handshakeFailures: registry.NewCounterVec("gosniffer_tls_handshake_failures_total",
+			"Failed TLS handshakes with clients and upstream servers.", "side"),
+	}
+}
+
+// SetMetrics records metrics for proxied requests and active connections (nil disables it)
+func (p *ProxyServer) SetMetrics(m *Metrics) {
+	p.metrics = m
+	if m == nil {
+		return
+	}
+
+	m.registry.NewGaugeFunc("gosniffer_active_connections",
+		"CONNECT tunnels currently open.", func() float64 {
+			return float64(p.shutdownCoordinator.GetActiveConnectionCount())
+		})
+	if p.mitmHandler != nil {
+		p.mitmHandler.SetMetrics(m)
+	}
+}
+
+// SetMetrics records metrics for intercepted requests and the certificate cache (nil disables it)
+func (m *MITMHandler) SetMetrics(mt *Metrics) {
+	m.metrics = mt
+	if mt == nil {
+		return
+	}
+
+	cache := m.certCache
+	mt.registry.NewCounterFunc("gosniffer_certificate_cache_hits_total",
+		"Certificate cache lookups that found a certificate.", func() float64 { return float64(cache.Stats().Hits) })
+	mt.registry.NewCounterFunc("gosniffer_certificate_cache_misses_total",
+		"Certificate cache lookups that found no usable certificate.", func() float64 { return float64(cache.Stats().Misses) })
+	mt.registry.NewCounterFunc("gosniffer_certificate_cache_evictions_total",
+		"Certificates removed from the cache by its size limit or TTL.", func() float64 { return float64(cache.Stats().Evictions) })
+	mt.registry.NewGaugeFunc("gosniffer_certificate_cache_size",
+		"Certificates currently cached.", func() float64 { return float64(cache.Size()) })
+}
+
+// observeExchange records a completed request and the body bytes relayed in each direction
+func (m *Metrics) observeExchange(scheme, method string, statusCode int, requestBytes, responseBytes int64) {
+	if m == nil {
+		return
+	}
+	if !knownMethods[method] {
+		method = "OTHER"
+	}
+	m.requests.With(scheme, method, strconv.Itoa(statusCode)).Inc()
+	m.transferredBytes.With(scheme, directionUpstream).Add(float64(requestBytes))
+	m.transferredBytes.With(scheme, directionDownstream).Add(float64(responseBytes))
+}
+
+// observeUpstreamLatency records the time an upstream server took to respond
+func (m *Metrics) observeUpstreamLatency(scheme string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.upstreamLatency.With(scheme).Observe(d.Seconds())
+}
+
+// observeCertGeneration records the time taken to issue a leaf certificate
+func (m *Metrics) observeCertGeneration(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.certGeneration.Observe(d.Seconds())
+}
+
+// handshakeFailed counts a failed TLS handshake on side
+func (m *Metrics) handshakeFailed(side string) {
+	if m == nil {
+		return
+	}
+	m.handshakeFailures.With(side).Inc()
+}
@@ -2,17 +2,23 @@ package proxy
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/yourusername/go-mitmproxy/pkg/ca"
 	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
 )
 
 const (
@@ -23,13 +29,63 @@ const (
 	upstreamDialTimeout = 15 * time.Second
 )
 
+// OutOfScopePolicy decides how CONNECT requests for hosts outside the CA's
+// name constraints are handled, since certificates for them would fail validation
+type OutOfScopePolicy string
+
+const (
+	// OutOfScopeTunnel relays the encrypted connection without interception
+	OutOfScopeTunnel OutOfScopePolicy = "tunnel"
+
+	// OutOfScopeReject refuses the CONNECT request with 403 Forbidden
+	OutOfScopeReject OutOfScopePolicy = "reject"
+)
+
 // MITMHandler handles HTTPS CONNECT requests with TLS interception
 // Implements T031-T045: Full HTTPS MITM functionality
 type MITMHandler struct {
 	ca                  *ca.CA
+	caMu                sync.RWMutex // Protects ca (replaced by ReloadCA)
 	certCache           *ca.CertificateCache
 	logger              *logger.Logger
 	shutdownCoordinator *ShutdownCoordinator
+
+	// Leaf key configuration (see SetLeafKeyType, SetSharedLeafKey, SetKeyPool)
+	leafKeyType   ca.KeySpec  // Key spec for generated leaf certificates
+	sharedLeafKey interface{} // Long-lived key reused for all leaf certificates (nil if disabled)
+	keyPool       *ca.KeyPool // Pre-generated leaf keys (nil if disabled)
+
+	outOfScopePolicy OutOfScopePolicy // Handling of hosts the CA may not sign for
+
+	// Mutual TLS (see SetClientCertificates, SetRequestClientCert)
+	clientCerts       *ClientCertificates // Certificates presented to upstream servers (nil if none)
+	requestClientCert bool                // Ask clients for a certificate on the downstream side
+
+	upstreamPolicy *UpstreamTLSPolicy // Verification of upstream server certificates
+	errorPages     *ErrorPages        // Served when the upstream cannot be reached
+
+	fingerprintRules *FingerprintRules // Routing by client TLS fingerprint (nil if none)
+
+	// TLS versions, ciphers, curves and ALPN per side (see SetClientTLSProfiles)
+	clientTLS   *TLSProfiles
+	upstreamTLS *TLSProfiles
+
+	keyLogWriter io.Writer // Receives TLS secrets of both legs (nil if disabled)
+
+	accessLog *logger.AccessLog // Access log for intercepted requests (nil if disabled)
+	metrics   *Metrics          // Prometheus metrics (nil if disabled)
+	tracer    *tracing.Tracer   // Trace context and spans (nil if disabled)
+
+	headerRules *HeaderRules // Header rewriting (nil rewrites nothing)
+	mapLocal    *MapLocal    // Requests answered from local files (nil if disabled)
+	mapRemote   *MapRemote   // Requests sent to other upstreams (nil if disabled)
+	breakpoints *Breakpoints // Flows paused for editing (nil if disabled)
+
+	// Transport for hosts whose upstream is chosen per request (see mappedTransport)
+	mappedOnce sync.Once
+	mapped     *http.Transport
+
+	flowHandler FlowHandler // Called for every completed flow (nil if none)
 }
 
 // NewMITMHandler creates a new MITM handler
@@ -38,15 +94,106 @@ func NewMITMHandler(rootCA *ca.CA, certCache *ca.CertificateCache, log *logger.L
 		ca:        rootCA,
 		certCache: certCache,
 		logger:    log,
+		// Leaf keys match the CA key type unless overridden by SetLeafKeyType
+		leafKeyType:      rootCA.KeySpec(),
+		outOfScopePolicy: OutOfScopeTunnel,
+		upstreamPolicy:   NewUpstreamTLSPolicy(),
+		errorPages:       NewErrorPages(),
+		clientTLS:        &TLSProfiles{defaults: DefaultTLSSettings()},
+		upstreamTLS:      &TLSProfiles{defaults: DefaultTLSSettings()},
+		headerRules:      DefaultHeaderRules(),
 		// Shutdown coordinator will be set by SetShutdownCoordinator
 	}
 }
 
+// SetHeaderRules replaces the header rules applied to intercepted requests and
+// responses (nil disables header rewriting, including the default X-Proxied-By rule)
+func (m *MITMHandler) SetHeaderRules(rules *HeaderRules) {
+	m.headerRules = rules
+}
+
 // SetShutdownCoordinator sets the shutdown coordinator for connection tracking
 func (m *MITMHandler) SetShutdownCoordinator(sc *ShutdownCoordinator) {
 	m.shutdownCoordinator = sc
 }
 
+// ReloadCA replaces the signing CA in a running proxy
+// Cached certificates issued by the previous CA are discarded.
+func (m *MITMHandler) ReloadCA(newCA *ca.CA) {
+	m.caMu.Lock()
+	m.ca = newCA
+	m.caMu.Unlock()
+
+	m.certCache.Clear()
+	m.logger.LogInfo(fmt.Sprintf("CA reloaded (fingerprint: %s), certificate cache cleared",
+		ca.Fingerprint(newCA.Certificate)))
+}
+
+// CA returns the current signing CA
+func (m *MITMHandler) CA() *ca.CA {
+	m.caMu.RLock()
+	defer m.caMu.RUnlock()
+	return m.ca
+}
+
+// SetLeafKeyType sets the key type used for generated leaf certificates
+// Any ca.KeySpec is supported (RSA 2048/3072/4096, ECDSA P-256/P-384, Ed25519);
+// unknown specs are rejected so they fail at startup rather than on every CONNECT.
+func (m *MITMHandler) SetLeafKeyType(keyType ca.KeySpec) error {
+	spec, err := ca.ParseKeySpec(string(keyType))
+	if err != nil {
+		return fmt.Errorf("invalid leaf key type: %w", err)
+	}
+	m.leafKeyType = spec
+	return nil
+}
+
+// SetSharedLeafKey sets a long-lived private key that is reused for every
+// generated leaf certificate (as mitmproxy does), so issuance only signs
+func (m *MITMHandler) SetSharedLeafKey(key interface{}) {
+	m.sharedLeafKey = key
+}
+
+// SetKeyPool sets a pool of pre-generated leaf keys used when no shared key is configured
+func (m *MITMHandler) SetKeyPool(pool *ca.KeyPool) {
+	m.keyPool = pool
+}
+
+// SetOutOfScopePolicy sets how hosts outside the CA's name constraints are handled
+func (m *MITMHandler) SetOutOfScopePolicy(policy OutOfScopePolicy) {
+	m.outOfScopePolicy = policy
+}
+
+// SetClientCertificates sets the client certificates presented to upstream
+// servers that request one
+func (m *MITMHandler) SetClientCertificates(certs *ClientCertificates) {
+	m.clientCerts = certs
+}
+
+// SetRequestClientCert makes the proxy ask clients for a certificate during the
+// downstream handshake. Clients may decline; a presented certificate is not
+// verified, only its subject is recorded on the flow.
+func (m *MITMHandler) SetRequestClientCert(enabled bool) {
+	m.requestClientCert = enabled
+}
+
+// SetUpstreamTLSPolicy sets how upstream server certificates are verified
+func (m *MITMHandler) SetUpstreamTLSPolicy(policy *UpstreamTLSPolicy) {
+	m.upstreamPolicy = policy
+}
+
+// leafKey returns the private key for the next generated leaf certificate
+// Preference order: shared key, key pool, synchronous generation
+func (m *MITMHandler) leafKey() (interface{}, error) {
+	if m.sharedLeafKey != nil {
+		return m.sharedLeafKey, nil
+	}
+	if m.keyPool != nil {
+		return m.keyPool.Get()
+	}
+	return ca.GenerateKey(m.leafKeyType)
+}
+
 // HandleCONNECT handles HTTPS CONNECT requests and performs TLS MITM
 // Implements:
 // - T031: CONNECT method detection
@@ -62,6 +209,7 @@ func (m *MITMHandler) HandleCONNECT(w http.ResponseWriter, r *http.Request) {
 	// T031: Extract hostname from CONNECT request
 	// CONNECT request format: "CONNECT example.com:443 HTTP/1.1"
 	hostname := r.Host
+	start := time.Now()
 	if hostname == "" {
 		m.logger.LogError("CONNECT request missing host", fmt.Errorf("empty Host header"))
 		http.Error(w, "Bad Request: missing host", http.StatusBadRequest)
@@ -75,6 +223,22 @@ func (m *MITMHandler) HandleCONNECT(w http.ResponseWriter, r *http.Request) {
 		host = hostname
 	}
 
+	// A name-constrained CA cannot issue a valid certificate for hosts outside
+	// its permitted set, so tunnel or reject them instead of intercepting
+	rootCA := m.CA()
+	if !rootCA.Permits(host) {
+		if m.outOfScopePolicy == OutOfScopeReject {
+			m.logger.LogInfo(fmt.Sprintf("Rejecting CONNECT to %s - outside CA name constraints", hostname))
+			http.Error(w, "Forbidden: host outside CA name constraints", http.StatusForbidden)
+			m.logTunnel(r, http.StatusForbidden, 0, start, "", "", "host outside CA name constraints")
+			return
+		}
+		if status, bytes := m.tunnelCONNECT(w, hostname); status != 0 {
+			m.logTunnel(r, status, bytes, start, "", "", "")
+		}
+		return
+	}
+
 	// T032: Hijack the connection to get raw TCP socket
 	hijacker, ok := w.(http.Hijacker)
 	if !ok {
@@ -112,61 +276,138 @@ func (m *MITMHandler) HandleCONNECT(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// T044: Get or generate certificate (with cache integration)
-	cert := m.certCache.Get(host)
-	if cert == nil {
-		// Certificate not in cache, generate new one
-		// T042: Error handling for certificate generation (abort on failure per SR-007)
-		cert, err = m.ca.GenerateCertificate(host, "rsa")
+	// The certificate is only looked up once the fingerprint rules have decided
+	// to intercept, so tunnelled and blocked clients do not pay for issuance
+	var certErr error
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := m.leafCertificate(rootCA, host)
 		if err != nil {
-			m.logger.LogError(fmt.Sprintf("certificate generation failed for %s", host), err)
-			// SR-007: MUST abort on certificate generation failure, no insecure fallback
-			return
+			certErr = err
+			return nil, err
 		}
-
-		// Cache the generated certificate
-		m.certCache.Put(host, cert)
+		return cert.TLSCert, nil
 	}
 
-	// T036: TLS configuration (TLS 1.2 minimum, TLS 1.3 preferred)
+	// T036: TLS configuration (TLS 1.2 minimum, TLS 1.3 preferred, unless configured)
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{*cert.TLSCert},
-		MinVersion:   tls.VersionTLS12,
-		MaxVersion:   tls.VersionTLS13,
+		GetCertificate: getCertificate,
+		KeyLogWriter:   m.keyLogWriter,
+	}
+	m.clientTLS.ForHost(host).apply(tlsConfig)
+	if m.requestClientCert {
+		tlsConfig.ClientAuth = tls.RequestClientCert
+	}
+
+	// Fingerprint the ClientHello (JA3/JA4) and apply fingerprint rules before
+	// the handshake continues; the recorded bytes let a tunnelled client reach
+	// the upstream with its original ClientHello
+	var hello *ClientHello
+	var ja3, ja4 string
+	recorder := newHelloRecorder(clientConn)
+	tlsConfig.GetConfigForClient = func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+		hello = NewClientHello(info)
+		ja3, ja4 = hello.JA3(), hello.JA4()
+		action := FingerprintIntercept
+		if m.fingerprintRules != nil {
+			action, _ = m.fingerprintRules.Match(ja3, ja4)
+		}
+		switch action {
+		case FingerprintBlock:
+			return nil, errFingerprintBlocked
+		case FingerprintTunnel:
+			recorder.tunnel = true
+			return nil, errFingerprintTunnel
+		}
+		recorder.stopRecording()
+		return nil, nil
 	}
 
 	// T034: Perform TLS handshake with client using generated certificate
 	// T043: Error handling for TLS handshake failures
-	clientTLS := tls.Server(clientConn, tlsConfig)
+	clientTLS := tls.Server(recorder, tlsConfig)
 	clientTLS.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
 
+	handshakeStart := time.Now()
 	if err := clientTLS.Handshake(); err != nil {
-		m.logger.LogError(fmt.Sprintf("client TLS handshake failed for %s", host), err)
+		switch {
+		case errors.Is(err, errFingerprintTunnel):
+			m.logger.LogInfo(fmt.Sprintf("Tunnelling %s without interception (client ja3=%s ja4=%s)", hostname, ja3, ja4))
+			clientConn.SetDeadline(time.Time{})
+			bytes, err := m.tunnelClientHello(clientConn, hostname, recorder.recorded.Bytes())
+			reason := ""
+			if err != nil {
+				reason = err.Error()
+			}
+			m.logTunnel(r, http.StatusOK, bytes, start, ja3, ja4, reason)
+		case errors.Is(err, errFingerprintBlocked):
+			m.logger.LogInfo(fmt.Sprintf("Blocked client %s for %s by fingerprint rule (ja3=%s ja4=%s)",
+				clientConn.RemoteAddr(), hostname, ja3, ja4))
+			m.logTunnel(r, http.StatusOK, 0, start, ja3, ja4, "blocked by fingerprint rule")
+		case certErr != nil:
+			// T042 / SR-007: MUST abort on certificate generation failure, no insecure fallback
+			m.logger.LogError(fmt.Sprintf("certificate generation failed for %s", host), certErr)
+		default:
+			m.metrics.handshakeFailed(handshakeSideClient)
+			m.logger.LogError(fmt.Sprintf("client TLS handshake failed for %s", host), err)
+		}
 		// SR-007: MUST abort on TLS handshake failure
 		return
 	}
 
 	// Clear deadline after successful handshake
 	clientTLS.SetDeadline(time.Time{})
+	clientHandshake := time.Since(handshakeStart)
 
-	// T035: Establish upstream TLS connection
-	upstreamTLSConfig := &tls.Config{
-		ServerName: host,
-		MinVersion: tls.VersionTLS12,
-		MaxVersion: tls.VersionTLS13,
-		// Certificate verification enabled by default (validates upstream server)
+	flow := &Flow{
+		ClientAddr:  clientConn.RemoteAddr().String(),
+		Host:        hostname,
+		ClientHello: hello,
+		JA3:         ja3,
+		JA4:         ja4,
+
+		ClientTLSVersion: clientTLS.ConnectionState().Version,
+	}
+	flow.Timings.ClientTLS = clientHandshake
+	flow.clientTLSStart = handshakeStart
+	if peerCerts := clientTLS.ConnectionState().PeerCertificates; len(peerCerts) > 0 {
+		flow.ClientCertSubject = peerCerts[0].Subject.String()
+		m.logger.LogInfo(fmt.Sprintf("Client %s presented certificate %q for %s",
+			flow.ClientAddr, flow.ClientCertSubject, host))
 	}
 
+	// Requests to hosts with map local or map remote rules may be answered locally
+	// or sent elsewhere, so their upstream is chosen per request instead of being
+	// dialled up front; it is only contacted for requests no rule matches. Hosts
+	// with breakpoints too, so a pause cannot outlast an idle upstream connection
+	if m.mapLocal.coversHost("https", hostname) || m.mapRemote.coversHost("https", hostname) ||
+		m.breakpoints.coversHost("https", hostname) {
+		m.proxyMappedTraffic(clientTLS, host, flow)
+		return
+	}
+
+	// T035: Establish upstream TLS connection
 	dialer := &net.Dialer{
 		Timeout: upstreamDialTimeout,
 	}
 
-	upstreamConn, err := tls.DialWithDialer(dialer, "tcp", hostname, upstreamTLSConfig)
+	flow.upstreamStart = time.Now()
+	upstreamConn, err := m.dialUpstreamTLS(dialer, hostname, m.upstreamTLSConfig(host, flow), &flow.Timings)
+	flow.upstreamEnd = time.Now()
 	if err != nil {
 		m.logger.LogError(fmt.Sprintf("upstream TLS connection failed for %s", hostname), err)
-		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		// The CONNECT was already answered, so report the failure inside the tunnel
+		m.serveErrorPage(clientTLS, hostname, err, flow)
 		return
 	}
 	defer upstreamConn.Close()
+	flow.UpstreamTLSVersion = upstreamConn.ConnectionState().Version
+
+	if v := flow.UpstreamTLS; v.Insecure && !v.Verified {
+		m.logger.Warn("upstream certificate not verified (insecure host)", "host", hostname, "error", v.Error)
+	}
+	if flow.UpstreamClientCertSubject != "" {
+		m.logger.LogInfo(fmt.Sprintf("Presented client certificate %q to %s", flow.UpstreamClientCertSubject, hostname))
+	}
 
 	// Now we have two TLS connections:
 	// - clientTLS: encrypted connection to client (decrypted by us)
@@ -180,12 +421,197 @@ func (m *MITMHandler) HandleCONNECT(w http.ResponseWriter, r *http.Request) {
 	// T041: Relay response to client TLS connection
 	// T045: Integrate logger for HTTPS request logging
 
-	m.proxyHTTPSTraffic(clientTLS, upstreamConn, host)
+	m.proxyHTTPSTraffic(clientTLS, upstreamConn, host, flow)
+}
+
+// upstreamTLSConfig returns the configuration for a TLS connection to host under
+// the upstream TLS policy, profiles and client certificates. The verification
+// result and any client certificate presented are recorded on flow, if not nil.
+func (m *MITMHandler) upstreamTLSConfig(host string, flow *Flow) *tls.Config {
+	config := &tls.Config{
+		ServerName:   host,
+		KeyLogWriter: m.keyLogWriter,
+		// Certificates are verified by VerifyConnection under the upstream TLS policy,
+		// which also records the chain and result on the flow
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			verification, err := m.upstreamPolicy.Verify(host, cs.PeerCertificates)
+			if flow != nil {
+				flow.UpstreamTLS = verification
+			}
+			return err
+		},
+	}
+
+	m.upstreamTLS.ForHost(host).apply(config)
+
+	// Present the configured client certificate if the upstream asks for one
+	if m.clientCerts != nil {
+		if clientCert := m.clientCerts.ForHost(host); clientCert != nil {
+			config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if flow != nil {
+					flow.UpstreamClientCertSubject = certificateSubject(clientCert)
+				}
+				return clientCert, nil
+			}
+		}
+	}
+	return config
+}
+
+// dialUpstreamTLS connects to hostname and performs the TLS handshake within the
+// dialer's timeout, counting handshake failures separately from dial failures.
+// The DNS, connect and handshake phases are recorded in timings, even on failure.
+func (m *MITMHandler) dialUpstreamTLS(dialer *net.Dialer, hostname string, config *tls.Config, timings *logger.Timings) (*tls.Conn, error) {
+	timer := &phaseTimer{}
+	ctx := httptrace.WithClientTrace(context.Background(), timer.trace())
+	rawConn, err := dialer.DialContext(ctx, "tcp", hostname)
+	dialTimings := timer.result()
+	timings.DNSLookup = dialTimings.DNSLookup
+	timings.TCPConnect = dialTimings.TCPConnect
+	if err != nil {
+		return nil, err
+	}
+
+	conn := tls.Client(rawConn, config)
+	conn.SetDeadline(time.Now().Add(dialer.Timeout))
+	handshakeStart := time.Now()
+	err = conn.Handshake()
+	timings.UpstreamTLS = time.Since(handshakeStart)
+	if err != nil {
+		rawConn.Close()
+		m.metrics.handshakeFailed(handshakeSideUpstream)
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// leafCertificate returns the cached certificate for host, or issues one from
+// rootCA; certificates issued before a CA reload are not reused
+func (m *MITMHandler) leafCertificate(rootCA *ca.CA, host string) (*ca.CertificateBundle, error) {
+	cert := m.certCache.Get(host)
+	if cert != nil && bytes.Equal(cert.Certificate.AuthorityKeyId, rootCA.Certificate.SubjectKeyId) {
+		return cert, nil
+	}
+
+	generationStart := time.Now()
+	leafKey, err := m.leafKey()
+	if err != nil {
+		return nil, fmt.Errorf("leaf key generation failed: %w", err)
+	}
+	cert, err = rootCA.GenerateCertificateWithKey(host, leafKey)
+	if err != nil {
+		return nil, err
+	}
+	m.metrics.observeCertGeneration(time.Since(generationStart))
+
+	m.certCache.Put(host, cert)
+	return cert, nil
+}
+
+// tunnelCONNECT relays a CONNECT request to hostname without TLS interception and
+// returns the status sent to the client (0 if none) and the bytes relayed to it
+// The upstream is dialled before hijacking so dial failures can still be reported as 502
+func (m *MITMHandler) tunnelCONNECT(w http.ResponseWriter, hostname string) (int, int64) {
+	upstreamConn, err := net.DialTimeout("tcp", hostname, upstreamDialTimeout)
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("tunnel connection failed for %s", hostname), err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return http.StatusBadGateway, 0
+	}
+	defer upstreamConn.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		m.logger.LogError("hijacking not supported", fmt.Errorf("ResponseWriter does not support hijacking"))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return http.StatusInternalServerError, 0
+	}
+
+	clientConn, clientRW, err := hijacker.Hijack()
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to hijack connection for %s", hostname), err)
+		return 0, 0
+	}
+	defer clientConn.Close()
+
+	// Track connection for graceful shutdown (if coordinator is set)
+	if m.shutdownCoordinator != nil {
+		if m.shutdownCoordinator.IsShuttingDown() {
+			m.logger.LogInfo(fmt.Sprintf("Rejecting CONNECT to %s - shutdown in progress", hostname))
+			return 0, 0
+		}
+
+		connID := m.shutdownCoordinator.TrackConnection(clientConn)
+		defer m.shutdownCoordinator.UntrackConnection(connID)
+	}
+
+	if _, err := clientConn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to send CONNECT response for %s", hostname), err)
+		return 0, 0
+	}
+
+	m.logger.LogInfo(fmt.Sprintf("Tunnelling %s without interception (outside CA name constraints)", hostname))
+
+	// Bytes the client pipelined after the CONNECT request (e.g. an early
+	// ClientHello) were already read into the server's buffer
+	if buffered := clientRW.Reader.Buffered(); buffered > 0 {
+		early, _ := clientRW.Reader.Peek(buffered)
+		if _, err := upstreamConn.Write(early); err != nil {
+			m.logger.LogError(fmt.Sprintf("failed to relay early data to %s", hostname), err)
+			return http.StatusOK, 0
+		}
+	}
+
+	return http.StatusOK, relayTunnel(clientConn, upstreamConn)
+}
+
+// tunnelClientHello relays a client routed to a tunnel by its ClientHello to hostname,
+// replaying the bytes already read from it (see helloRecorder), and returns the
+// bytes relayed to the client
+func (m *MITMHandler) tunnelClientHello(clientConn net.Conn, hostname string, recorded []byte) (int64, error) {
+	upstreamConn, err := net.DialTimeout("tcp", hostname, upstreamDialTimeout)
+	if err != nil {
+		m.logger.LogError(fmt.Sprintf("tunnel connection failed for %s", hostname), err)
+		return 0, fmt.Errorf("tunnel connection failed: %w", err)
+	}
+	defer upstreamConn.Close()
+
+	if _, err := upstreamConn.Write(recorded); err != nil {
+		m.logger.LogError(fmt.Sprintf("failed to replay ClientHello to %s", hostname), err)
+		return 0, fmt.Errorf("failed to replay ClientHello: %w", err)
+	}
+
+	return relayTunnel(clientConn, upstreamConn), nil
+}
+
+// relayTunnel copies bytes in both directions until either side closes and
+// returns the number of bytes relayed from the upstream to the client
+func relayTunnel(clientConn, upstreamConn net.Conn) int64 {
+	var relayed int64
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstreamConn, clientConn)
+		done <- struct{}{}
+	}()
+	go func() {
+		relayed, _ = io.Copy(clientConn, upstreamConn)
+		done <- struct{}{}
+	}()
+	<-done
+
+	// Unblock the other direction so the count is final
+	clientConn.SetDeadline(time.Now())
+	upstreamConn.SetDeadline(time.Now())
+	<-done
+	return relayed
 }
 
 // proxyHTTPSTraffic handles the bidirectional proxy of decrypted HTTPS traffic
+// conn describes the connection; each exchange is reported as a copy of it
 // Implements T037-T041, T045
-func (m *MITMHandler) proxyHTTPSTraffic(clientConn *tls.Conn, upstreamConn *tls.Conn, hostname string) {
+func (m *MITMHandler) proxyHTTPSTraffic(clientConn *tls.Conn, upstreamConn *tls.Conn, hostname string, conn *Flow) {
 	// T037: Read HTTP request from decrypted client connection
 	clientReader := bufio.NewReader(clientConn)
 
@@ -195,25 +621,44 @@ func (m *MITMHandler) proxyHTTPSTraffic(clientConn *tls.Conn, upstreamConn *tls.
 		m.logger.LogError(fmt.Sprintf("failed to read HTTPS request from client for %s", hostname), err)
 		return
 	}
+	start := time.Now()
 
-	// DEBUG: Log request details
-	contentLength := req.ContentLength
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] %s %s %s (Content-Length: %d)",
-		req.Method, hostname, req.URL.Path, contentLength))
+	m.logger.Debug("intercepted request", "method", req.Method, "host", hostname,
+		"path", req.URL.Path, "content_length", req.ContentLength)
+
+	// The first exchange's trace also records how the connection was set up
+	span := startRequestSpan(m.tracer, req, "https", conn.Host, start)
+	traceConnection(span, conn, nil)
 
 	// Check if this is a WebSocket upgrade request
 	if isWebSocketUpgrade(req) {
-		m.logger.LogInfo(fmt.Sprintf("[DEBUG] WebSocket upgrade detected for %s, creating tunnel", hostname))
-		m.handleWebSocketUpgrade(clientConn, upstreamConn, req, hostname)
+		m.logger.Debug("websocket upgrade detected", "host", hostname)
+		m.handleWebSocketUpgrade(clientConn, upstreamConn, req, hostname, conn, start, span)
 		return
 	}
 
-	// T038: Inject custom header (same as HTTP interception)
-	req.Header.Set(ProxyHeaderName, ProxyHeaderValue)
+	// Answer from a local file instead of the upstream if a map local rule matches
+	if handled, err := m.serveLocal(clientConn, req, hostname, conn, start, conn.Timings, span); handled {
+		if err == nil {
+			m.handleKeepAlive(clientConn, upstreamConn, clientReader, hostname, conn)
+		}
+		return
+	}
 
 	// Remove hop-by-hop headers
 	removeHopByHopHeaders(req.Header)
 
+	// T038: Rewrite headers as for HTTP interception (the default rule injects the custom header)
+	m.headerRules.ApplyRequest(req, hostname)
+
+	// Pause at a matching breakpoint; the exchange may end there
+	if done, reuse := m.breakRequest(clientConn, req, hostname, conn, start, conn.Timings, span); done {
+		if reuse {
+			m.handleKeepAlive(clientConn, upstreamConn, clientReader, hostname, conn)
+		}
+		return
+	}
+
 	// Ensure request URL is properly formatted for upstream
 	// For HTTPS, the request URI is typically relative (e.g., "/path")
 	req.RequestURI = ""
@@ -228,50 +673,67 @@ func (m *MITMHandler) proxyHTTPSTraffic(clientConn *tls.Conn, upstreamConn *tls.
 
 	// T039: Write request to upstream TLS connection
 	// Use httputil.DumpRequestOut for proper body handling (includes body streaming)
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] Dumping request for %s (method: %s, content-length: %d)",
-		hostname, req.Method, req.ContentLength))
-
+	reqBody := countRequestBody(req)
 	reqDump, err := httputil.DumpRequestOut(req, true)
 	if err != nil {
 		m.logger.LogError(fmt.Sprintf("failed to dump request for %s", hostname), err)
+		endRequestSpan(span, 0, err)
 		return
 	}
 
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] Dumped %d bytes, writing to upstream %s", len(reqDump), hostname))
+	m.logger.Debug("writing request to upstream", "host", hostname, "bytes", len(reqDump))
 
 	// Write the complete request (headers + body) to upstream
+	// The connection phases were timed when it was established
+	timings := conn.Timings
+	upstreamStart := time.Now()
 	written, err := upstreamConn.Write(reqDump)
 	if err != nil {
 		m.logger.LogError(fmt.Sprintf("failed to write request to upstream for %s (wrote %d/%d bytes)",
 			hostname, written, len(reqDump)), err)
+		endRequestSpan(span, 0, err)
 		return
 	}
-
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] Successfully wrote %d bytes to upstream %s, waiting for response",
-		written, hostname))
+	sent := time.Now()
+	timings.RequestSend = sent.Sub(start)
 
 	// T040: Read response from upstream and extract status code
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] Reading response from upstream %s", hostname))
 
-	upstreamReader := bufio.NewReader(upstreamConn)
+	firstByte := &firstByteReader{r: upstreamConn}
+	upstreamReader := bufio.NewReader(firstByte)
 	resp, err := http.ReadResponse(upstreamReader, req)
 	if err != nil {
 		m.logger.LogError(fmt.Sprintf("failed to read response from upstream for %s (after writing %d bytes)",
 			hostname, written), err)
+		endRequestSpan(span, 0, err)
 		return
 	}
 	defer resp.Body.Close()
+	m.metrics.observeUpstreamLatency("https", time.Since(upstreamStart))
+	timings.TimeToFirstByte = firstByte.at.Sub(sent)
 
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] Got response %d from upstream %s (content-length: %d)",
-		resp.StatusCode, hostname, resp.ContentLength))
-
-	// T045: Log HTTPS request (hostname and status code)
-	m.logger.LogRequest(hostname, resp.StatusCode)
+	m.logger.Debug("upstream response", "host", hostname, "status", resp.StatusCode,
+		"content_length", resp.ContentLength)
 
 	// T041: Relay response to client TLS connection
 	// Clear write deadline to allow large response bodies
+	m.headerRules.ApplyResponse(resp.Header, req, hostname)
+	if m.breakResponse(resp, req, hostname, conn, span) {
+		return
+	}
 	clientConn.SetWriteDeadline(time.Time{})
-	if err := resp.Write(clientConn); err != nil {
+	body := &countingReader{ReadCloser: resp.Body}
+	resp.Body = body
+	transferStart := time.Now()
+	err = resp.Write(clientConn)
+	timings.BodyTransfer = time.Since(transferStart)
+
+	// T045: Log HTTPS request (hostname and status code)
+	m.completeFlow(conn, hostname, req, resp.StatusCode, timings)
+	m.logAccess(conn, req, resp.StatusCode, body.n, start, timings)
+	endRequestSpan(span, resp.StatusCode, err)
+	m.metrics.observeExchange("https", req.Method, resp.StatusCode, reqBody.n, body.n)
+	if err != nil {
 		// Check if error is due to client closing connection (expected for some cases)
 		if strings.Contains(err.Error(), "broken pipe") ||
 			strings.Contains(err.Error(), "connection reset") ||
@@ -285,11 +747,11 @@ func (m *MITMHandler) proxyHTTPSTraffic(clientConn *tls.Conn, upstreamConn *tls.
 
 	// Handle additional requests on the same connection (HTTP keep-alive)
 	// This is a simplified implementation - production code would need a full bidirectional relay
-	m.handleKeepAlive(clientConn, upstreamConn, clientReader, hostname)
+	m.handleKeepAlive(clientConn, upstreamConn, clientReader, hostname, conn)
 }
 
 // handleKeepAlive handles multiple HTTP requests on the same TLS connection
-func (m *MITMHandler) handleKeepAlive(clientConn *tls.Conn, upstreamConn *tls.Conn, clientReader *bufio.Reader, hostname string) {
+func (m *MITMHandler) handleKeepAlive(clientConn *tls.Conn, upstreamConn *tls.Conn, clientReader *bufio.Reader, hostname string, conn *Flow) {
 	// Set a short read deadline to detect if client wants to send more requests
 	clientConn.SetReadDeadline(time.Now().Add(1 * time.Second))
 
@@ -306,14 +768,31 @@ func (m *MITMHandler) handleKeepAlive(clientConn *tls.Conn, upstreamConn *tls.Co
 
 		// Clear deadline for request processing
 		clientConn.SetReadDeadline(time.Time{})
+		start := time.Now()
+
+		m.logger.Debug("intercepted keep-alive request", "method", req.Method, "host", hostname,
+			"path", req.URL.Path, "content_length", req.ContentLength)
+		span := startRequestSpan(m.tracer, req, "https", conn.Host, start)
 
-		// DEBUG: Log keep-alive request
-		m.logger.LogInfo(fmt.Sprintf("[DEBUG] Keep-alive: %s %s %s (Content-Length: %d)",
-			req.Method, hostname, req.URL.Path, req.ContentLength))
+		// The connections were set up for an earlier request
+		if handled, err := m.serveLocal(clientConn, req, hostname, conn, start, logger.Timings{}, span); handled {
+			if err != nil {
+				return
+			}
+			clientConn.SetReadDeadline(time.Now().Add(1 * time.Second))
+			continue
+		}
 
-		// Inject header and clean up request
-		req.Header.Set(ProxyHeaderName, ProxyHeaderValue)
+		// Clean up request and rewrite headers
 		removeHopByHopHeaders(req.Header)
+		m.headerRules.ApplyRequest(req, hostname)
+		if done, reuse := m.breakRequest(clientConn, req, hostname, conn, start, logger.Timings{}, span); done {
+			if !reuse {
+				return
+			}
+			clientConn.SetReadDeadline(time.Now().Add(1 * time.Second))
+			continue
+		}
 		req.RequestURI = ""
 		req.URL.Scheme = "https"
 		req.URL.Host = hostname
@@ -324,35 +803,58 @@ func (m *MITMHandler) handleKeepAlive(clientConn *tls.Conn, upstreamConn *tls.Co
 		upstreamConn.SetReadDeadline(time.Time{})
 
 		// Forward request with proper body handling
+		reqBody := countRequestBody(req)
 		reqDump, err := httputil.DumpRequestOut(req, true)
 		if err != nil {
 			m.logger.LogError(fmt.Sprintf("failed to dump keep-alive request for %s", hostname), err)
+			endRequestSpan(span, 0, err)
 			return
 		}
 
-		m.logger.LogInfo(fmt.Sprintf("[DEBUG] Keep-alive: Writing %d bytes to upstream %s", len(reqDump), hostname))
-
+		// The connections are reused, so only the request phases are timed
+		var timings logger.Timings
+		upstreamStart := time.Now()
 		written, err := upstreamConn.Write(reqDump)
 		if err != nil {
 			m.logger.LogError(fmt.Sprintf("failed to forward keep-alive request for %s (wrote %d/%d bytes)",
 				hostname, written, len(reqDump)), err)
+			endRequestSpan(span, 0, err)
 			return
 		}
+		sent := time.Now()
+		timings.RequestSend = sent.Sub(start)
 
 		// Read response
-		upstreamReader := bufio.NewReader(upstreamConn)
+		firstByte := &firstByteReader{r: upstreamConn}
+		upstreamReader := bufio.NewReader(firstByte)
 		resp, err := http.ReadResponse(upstreamReader, req)
 		if err != nil {
 			m.logger.LogError(fmt.Sprintf("failed to read keep-alive response for %s", hostname), err)
+			endRequestSpan(span, 0, err)
 			return
 		}
-
-		// Log request
-		m.logger.LogRequest(hostname, resp.StatusCode)
+		m.metrics.observeUpstreamLatency("https", time.Since(upstreamStart))
+		timings.TimeToFirstByte = firstByte.at.Sub(sent)
 
 		// Relay response with cleared deadline
+		m.headerRules.ApplyResponse(resp.Header, req, hostname)
+		if m.breakResponse(resp, req, hostname, conn, span) {
+			resp.Body.Close()
+			return
+		}
 		clientConn.SetWriteDeadline(time.Time{})
-		if err := resp.Write(clientConn); err != nil {
+		body := &countingReader{ReadCloser: resp.Body}
+		resp.Body = body
+		transferStart := time.Now()
+		err = resp.Write(clientConn)
+		timings.BodyTransfer = time.Since(transferStart)
+
+		// Log request
+		m.completeFlow(conn, hostname, req, resp.StatusCode, timings)
+		m.logAccess(conn, req, resp.StatusCode, body.n, start, timings)
+		endRequestSpan(span, resp.StatusCode, err)
+		m.metrics.observeExchange("https", req.Method, resp.StatusCode, reqBody.n, body.n)
+		if err != nil {
 			resp.Body.Close()
 			// Don't log client-initiated disconnections as errors
 			if !strings.Contains(err.Error(), "broken pipe") &&
@@ -376,41 +878,57 @@ func isWebSocketUpgrade(req *http.Request) bool {
 }
 
 // handleWebSocketUpgrade handles WebSocket upgrade requests by creating a bidirectional tunnel
-func (m *MITMHandler) handleWebSocketUpgrade(clientConn *tls.Conn, upstreamConn *tls.Conn, req *http.Request, hostname string) {
+// start is when the upgrade request was received (for the access log); span
+// covers the upgrade, not the tunnel that follows
+func (m *MITMHandler) handleWebSocketUpgrade(clientConn *tls.Conn, upstreamConn *tls.Conn, req *http.Request, hostname string, conn *Flow, start time.Time, span *tracing.Span) {
 	// Forward the upgrade request to upstream
+	m.headerRules.ApplyRequest(req, hostname)
+	timings := conn.Timings
 	if err := req.Write(upstreamConn); err != nil {
 		m.logger.LogError(fmt.Sprintf("failed to send WebSocket upgrade request to %s", hostname), err)
+		endRequestSpan(span, 0, err)
 		return
 	}
+	sent := time.Now()
+	timings.RequestSend = sent.Sub(start)
 
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] Sent WebSocket upgrade request to %s, waiting for 101 response", hostname))
+	m.logger.Debug("sent websocket upgrade request", "host", hostname)
 
 	// Read the upgrade response
-	upstreamReader := bufio.NewReader(upstreamConn)
+	firstByte := &firstByteReader{r: upstreamConn}
+	upstreamReader := bufio.NewReader(firstByte)
 	resp, err := http.ReadResponse(upstreamReader, req)
 	if err != nil {
 		m.logger.LogError(fmt.Sprintf("failed to read WebSocket upgrade response from %s", hostname), err)
+		endRequestSpan(span, 0, err)
 		return
 	}
+	timings.TimeToFirstByte = firstByte.at.Sub(sent)
 
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] Got WebSocket upgrade response %d from %s", resp.StatusCode, hostname))
+	m.logger.Debug("websocket upgrade response", "host", hostname, "status", resp.StatusCode)
+	m.headerRules.ApplyResponse(resp.Header, req, hostname)
 
 	// Check if upgrade was successful
 	if resp.StatusCode != http.StatusSwitchingProtocols {
 		m.logger.LogError(fmt.Sprintf("WebSocket upgrade failed for %s, got status %d", hostname, resp.StatusCode), nil)
 		// Forward the error response to client
 		resp.Write(clientConn)
+		endRequestSpan(span, resp.StatusCode, nil)
 		return
 	}
 
 	// Forward the 101 response to client
 	if err := resp.Write(clientConn); err != nil {
 		m.logger.LogError(fmt.Sprintf("failed to send WebSocket upgrade response to client for %s", hostname), err)
+		endRequestSpan(span, resp.StatusCode, err)
 		return
 	}
 
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] WebSocket tunnel established for %s, starting bidirectional relay", hostname))
-	m.logger.LogRequest(hostname, resp.StatusCode)
+	m.logger.Debug("websocket tunnel established", "host", hostname)
+	m.completeFlow(conn, hostname, req, resp.StatusCode, timings)
+	m.logAccess(conn, req, resp.StatusCode, 0, start, timings)
+	endRequestSpan(span, resp.StatusCode, nil)
+	m.metrics.observeExchange("https", req.Method, resp.StatusCode, 0, 0)
 
 	// Now create a bidirectional tunnel for WebSocket frames
 	// Clear all deadlines for long-lived WebSocket connection
@@ -434,5 +952,5 @@ func (m *MITMHandler) handleWebSocketUpgrade(clientConn *tls.Conn, upstreamConn
 
 	// Wait for either direction to complete
 	<-done
-	m.logger.LogInfo(fmt.Sprintf("[DEBUG] WebSocket tunnel closed for %s", hostname))
+	m.logger.Debug("websocket tunnel closed", "host", hostname)
 }
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+const (
+	// OnboardingHost is the magic hostname answered by the proxy itself
+	// Browsing to http://gosniffer.cert/ through the proxy serves the CA downloads
+	OnboardingHost = "gosniffer.cert"
+)
+
+// OnboardingHandler serves the root CA certificate in device-friendly formats
+// together with per-platform installation instructions
+type OnboardingHandler struct {
+	ca          *ca.CA
+	upcoming    *ca.CA       // New root during a rotation grace period (nil otherwise)
+	mu          sync.RWMutex // Protects ca and upcoming (replaced by SetCA)
+	p12Password string
+	logger      *logger.Logger
+}
+
+// NewOnboardingHandler creates a handler for the certificate onboarding page
+// p12Password protects the .p12 download and is shown on the page
+func NewOnboardingHandler(rootCA *ca.CA, p12Password string, log *logger.Logger) *OnboardingHandler {
+	return &OnboardingHandler{
+		ca:          rootCA,
+		p12Password: p12Password,
+		logger:      log,
+	}
+}
+
+// SetCA replaces the served CA after a reload
+// upcoming is the new root clients should install during a rotation grace period, or nil
+func (o *OnboardingHandler) SetCA(active, upcoming *ca.CA) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ca = active
+	o.upcoming = upcoming
+}
+
+// selectCA returns the CA a request refers to: the upcoming root for ?root=upcoming
+func (o *OnboardingHandler) selectCA(r *http.Request) *ca.CA {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if r.URL.Query().Get("root") == "upcoming" && o.upcoming != nil {
+		return o.upcoming
+	}
+	return o.ca
+}
+
+// IsOnboardingRequest reports whether a proxied request targets OnboardingHost
+func IsOnboardingRequest(r *http.Request) bool {
+	host := getHostname(r)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.EqualFold(host, OnboardingHost)
+}
+
+// ServeHTTP serves the onboarding page and certificate downloads
+func (o *OnboardingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rootCA := o.selectCA(r)
+
+	switch r.URL.Path {
+	case "", "/":
+		o.serveIndex(w)
+	case "/cert/pem":
+		serveDownload(w, ca.ExportPEMFile, "application/x-pem-file", rootCA.ExportPEM())
+	case "/cert/cer":
+		serveDownload(w, ca.ExportDERFile, "application/pkix-cert", rootCA.ExportDER())
+	case "/cert/android":
+		serveDownload(w, rootCA.AndroidFilename(), "application/x-pem-file", rootCA.ExportPEM())
+	case "/cert/p12":
+		data, err := rootCA.ExportPKCS12(o.p12Password)
+		if err != nil {
+			o.logger.LogError("onboarding PKCS#12 export", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		serveDownload(w, ca.ExportPKCS12File, "application/x-pkcs12", data)
+	case "/cert/mobileconfig":
+		data, err := rootCA.ExportMobileConfig()
+		if err != nil {
+			o.logger.LogError("onboarding mobileconfig export", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		serveDownload(w, ca.ExportMobileConfigFile, "application/x-apple-aspen-config", data)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	o.logger.LogRequest(OnboardingHost+r.URL.Path, http.StatusOK)
+}
+
+// serveIndex renders the onboarding page
+func (o *OnboardingHandler) serveIndex(w http.ResponseWriter) {
+	o.mu.RLock()
+	active, upcoming := o.ca, o.upcoming
+	o.mu.RUnlock()
+
+	root := active.Root()
+	data := map[string]string{
+		"Name":            root.Subject.CommonName,
+		"Fingerprint":     ca.Fingerprint(root),
+		"NotAfter":        root.NotAfter.Format("2006-01-02"),
+		"AndroidFilename": active.AndroidFilename(),
+		"P12Password":     o.p12Password,
+	}
+	if upcoming != nil {
+		data["UpcomingFingerprint"] = ca.Fingerprint(upcoming.Root())
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := onboardingTemplate.Execute(w, data); err != nil {
+		o.logger.LogError("rendering onboarding page", err)
+	}
+}
+
+// serveDownload writes data as a file attachment
+func serveDownload(w http.ResponseWriter, filename, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// onboardingTemplate is the certificate installation page
+var onboardingTemplate = template.Must(template.New("onboarding").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>GoSniffer CA Certificate</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+code { background: #f3f3f3; padding: 0 .25em; word-break: break-all; }
+h2 { border-bottom: 1px solid #ddd; }
+</style>
+</head>
+<body>
+<h1>Install the GoSniffer CA certificate</h1>
+<p>Your traffic is passing through GoSniffer. To inspect HTTPS without certificate
+warnings, install and trust the root certificate below. Only do this on devices you own.</p>
+<p><b>{{.Name}}</b><br>SHA-256: <code>{{.Fingerprint}}</code><br>Expires: {{.NotAfter}}</p>
+{{if .UpcomingFingerprint}}
+<p><b>The CA is being rotated.</b> Please also install the new root now; it replaces the current one
+when the grace period ends. New root SHA-256: <code>{{.UpcomingFingerprint}}</code><br>
+Downloads: <a href="/cert/pem?root=upcoming">.pem</a> &middot; <a href="/cert/cer?root=upcoming">.cer</a> &middot;
+<a href="/cert/p12?root=upcoming">.p12</a> &middot; <a href="/cert/mobileconfig?root=upcoming">.mobileconfig</a> &middot;
+<a href="/cert/android?root=upcoming">Android system store</a></p>
+{{end}}
+<h2>iOS / iPadOS</h2>
+<ol>
+<li>Open <a href="/cert/mobileconfig">this profile</a> in Safari and allow the download.</li>
+<li>Settings &rarr; General &rarr; VPN &amp; Device Management &rarr; install the downloaded profile.</li>
+<li>Settings &rarr; General &rarr; About &rarr; Certificate Trust Settings &rarr; enable full trust for {{.Name}}.</li>
+</ol>
+
+<h2>Android</h2>
+<ol>
+<li>Download <a href="/cert/cer">the certificate (.cer)</a>.</li>
+<li>Settings &rarr; Security &rarr; Encryption &amp; credentials &rarr; Install a certificate &rarr; CA certificate.</li>
+<li>Apps only trust user CAs if their network security config allows it. On rooted devices or
+emulators, copy <a href="/cert/android">{{.AndroidFilename}}</a> to <code>/system/etc/security/cacerts/</code> instead.</li>
+</ol>
+
+<h2>Windows</h2>
+<ol>
+<li>Download <a href="/cert/cer">the certificate (.cer)</a> or <a href="/cert/p12">the PKCS#12 bundle (.p12)</a>{{if .P12Password}} (password: <code>{{.P12Password}}</code>){{end}}.</li>
+<li>Open it, choose Install Certificate &rarr; Local Machine &rarr; Trusted Root Certification Authorities,
+or run <code>certutil -addstore root gosniffer-ca-cert.cer</code> as administrator.</li>
+</ol>
+
+<h2>macOS</h2>
+<ol>
+<li>Download <a href="/cert/pem">the certificate (.pem)</a> or install <a href="/cert/mobileconfig">the profile</a>.</li>
+<li>Run <code>sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain gosniffer-ca-cert.pem</code>.</li>
+</ol>
+
+<h2>Linux</h2>
+<ol>
+<li>Download <a href="/cert/pem">the certificate (.pem)</a>.</li>
+<li>Debian/Ubuntu: copy it to <code>/usr/local/share/ca-certificates/gosniffer.crt</code> and run <code>sudo update-ca-certificates</code>.</li>
+<li>Fedora/RHEL: copy it to <code>/etc/pki/ca-trust/source/anchors/</code> and run <code>sudo update-ca-trust</code>.</li>
+</ol>
+
+<h2>Firefox</h2>
+<ol>
+<li>Firefox uses its own store: Settings &rarr; Privacy &amp; Security &rarr; Certificates &rarr; View Certificates &rarr; Authorities &rarr; Import
+<a href="/cert/pem">the certificate (.pem)</a> and trust it to identify websites.</li>
+</ol>
+</body>
+</html>
+`))
@@ -9,15 +9,30 @@ import (
 	"time"
 
 	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
 )
 
+// defaultServerTimeout is the read and write timeout for plain HTTP exchanges
+// (constitution error handling)
+const defaultServerTimeout = 30 * time.Second
+
 // ProxyServer represents an HTTP/HTTPS proxy server
 type ProxyServer struct {
 	addr                string
 	server              *http.Server
 	logger              *logger.Logger
-	mitmHandler         *MITMHandler // HTTPS MITM handler (nil if HTTPS not enabled)
+	mitmHandler         *MITMHandler       // HTTPS MITM handler (nil if HTTPS not enabled)
+	onboardingHandler   *OnboardingHandler // Certificate onboarding page (nil if disabled)
 	shutdownCoordinator *ShutdownCoordinator
+	accessLog           *logger.AccessLog // Access log for proxied requests (nil if disabled)
+	metrics             *Metrics          // Prometheus metrics (nil if disabled)
+	tracer              *tracing.Tracer   // Trace context and spans (nil if disabled)
+	headerRules         *HeaderRules      // Header rewriting for plain HTTP (nil rewrites nothing)
+	mapLocal            *MapLocal         // Requests answered from local files (nil if disabled)
+	mapRemote           *MapRemote        // Requests sent to other upstreams (nil if disabled)
+	breakpoints         *Breakpoints      // Flows paused for editing (nil if disabled)
+	readTimeout         time.Duration     // Server read timeout for plain HTTP requests
+	writeTimeout        time.Duration     // Server write timeout for plain HTTP responses
 	mu                  sync.Mutex
 	running             bool
 }
@@ -28,6 +43,9 @@ func NewProxyServer(addr string, logger *logger.Logger) *ProxyServer {
 		addr:                addr,
 		logger:              logger,
 		shutdownCoordinator: NewShutdownCoordinator(logger),
+		headerRules:         DefaultHeaderRules(),
+		readTimeout:         defaultServerTimeout,
+		writeTimeout:        defaultServerTimeout,
 	}
 }
 
@@ -43,9 +61,24 @@ func NewProxyServerWithMITM(addr string, logger *logger.Logger, mitmHandler *MIT
 		logger:              logger,
 		mitmHandler:         mitmHandler,
 		shutdownCoordinator: sc,
+		headerRules:         DefaultHeaderRules(),
+		readTimeout:         defaultServerTimeout,
+		writeTimeout:        defaultServerTimeout,
 	}
 }
 
+// SetTimeouts sets the server's read and write timeouts (default 30s each; 0
+// disables a timeout). Flows paused at a breakpoint are exempt. Call before Start.
+func (p *ProxyServer) SetTimeouts(read, write time.Duration) {
+	p.readTimeout = read
+	p.writeTimeout = write
+}
+
+// SetOnboardingHandler enables the certificate onboarding page on OnboardingHost
+func (p *ProxyServer) SetOnboardingHandler(h *OnboardingHandler) {
+	p.onboardingHandler = h
+}
+
 // Start starts the HTTP proxy server and begins listening for connections
 // Implements constitution Principle I: dedicated goroutine per connection
 func (p *ProxyServer) Start() error {
@@ -65,8 +98,8 @@ func (p *ProxyServer) Start() error {
 		Addr:    p.addr,
 		Handler: http.HandlerFunc(p.handleHTTP),
 		// Connection timeouts per constitution error handling
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		ReadTimeout:  p.readTimeout,
+		WriteTimeout: p.writeTimeout,
 		IdleTimeout:  120 * time.Second,
 		// Each connection gets its own goroutine (Go default behavior)
 		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
@@ -122,7 +155,7 @@ func (p *ProxyServer) Shutdown(timeout time.Duration) error {
 }
 
 // handleHTTP handles all HTTP/HTTPS proxy requests
-// Routes CONNECT requests to MITM handler, regular HTTP to HandleHTTPRequest
+// Routes CONNECT requests to MITM handler, regular HTTP to handleHTTPRequest
 func (p *ProxyServer) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	// Check if this is a CONNECT request (HTTPS MITM)
 	if r.Method == http.MethodConnect {
@@ -138,6 +171,30 @@ func (p *ProxyServer) handleHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Serve the onboarding page for the magic hostname instead of forwarding
+	if p.onboardingHandler != nil && IsOnboardingRequest(r) {
+		p.onboardingHandler.ServeHTTP(w, r)
+		return
+	}
+
 	// Handle regular HTTP requests
-	HandleHTTPRequest(w, r, p.logger)
+	if p.accessLog != nil || p.metrics != nil || p.tracer != nil {
+		p.serveRecordedHTTP(w, r)
+		return
+	}
+	handleHTTPRequest(w, r, p.logger, p.forwardOptions())
+}
+
+// forwardOptions returns how the server forwards plain HTTP requests
+func (p *ProxyServer) forwardOptions() forwardOptions {
+	return forwardOptions{tracer: p.tracer, headerRules: p.headerRules, mapLocal: p.mapLocal, mapRemote: p.mapRemote, breakpoints: p.breakpoints}
+}
+
+// SetHeaderRules replaces the header rules applied to proxied requests and
+// responses (nil disables header rewriting, including the default X-Proxied-By rule)
+func (p *ProxyServer) SetHeaderRules(rules *HeaderRules) {
+	p.headerRules = rules
+	if p.mitmHandler != nil {
+		p.mitmHandler.SetHeaderRules(rules)
+	}
 }
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// phaseTimer measures the connection and request phases reported by httptrace
+// Callbacks may run on dialer goroutines, so fields are guarded by mu.
+type phaseTimer struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	getConn      time.Time
+	gotConn      time.Time
+	reused       bool
+	wroteRequest time.Time
+	bodyStart    time.Time
+	timings      logger.Timings
+}
+
+// trace returns hooks recording into p
+// The net package reports DNS and connect phases for any dial whose context
+// carries the trace, so it also times raw dials made by the MITM.
+func (p *phaseTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			p.mark(&p.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			p.measure(&p.dnsStart, &p.timings.DNSLookup)
+		},
+		ConnectStart: func(string, string) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			// Several addresses may be tried; time from the first attempt
+			if p.connectStart.IsZero() {
+				p.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			p.measure(&p.connectStart, &p.timings.TCPConnect)
+		},
+		TLSHandshakeStart: func() {
+			p.mark(&p.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			p.measure(&p.tlsStart, &p.timings.UpstreamTLS)
+		},
+		GetConn: func(string) {
+			p.mark(&p.getConn)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.mark(&p.gotConn)
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			p.mark(&p.wroteRequest)
+			p.measure(&p.gotConn, &p.timings.RequestSend)
+		},
+		GotFirstResponseByte: func() {
+			p.measure(&p.wroteRequest, &p.timings.TimeToFirstByte)
+		},
+	}
+}
+
+// mark records the current time in t
+func (p *phaseTimer) mark(t *time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*t = time.Now()
+}
+
+// measure sets d to the time since start, if start was recorded
+func (p *phaseTimer) measure(start *time.Time, d *time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !start.IsZero() {
+		*d = time.Since(*start)
+	}
+}
+
+// startBody marks the start of relaying the response body to the client
+func (p *phaseTimer) startBody() {
+	p.mark(&p.bodyStart)
+}
+
+// endBody records the time taken to relay the response body
+func (p *phaseTimer) endBody() {
+	p.measure(&p.bodyStart, &p.timings.BodyTransfer)
+}
+
+// dial returns when a new upstream connection was requested and obtained;
+// ok is false if an idle connection was reused or none was obtained
+func (p *phaseTimer) dial() (start, end time.Time, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reused || p.getConn.IsZero() || p.gotConn.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+	return p.getConn, p.gotConn, true
+}
+
+// result returns the timings measured so far
+func (p *phaseTimer) result() logger.Timings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.timings
+}
+
+// firstByteReader records when the first byte is read from r
+type firstByteReader struct {
+	r  io.Reader
+	at time.Time
+}
+
+func (f *firstByteReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if n > 0 && f.at.IsZero() {
+		f.at = time.Now()
+	}
+	return n, err
+}
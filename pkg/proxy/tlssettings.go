@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// alpnHTTP11 is the only application protocol the proxy relays (RFC 7301 Section 6)
+const alpnHTTP11 = "http/1.1"
+
+// TLSSettings controls the TLS parameters offered on one side of the MITM
+// Zero fields use the Go defaults, except that versions default to TLS 1.2-1.3.
+// CipherSuites only apply to TLS 1.2 and below (RFC 8446 suites are not configurable).
+type TLSSettings struct {
+	MinVersion       uint16
+	MaxVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+	NextProtos       []string // ALPN protocols; only "http/1.1" can be relayed
+}
+
+// DefaultTLSSettings returns the settings used when none are configured: TLS 1.2 to 1.3
+func DefaultTLSSettings() TLSSettings {
+	return TLSSettings{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS13}
+}
+
+// ParseTLSSettings parses settings written as semicolon-separated key=value pairs:
+// min=1.0;max=1.3;ciphers=TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,...;curves=X25519,P256;alpn=http/1.1
+// Cipher suites use their Go/IANA names; keys may be omitted.
+func ParseTLSSettings(spec string) (TLSSettings, error) {
+	var s TLSSettings
+	for _, field := range strings.Split(spec, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return s, fmt.Errorf("invalid TLS setting %q (expected key=value)", field)
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "min":
+			s.MinVersion, err = parseTLSVersion(value)
+		case "max":
+			s.MaxVersion, err = parseTLSVersion(value)
+		case "ciphers":
+			s.CipherSuites, err = parseCipherSuites(value)
+		case "curves":
+			s.CurvePreferences, err = parseCurves(value)
+		case "alpn":
+			s.NextProtos = splitList(value)
+		default:
+			err = fmt.Errorf("unknown TLS setting %q (expected min, max, ciphers, curves or alpn)", key)
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+	return s, s.validate()
+}
+
+// validate checks that the settings can be used by the proxy
+func (s TLSSettings) validate() error {
+	if s.MinVersion != 0 && s.MaxVersion != 0 && s.MinVersion > s.MaxVersion {
+		return fmt.Errorf("minimum TLS version %s is above maximum %s",
+			tls.VersionName(s.MinVersion), tls.VersionName(s.MaxVersion))
+	}
+	for _, proto := range s.NextProtos {
+		if proto != alpnHTTP11 {
+			return fmt.Errorf("unsupported ALPN protocol %q: the proxy only relays %s", proto, alpnHTTP11)
+		}
+	}
+	return nil
+}
+
+// merge returns s with zero fields taken from defaults
+func (s TLSSettings) merge(defaults TLSSettings) TLSSettings {
+	if s.MinVersion == 0 {
+		s.MinVersion = defaults.MinVersion
+	}
+	if s.MaxVersion == 0 {
+		s.MaxVersion = defaults.MaxVersion
+	}
+	if s.CipherSuites == nil {
+		s.CipherSuites = defaults.CipherSuites
+	}
+	if s.CurvePreferences == nil {
+		s.CurvePreferences = defaults.CurvePreferences
+	}
+	if s.NextProtos == nil {
+		s.NextProtos = defaults.NextProtos
+	}
+	return s
+}
+
+// apply sets the settings on config
+func (s TLSSettings) apply(config *tls.Config) {
+	config.MinVersion = s.MinVersion
+	config.MaxVersion = s.MaxVersion
+	config.CipherSuites = s.CipherSuites
+	config.CurvePreferences = s.CurvePreferences
+	config.NextProtos = s.NextProtos
+}
+
+// TLSProfiles selects the TLS settings for one side of the MITM by host
+// Host patterns are as for ClientCertificates. Fields left zero in a host's
+// settings are taken from the defaults.
+type TLSProfiles struct {
+	defaults TLSSettings
+	hosts    hostMatcher[TLSSettings]
+}
+
+// NewTLSProfiles creates profiles using defaults for every host
+// Zero fields in defaults are taken from DefaultTLSSettings.
+func NewTLSProfiles(defaults TLSSettings) (*TLSProfiles, error) {
+	defaults = defaults.merge(DefaultTLSSettings())
+	if err := defaults.validate(); err != nil {
+		return nil, err
+	}
+	return &TLSProfiles{defaults: defaults}, nil
+}
+
+// Add configures settings for hosts matching pattern
+func (p *TLSProfiles) Add(pattern string, settings TLSSettings) error {
+	settings = settings.merge(p.defaults)
+	if err := settings.validate(); err != nil {
+		return fmt.Errorf("TLS settings for %s: %w", pattern, err)
+	}
+	if err := p.hosts.add(pattern, settings); err != nil {
+		return fmt.Errorf("TLS settings: %w", err)
+	}
+	return nil
+}
+
+// ForHost returns the settings for host (without port)
+func (p *TLSProfiles) ForHost(host string) TLSSettings {
+	if settings, ok := p.hosts.lookup(host); ok {
+		return settings
+	}
+	return p.defaults
+}
+
+// SetClientTLSProfiles sets the TLS settings offered to clients
+func (m *MITMHandler) SetClientTLSProfiles(profiles *TLSProfiles) {
+	m.clientTLS = profiles
+}
+
+// SetUpstreamTLSProfiles sets the TLS settings offered to upstream servers
+func (m *MITMHandler) SetUpstreamTLSProfiles(profiles *TLSProfiles) {
+	m.upstreamTLS = profiles
+}
+
+// parseTLSVersion parses "1.0" to "1.3" (optionally prefixed with "TLS")
+func parseTLSVersion(value string) (uint16, error) {
+	v := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "TLS")
+	switch strings.TrimSpace(v) {
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q (expected 1.0, 1.1, 1.2 or 1.3)", value)
+}
+
+// parseCipherSuites parses comma-separated cipher suite names
+func parseCipherSuites(value string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range splitList(value) {
+		id, ok := known[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseCurves parses comma-separated key exchange group names
+func parseCurves(value string) ([]tls.CurveID, error) {
+	var curves []tls.CurveID
+	for _, name := range splitList(value) {
+		switch strings.ToUpper(strings.ReplaceAll(name, "-", "")) {
+		case "X25519":
+			curves = append(curves, tls.X25519)
+		case "X25519MLKEM768":
+			curves = append(curves, tls.X25519MLKEM768)
+		case "P256", "CURVEP256":
+			curves = append(curves, tls.CurveP256)
+		case "P384", "CURVEP384":
+			curves = append(curves, tls.CurveP384)
+		case "P521", "CURVEP521":
+			curves = append(curves, tls.CurveP521)
+		default:
+			return nil, fmt.Errorf("unknown curve %q (expected X25519, X25519MLKEM768, P256, P384 or P521)", name)
+		}
+	}
+	return curves, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
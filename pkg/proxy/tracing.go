@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
+)
+
+// Span names
+const (
+	spanClientHandshake = "tls.client_handshake"
+	spanUpstreamConnect = "upstream.connect"
+)
+
+// SetTracer propagates W3C trace context on proxied requests and records spans
+// for them (nil disables it)
+func (p *ProxyServer) SetTracer(tracer *tracing.Tracer) {
+	p.tracer = tracer
+	if p.mitmHandler != nil {
+		p.mitmHandler.SetTracer(tracer)
+	}
+}
+
+// SetTracer propagates W3C trace context on intercepted requests and records
+// spans for their connections and exchanges (nil disables it)
+func (m *MITMHandler) SetTracer(tracer *tracing.Tracer) {
+	m.tracer = tracer
+}
+
+// startRequestSpan starts the span of a request/response exchange as a child of
+// the request's traceparent, or of a new trace if it has none, and rewrites the
+// request's traceparent so the upstream continues the trace from this span
+func startRequestSpan(tracer *tracing.Tracer, req *http.Request, scheme, host string, start time.Time) *tracing.Span {
+	if tracer == nil {
+		return nil
+	}
+
+	parent, err := tracing.ParseTraceparent(req.Header.Get(tracing.TraceparentHeader))
+	if err != nil {
+		// tracestate is meaningless without a valid traceparent
+		req.Header.Del(tracing.TracestateHeader)
+	}
+
+	span := tracer.StartAt(req.Method, tracing.SpanKindServer, parent, start)
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.scheme", scheme)
+	span.SetAttribute("url.path", req.URL.Path)
+	setServerAttributes(span, host)
+	req.Header.Set(tracing.TraceparentHeader, span.Context().Traceparent())
+	return span
+}
+
+// endRequestSpan ends an exchange span with its response status; err is set when
+// the proxy could not relay the exchange
+func endRequestSpan(span *tracing.Span, statusCode int, err error) {
+	if statusCode != 0 {
+		span.SetAttribute("http.response.status_code", statusCode)
+	}
+	if err == nil && statusCode >= http.StatusInternalServerError {
+		err = errors.New(http.StatusText(statusCode))
+	}
+	span.SetError(err)
+	span.End()
+}
+
+// traceConnection records the client handshake and upstream connect of conn as
+// children of the first exchange's span; err is the upstream dial error, if any
+func traceConnection(span *tracing.Span, conn *Flow, err error) {
+	if span == nil {
+		return
+	}
+	tracer := span.Tracer()
+
+	if !conn.clientTLSStart.IsZero() {
+		handshake := tracer.StartAt(spanClientHandshake, tracing.SpanKindInternal, span.Context(), conn.clientTLSStart)
+		handshake.SetAttribute("tls.protocol.version", tls.VersionName(conn.ClientTLSVersion))
+		if conn.JA4 != "" {
+			handshake.SetAttribute("tls.client.ja4", conn.JA4)
+		}
+		handshake.EndAt(conn.clientTLSStart.Add(conn.Timings.ClientTLS))
+	}
+
+	if !conn.upstreamStart.IsZero() {
+		connect := tracer.StartAt(spanUpstreamConnect, tracing.SpanKindClient, span.Context(), conn.upstreamStart)
+		setServerAttributes(connect, conn.Host)
+		if err == nil {
+			connect.SetAttribute("tls.protocol.version", tls.VersionName(conn.UpstreamTLSVersion))
+		}
+		connect.SetError(err)
+		connect.EndAt(conn.upstreamEnd)
+	}
+}
+
+// traceUpstreamDial records the connection dialled for a plain HTTP request, if
+// a new one was made
+func traceUpstreamDial(span *tracing.Span, timer *phaseTimer, host string) {
+	start, end, ok := timer.dial()
+	if span == nil || !ok {
+		return
+	}
+	connect := span.Tracer().StartAt(spanUpstreamConnect, tracing.SpanKindClient, span.Context(), start)
+	setServerAttributes(connect, host)
+	connect.EndAt(end)
+}
+
+// setServerAttributes records the upstream host and port of a span
+func setServerAttributes(span *tracing.Span, host string) {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		span.SetAttribute("server.address", host)
+		return
+	}
+	span.SetAttribute("server.address", name)
+	if n, err := strconv.Atoi(port); err == nil {
+		span.SetAttribute("server.port", n)
+	}
+}
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+)
+
+// spkiPinPrefix marks a SHA-256 SPKI pin, as in HPKP (RFC 7469 Section 2.4)
+const spkiPinPrefix = "sha256/"
+
+// UpstreamTLSPolicy decides how upstream server certificates are verified
+// By default every host is verified against the system roots. Extra roots can
+// be trusted, hosts can be exempted from verification, and hosts can be pinned
+// to the SHA-256 hash of a public key anywhere in their chain.
+type UpstreamTLSPolicy struct {
+	roots    *x509.CertPool // nil means the system roots
+	insecure hostMatcher[bool]
+	pins     hostMatcher[*[][sha256.Size]byte]
+	patterns map[string]*[][sha256.Size]byte // Pins by normalised pattern, for AddPin
+}
+
+// UpstreamVerification records how an upstream server certificate was checked
+type UpstreamVerification struct {
+	Chain    []*x509.Certificate // Certificates presented by the server, leaf first
+	Verified bool                // Chain is valid for the host under the trusted roots
+	Error    string              // Why the chain was not verified or was rejected
+	Insecure bool                // Host is exempt from verification
+	Pinned   bool                // A configured SPKI pin matched
+}
+
+// UpstreamCertificateError reports an upstream certificate rejected by the policy
+type UpstreamCertificateError struct {
+	Host         string
+	Verification *UpstreamVerification
+	Err          error
+}
+
+// Error describes the rejected certificate
+func (e *UpstreamCertificateError) Error() string {
+	return fmt.Sprintf("upstream certificate for %s rejected: %v", e.Host, e.Err)
+}
+
+// Unwrap returns the underlying verification error
+func (e *UpstreamCertificateError) Unwrap() error {
+	return e.Err
+}
+
+// NewUpstreamTLSPolicy creates a policy that verifies every host against the system roots
+func NewUpstreamTLSPolicy() *UpstreamTLSPolicy {
+	return &UpstreamTLSPolicy{patterns: make(map[string]*[][sha256.Size]byte)}
+}
+
+// AddRootCAs trusts certs for upstream servers in addition to the system roots
+func (p *UpstreamTLSPolicy) AddRootCAs(certs ...*x509.Certificate) {
+	if p.roots == nil {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		p.roots = pool
+	}
+	for _, cert := range certs {
+		p.roots.AddCert(cert)
+	}
+}
+
+// LoadCABundle trusts the PEM certificates in path for upstream servers
+func (p *UpstreamTLSPolicy) LoadCABundle(path string) error {
+	certs, err := ca.LoadCertificates(path)
+	if err != nil {
+		return fmt.Errorf("failed to load upstream CA bundle: %w", err)
+	}
+	p.AddRootCAs(certs...)
+	return nil
+}
+
+// AddInsecureHost exempts hosts matching pattern from certificate verification
+// The verification result is still recorded on the flow, and pins still apply.
+func (p *UpstreamTLSPolicy) AddInsecureHost(pattern string) error {
+	if err := p.insecure.add(pattern, true); err != nil {
+		return fmt.Errorf("insecure host: %w", err)
+	}
+	return nil
+}
+
+// AddPin pins hosts matching pattern to a public key: at least one certificate in
+// the chain must have this SPKI hash. pin is "sha256/<base64>" (HPKP format) or
+// the bare base64 hash; several pins may be added for the same pattern.
+func (p *UpstreamTLSPolicy) AddPin(pattern, pin string) error {
+	encoded := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(pin), spkiPinPrefix), "/")
+	hash, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("invalid pin %q (expected sha256/ followed by a base64 SHA-256 hash)", pin)
+	}
+
+	key := strings.ToLower(strings.TrimSpace(pattern))
+	pins, exists := p.patterns[key]
+	if !exists {
+		pins = &[][sha256.Size]byte{}
+		if err := p.pins.add(pattern, pins); err != nil {
+			return fmt.Errorf("pin: %w", err)
+		}
+		p.patterns[key] = pins
+	}
+	*pins = append(*pins, [sha256.Size]byte(hash))
+	return nil
+}
+
+// SPKIPin returns the pin of a certificate's public key in "sha256/<base64>" form
+func SPKIPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return spkiPinPrefix + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Verify checks the certificates presented by host (leaf first) under the policy
+// The returned verification is always set; the error is an *UpstreamCertificateError
+// if the connection must be refused.
+func (p *UpstreamTLSPolicy) Verify(host string, certs []*x509.Certificate) (*UpstreamVerification, error) {
+	result := &UpstreamVerification{Chain: certs}
+	result.Insecure = p.insecure.get(host)
+
+	reject := func(err error) (*UpstreamVerification, error) {
+		result.Error = err.Error()
+		return result, &UpstreamCertificateError{Host: host, Verification: result, Err: err}
+	}
+
+	if len(certs) == 0 {
+		return reject(errors.New("server presented no certificate"))
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         p.roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	chains, err := certs[0].Verify(opts)
+	if err != nil {
+		if !result.Insecure {
+			return reject(err)
+		}
+		result.Error = err.Error()
+	} else {
+		result.Verified = true
+	}
+
+	if pins := p.pins.get(host); pins != nil {
+		// Only verified chains vouch for their certificates; presented extras are
+		// unauthenticated and could be any public certificate, including the
+		// pinned one. Without a verified chain only the leaf is checked.
+		candidates := certs[:1]
+		if len(chains) > 0 {
+			candidates = nil
+			for _, chain := range chains {
+				candidates = append(candidates, chain...)
+			}
+		}
+		if !matchesPin(candidates, *pins) {
+			presented := make([]string, len(certs))
+			for i, cert := range certs {
+				presented[i] = SPKIPin(cert)
+			}
+			return reject(fmt.Errorf("no certificate matches the pinned public keys (presented: %s)",
+				strings.Join(presented, ", ")))
+		}
+		result.Pinned = true
+	}
+
+	return result, nil
+}
+
+// matchesPin reports whether any certificate's SPKI hash is in pins
+func matchesPin(certs []*x509.Certificate, pins [][sha256.Size]byte) bool {
+	for _, cert := range certs {
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if hash == pin {
+				return true
+			}
+		}
+	}
+	return false
+}
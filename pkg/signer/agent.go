@@ -0,0 +1,204 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// Agent serves signatures from a crypto.Signer over a Unix socket
+// It is the reference implementation of the protocol: run it as a separate
+// user or process so the CA key never enters the proxy's address space.
+// Access control relies on the socket file permissions.
+type Agent struct {
+	signer       crypto.Signer
+	publicKeyDER []byte
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup // Tracks connection handlers
+}
+
+// NewAgent creates an agent signing with signer
+func NewAgent(signer crypto.Signer) (*Agent, error) {
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	return &Agent{
+		signer:       signer,
+		publicKeyDER: publicKeyDER,
+		conns:        make(map[net.Conn]struct{}),
+	}, nil
+}
+
+// Listen creates the Unix socket at socketPath with the given permissions
+// A stale socket left by a previous run is replaced.
+func (a *Agent) Listen(socketPath string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", socketPath)
+		}
+		os.Remove(socketPath)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set permissions on %s: %w", socketPath, err)
+	}
+	return listener, nil
+}
+
+// Serve accepts connections on listener until Close is called
+// Returns nil after Close, or the accept error otherwise.
+func (a *Agent) Serve(listener net.Listener) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		listener.Close()
+		return nil
+	}
+	a.listener = listener
+	a.mu.Unlock()
+
+	agentLogger().Info("signing agent listening", "address", listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			a.mu.Lock()
+			closed := a.closed
+			a.mu.Unlock()
+			if closed || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("accept failed: %w", err)
+		}
+
+		a.mu.Lock()
+		a.conns[conn] = struct{}{}
+		a.wg.Add(1)
+		a.mu.Unlock()
+
+		go a.handle(conn)
+	}
+}
+
+// Close stops accepting connections, closes open ones and waits for handlers
+func (a *Agent) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	var err error
+	if a.listener != nil {
+		err = a.listener.Close()
+	}
+	for conn := range a.conns {
+		conn.Close()
+	}
+	a.mu.Unlock()
+
+	a.wg.Wait()
+	return err
+}
+
+// handle serves requests on one connection until it is closed
+func (a *Agent) handle(conn net.Conn) {
+	defer func() {
+		a.mu.Lock()
+		delete(a.conns, conn)
+		a.mu.Unlock()
+		conn.Close()
+		a.wg.Done()
+	}()
+
+	scanner := newScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var req request
+		if err := readMessage(scanner, &req); err != nil {
+			return
+		}
+		if err := encoder.Encode(a.process(&req)); err != nil {
+			return
+		}
+	}
+}
+
+// process executes one request
+func (a *Agent) process(req *request) *response {
+	switch req.Op {
+	case OpPublicKey:
+		return &response{PublicKey: a.publicKeyDER}
+	case OpSign:
+		hash, err := parseHash(req.Hash)
+		if err != nil {
+			return &response{Error: err.Error()}
+		}
+		if err := a.checkDigest(hash, req.Digest); err != nil {
+			agentLogger().Warn("refused signing request", "error", err)
+			return &response{Error: err.Error()}
+		}
+
+		var opts crypto.SignerOpts = hash
+		if req.PSSSaltLength != nil {
+			opts = &rsa.PSSOptions{SaltLength: *req.PSSSaltLength, Hash: hash}
+		}
+
+		signature, err := a.signer.Sign(rand.Reader, req.Digest, opts)
+		if err != nil {
+			agentLogger().Error("signing failed", "error", err)
+			return &response{Error: fmt.Sprintf("signing failed: %v", err)}
+		}
+		agentLogger().Info("signed digest", "bytes", len(req.Digest), "hash", hashName(hash))
+		return &response{Signature: signature}
+	default:
+		return &response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+	}
+}
+
+// checkDigest accepts only the signing requests certificate issuance makes: a
+// digest of a supported hash, or for Ed25519 keys the message itself. Anything
+// else (e.g. raw PKCS#1 v1.5 signing without a hash) would turn the agent into
+// a general-purpose signing oracle.
+func (a *Agent) checkDigest(hash crypto.Hash, digest []byte) error {
+	_, isEd25519 := a.signer.Public().(ed25519.PublicKey)
+	switch {
+	case isEd25519 && hash != 0:
+		return fmt.Errorf("hash %s not allowed for Ed25519 keys, which sign messages directly", hash)
+	case !isEd25519 && hash == 0:
+		return fmt.Errorf("a hash is required for %T keys", a.signer.Public())
+	case hash != 0 && len(digest) != hash.Size():
+		return fmt.Errorf("digest is %d bytes, expected %d for %s", len(digest), hash.Size(), hash)
+	}
+	return nil
+}
+
+// hashName returns a printable hash name, "none" for direct message signing
+func hashName(h crypto.Hash) string {
+	if h == 0 {
+		return "none"
+	}
+	return h.String()
+}
+
+// agentLogger returns the logger for signing agent events
+func agentLogger() *logger.Logger {
+	return logger.Default().Named("signer")
+}
@@ -0,0 +1,156 @@
+// Package signer lets the CA sign certificates with a key held by a separate
+// signing agent process, reached over a Unix socket. The proxy only ever sees
+// the public key and the signatures it asks for.
+//
+// The protocol is newline-delimited JSON: each request line gets exactly one
+// response line, and a connection may carry any number of requests.
+package signer
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	// OpPublicKey asks the agent for its public key (PKIX DER)
+	OpPublicKey = "public_key"
+
+	// OpSign asks the agent to sign a digest (or, for Ed25519, a message)
+	OpSign = "sign"
+
+	// maxMessageSize bounds one JSON line; certificates to be signed are far smaller
+	maxMessageSize = 64 * 1024
+
+	// requestTimeout bounds a full request/response exchange with the agent
+	requestTimeout = 10 * time.Second
+)
+
+// request is one message from the client to the agent
+type request struct {
+	Op     string `json:"op"`
+	Digest []byte `json:"digest,omitempty"`
+	// Hash is the crypto.Hash name (e.g. "SHA-256"); empty means the message is
+	// signed directly, as for Ed25519 (RFC 8032)
+	Hash string `json:"hash,omitempty"`
+	// PSSSaltLength is set for RSA-PSS signatures (rsa.PSSOptions)
+	PSSSaltLength *int `json:"pss_salt_length,omitempty"`
+}
+
+// response is the agent's reply to one request
+type response struct {
+	PublicKey []byte `json:"public_key,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// supportedHashes are the digests the agent accepts, by crypto.Hash name
+var supportedHashes = []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+
+// parseHash resolves a crypto.Hash name sent by the client
+func parseHash(name string) (crypto.Hash, error) {
+	if name == "" {
+		return 0, nil
+	}
+	for _, h := range supportedHashes {
+		if h.String() == name {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported hash %q", name)
+}
+
+// Client is a crypto.Signer backed by a signing agent
+// Each signature uses a fresh connection, so a Client is safe for concurrent use
+// and survives agent restarts as long as the key stays the same.
+type Client struct {
+	socketPath string
+	publicKey  crypto.PublicKey
+}
+
+// Dial connects to the agent at socketPath and fetches its public key
+func Dial(socketPath string) (*Client, error) {
+	c := &Client{socketPath: socketPath}
+
+	resp, err := c.roundTrip(&request{Op: OpPublicKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch public key from signing agent: %w", err)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing agent public key: %w", err)
+	}
+	c.publicKey = publicKey
+
+	return c, nil
+}
+
+// Public returns the agent's public key
+func (c *Client) Public() crypto.PublicKey {
+	return c.publicKey
+}
+
+// Sign asks the agent to sign digest; rand is ignored, the agent uses its own
+func (c *Client) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := &request{Op: OpSign, Digest: digest}
+	if h := opts.HashFunc(); h != 0 {
+		req.Hash = h.String()
+	}
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		saltLength := pss.SaltLength
+		req.PSSSaltLength = &saltLength
+	}
+
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("signing agent: %w", err)
+	}
+	return resp.Signature, nil
+}
+
+// roundTrip sends one request on a new connection and reads the response
+func (c *Client) roundTrip(req *request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.socketPath, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp response
+	if err := readMessage(newScanner(conn), &resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// newScanner returns a line scanner limited to maxMessageSize
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxMessageSize)
+	return scanner
+}
+
+// readMessage reads one JSON line from scanner into v
+func readMessage(scanner *bufio.Scanner, v interface{}) error {
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	return json.Unmarshal(scanner.Bytes(), v)
+}
@@ -0,0 +1,319 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// TracesPath is the OTLP/HTTP path for traces, appended to endpoints without a path
+const TracesPath = "/v1/traces"
+
+// Exporter defaults
+const (
+	DefaultServiceName   = "gosniffer"
+	DefaultBatchSize     = 512
+	DefaultQueueSize     = 2048
+	DefaultFlushInterval = 5 * time.Second
+	DefaultExportTimeout = 10 * time.Second
+)
+
+// statusCodeError is the OTLP Status.code of a failed span
+const statusCodeError = 2
+
+// instrumentationScope names the code creating the spans
+const instrumentationScope = "github.com/yourusername/go-mitmproxy/pkg/tracing"
+
+// ExporterOptions configures an Exporter; zero values select the defaults
+type ExporterOptions struct {
+	ServiceName   string        // service.name resource attribute
+	BatchSize     int           // Spans per export request
+	QueueSize     int           // Spans buffered before new ones are dropped
+	FlushInterval time.Duration // Maximum delay before queued spans are sent
+	Timeout       time.Duration // Timeout of one export request
+}
+
+// Exporter sends spans in batches to an OTLP/HTTP collector as JSON
+// (https://opentelemetry.io/docs/specs/otlp/#otlphttp)
+type Exporter struct {
+	endpoint string
+	opts     ExporterOptions
+	client   *http.Client
+	logger   atomic.Pointer[logger.Logger]
+	queue    chan *Span
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	dropped  atomic.Uint64
+}
+
+// NewExporter starts an exporter posting to endpoint, a collector URL such as
+// http://localhost:4318 (TracesPath is appended when the URL has no path)
+func NewExporter(endpoint string, opts ExporterOptions) (*Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: scheme must be http or https", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = TracesPath
+	}
+
+	if opts.ServiceName == "" {
+		opts.ServiceName = DefaultServiceName
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultExportTimeout
+	}
+
+	e := &Exporter{
+		endpoint: u.String(),
+		opts:     opts,
+		client:   &http.Client{Timeout: opts.Timeout},
+		queue:    make(chan *Span, opts.QueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// Endpoint returns the URL spans are posted to
+func (e *Exporter) Endpoint() string {
+	return e.endpoint
+}
+
+// SetLogger sets the logger for failed exports
+func (e *Exporter) SetLogger(l *logger.Logger) {
+	e.logger.Store(l)
+}
+
+// log returns the exporter's logger, or the default logger if none was set
+func (e *Exporter) log() *logger.Logger {
+	if l := e.logger.Load(); l != nil {
+		return l
+	}
+	return logger.Default().Named("tracing")
+}
+
+// Dropped returns the number of spans discarded because the queue was full
+func (e *Exporter) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// enqueue queues an ended span without blocking the proxy
+func (e *Exporter) enqueue(s *Span) {
+	select {
+	case <-e.stop:
+		e.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case e.queue <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Shutdown sends the queued spans and stops the exporter, waiting until ctx is done
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flushing spans: %w", ctx.Err())
+	}
+}
+
+// run batches queued spans until the exporter is stopped
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			e.log().Warn("span export failed", "endpoint", e.endpoint, "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= e.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+					if len(batch) >= e.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts one batch of spans
+func (e *Exporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON encoding of an ExportTraceServiceRequest
+// IDs are hex strings and 64-bit integers decimal strings, as the OTLP JSON mapping requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Flags             uint32         `json:"flags"`
+		Name              string         `json:"name"`
+		Kind              SpanKind       `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// encode converts spans to an export request for the exporter's service
+func (e *Exporter) encode(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		encoded[i] = otlpSpan{
+			TraceID:           s.context.TraceID.String(),
+			SpanID:            s.context.SpanID.String(),
+			Flags:             uint32(s.context.Flags),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.attributes),
+		}
+		if s.parent.IsValid() {
+			encoded[i].ParentSpanID = s.parent.String()
+		}
+		if s.err != "" {
+			encoded[i].Status = &otlpStatus{Code: statusCodeError, Message: s.err}
+		}
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: encodeAttributes([]Attribute{
+			{Key: "service.name", Value: e.opts.ServiceName},
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: instrumentationScope},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+// encodeAttributes converts attributes to OTLP key/values; other value types are formatted as strings
+func encodeAttributes(attributes []Attribute) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attributes))
+	for _, a := range attributes {
+		var v otlpValue
+		switch value := a.Value.(type) {
+		case string:
+			v.StringValue = &value
+		case bool:
+			v.BoolValue = &value
+		case int:
+			s := strconv.Itoa(value)
+			v.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		kvs = append(kvs, otlpKeyValue{Key: a.Key, Value: v})
+	}
+	return kvs
+}
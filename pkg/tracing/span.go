@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"time"
+)
+
+// SpanKind describes a span's role, with the values of the OTLP SpanKind enum
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1 // An operation inside the proxy
+	SpanKindServer   SpanKind = 2 // Handling a request from a client
+	SpanKindClient   SpanKind = 3 // A request to an upstream server
+)
+
+// Attribute is a span attribute; Value is a string, bool, int, int64 or float64
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts spans and hands ended, sampled spans to its exporter
+// A nil *Tracer records nothing, and the spans it returns are nil.
+type Tracer struct {
+	exporter *Exporter
+}
+
+// NewTracer creates a tracer exporting to exporter; with a nil exporter, trace
+// context is still generated and propagated but spans are discarded
+func NewTracer(exporter *Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Span is one timed operation of a trace
+// A span is not safe for concurrent use and must not be changed after it ends;
+// a nil *Span ignores all calls.
+type Span struct {
+	tracer     *Tracer
+	name       string
+	kind       SpanKind
+	context    SpanContext
+	parent     SpanID
+	start      time.Time
+	end        time.Time
+	attributes []Attribute
+	err        string
+}
+
+// Start starts a span now; see StartAt
+func (t *Tracer) Start(name string, kind SpanKind, parent SpanContext) *Span {
+	return t.StartAt(name, kind, parent, time.Now())
+}
+
+// StartAt starts a span at start as a child of parent, or as the root of a new
+// sampled trace if parent is invalid. Children keep the parent's trace flags.
+func (t *Tracer) StartAt(name string, kind SpanKind, parent SpanContext, start time.Time) *Span {
+	if t == nil {
+		return nil
+	}
+
+	s := &Span{tracer: t, name: name, kind: kind, start: start}
+	if parent.IsValid() {
+		s.context = SpanContext{TraceID: parent.TraceID, Flags: parent.Flags}
+		s.parent = parent.SpanID
+	} else {
+		s.context = SpanContext{TraceID: newTraceID(), Flags: FlagSampled}
+	}
+	s.context.SpanID = newSpanID()
+	return s
+}
+
+// Tracer returns the tracer that started the span, for starting children
+func (s *Span) Tracer() *Tracer {
+	if s == nil {
+		return nil
+	}
+	return s.tracer
+}
+
+// Context returns the span's context for propagation (zero for a nil span)
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttribute records an attribute, replacing any earlier value of key
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	for i := range s.attributes {
+		if s.attributes[i].Key == key {
+			s.attributes[i].Value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, Attribute{Key: key, Value: value})
+}
+
+// SetError marks the span as failed with err's message (nil is ignored)
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err.Error()
+}
+
+// End ends the span now; see EndAt
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt ends the span at end and exports it if it is sampled
+// Ending a span again has no effect.
+func (s *Span) EndAt(end time.Time) {
+	if s == nil || !s.end.IsZero() {
+		return
+	}
+	s.end = end
+	if s.context.IsSampled() && s.tracer.exporter != nil {
+		s.tracer.exporter.enqueue(s)
+	}
+}
@@ -0,0 +1,126 @@
+// Package tracing implements W3C Trace Context propagation and spans exported
+// to an OpenTelemetry collector as OTLP/HTTP JSON, using only the standard library
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// W3C Trace Context header names (https://www.w3.org/TR/trace-context/)
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// traceparentVersion is the only version of the traceparent header this package writes
+const traceparentVersion = "00"
+
+// FlagSampled is the trace-flags bit recording that the caller may record the trace
+const FlagSampled byte = 0x01
+
+// TraceID identifies a trace; the zero value is invalid
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace; the zero value is invalid
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether t is not all zeros
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether s is not all zeros
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext is the part of a span propagated to other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the sampled flag is set
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent formats sc as a version 00 traceparent header value
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses a traceparent header value
+// Versions after 00 are accepted as long as they start with the version 00 fields,
+// as the specification requires; version ff and all-zero IDs are rejected.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	value = strings.TrimSpace(value)
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("traceparent %q: expected version-traceid-parentid-flags", value)
+	}
+
+	version, err := decodeHex(parts[0], 1)
+	if err != nil || version[0] == 0xff {
+		return sc, fmt.Errorf("traceparent %q: invalid version", value)
+	}
+	if parts[0] == traceparentVersion && len(parts) != 4 {
+		return sc, fmt.Errorf("traceparent %q: unexpected fields after flags", value)
+	}
+
+	traceID, err := decodeHex(parts[1], len(sc.TraceID))
+	if err != nil {
+		return sc, fmt.Errorf("traceparent %q: invalid trace-id", value)
+	}
+	spanID, err := decodeHex(parts[2], len(sc.SpanID))
+	if err != nil {
+		return sc, fmt.Errorf("traceparent %q: invalid parent-id", value)
+	}
+	flags, err := decodeHex(parts[3], 1)
+	if err != nil {
+		return sc, fmt.Errorf("traceparent %q: invalid trace-flags", value)
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent %q: all-zero trace-id or parent-id", value)
+	}
+	return sc, nil
+}
+
+// decodeHex decodes exactly n bytes of lowercase hex
+func decodeHex(s string, n int) ([]byte, error) {
+	if len(s) != 2*n || strings.ToLower(s) != s {
+		return nil, fmt.Errorf("expected %d lowercase hex digits", 2*n)
+	}
+	return hex.DecodeString(s)
+}
+
+// newTraceID returns a random trace ID
+func newTraceID() TraceID {
+	var t TraceID
+	for !t.IsValid() {
+		rand.Read(t[:])
+	}
+	return t
+}
+
+// newSpanID returns a random span ID
+func newSpanID() SpanID {
+	var s SpanID
+	for !s.IsValid() {
+		rand.Read(s[:])
+	}
+	return s
+}
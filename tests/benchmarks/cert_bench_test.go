@@ -106,3 +106,49 @@ func BenchmarkRootCAGenerationECDSA(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkCertificateSigningWithPooledKey benchmarks leaf issuance when keys come from a KeyPool
+// Measures the hot path cost when key generation happens in the background
+func BenchmarkCertificateSigningWithPooledKey(b *testing.B) {
+	rootCA, err := ca.GenerateCA("rsa")
+	if err != nil {
+		b.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	pool, err := ca.NewKeyPool("ecdsa", ca.DefaultKeyPoolSize)
+	if err != nil {
+		b.Fatalf("Failed to create key pool: %v", err)
+	}
+	defer pool.Stop()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key, err := pool.Get()
+		if err != nil {
+			b.Fatalf("Failed to get pooled key: %v", err)
+		}
+		if _, err := rootCA.GenerateCertificateWithKey("example.com", key); err != nil {
+			b.Fatalf("Failed to sign certificate: %v", err)
+		}
+	}
+}
+
+// BenchmarkCertificateSigningWithSharedKey benchmarks leaf issuance with one reused key
+func BenchmarkCertificateSigningWithSharedKey(b *testing.B) {
+	rootCA, err := ca.GenerateCA("rsa")
+	if err != nil {
+		b.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	key, err := ca.GenerateKey("rsa")
+	if err != nil {
+		b.Fatalf("Failed to generate shared key: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rootCA.GenerateCertificateWithKey("example.com", key); err != nil {
+			b.Fatalf("Failed to sign certificate: %v", err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestAccessLogFormats tests the built-in and template formats, including
+// sanitising of injected line breaks and quotes
+func TestAccessLogFormats(t *testing.T) {
+	entry := logger.AccessEntry{
+		Time:        time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		ClientAddr:  "192.0.2.1:51234",
+		Method:      "GET",
+		URL:         "https://example.com/a?q=1",
+		Proto:       "HTTP/1.1",
+		Host:        "example.com:443",
+		Status:      200,
+		Bytes:       512,
+		Duration:    1500 * time.Microsecond,
+		Referer:     "https://example.com/",
+		UserAgent:   "evil\r\n127.0.0.1 - - \"forged\"",
+		Intercepted: true,
+		JA4:         "t13d1516h2_8daaf6152771_e5627efa2ab1",
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{logger.AccessFormatCommon,
+			`192.0.2.1 - - [04/Mar/2026:05:06:07 +0000] "GET https://example.com/a?q=1 HTTP/1.1" 200 512`},
+		{logger.AccessFormatCombined,
+			`192.0.2.1 - - [04/Mar/2026:05:06:07 +0000] "GET https://example.com/a?q=1 HTTP/1.1" 200 512 "https://example.com/" "evil127.0.0.1 - - \"forged\""`},
+		{`{{.ClientIP}} {{.Method}} {{.Host}} {{.Status}} {{.Duration}} {{.Intercepted}} {{.JA4}}`,
+			`192.0.2.1 GET example.com:443 200 1.5ms true t13d1516h2_8daaf6152771_e5627efa2ab1`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		accessLog, err := logger.NewAccessLog(&buf, tt.format)
+		if err != nil {
+			t.Fatalf("NewAccessLog(%q) failed: %v", tt.format, err)
+		}
+		accessLog.Log(entry)
+		if got := buf.String(); got != tt.want+"\n" {
+			t.Errorf("Format %q:\n got %q\nwant %q", tt.format, got, tt.want+"\n")
+		}
+	}
+
+	var buf bytes.Buffer
+	accessLog, _ := logger.NewAccessLog(&buf, logger.AccessFormatJSON)
+	accessLog.Log(entry)
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", buf.String(), err)
+	}
+	if got["client_ip"] != "192.0.2.1" || got["status"] != float64(200) || got["duration_ms"] != 1.5 ||
+		got["intercepted"] != true || got["user_agent"] != `evil127.0.0.1 - - "forged"` {
+		t.Errorf("Unexpected JSON entry %v", got)
+	}
+
+	for _, format := range []string{"apache", "{{.Nope}}", "{{.Status"} {
+		if _, err := logger.NewAccessLog(io.Discard, format); err == nil {
+			t.Errorf("Expected NewAccessLog(%q) to fail", format)
+		}
+	}
+}
+
+// TestAccessLogProxy tests that plain HTTP and intercepted HTTPS requests are logged
+func TestAccessLogProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	upstream := httptest.NewTLSServer(handler)
+	defer upstream.Close()
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	entries, accessLog := newJSONAccessLog(t)
+
+	// HTTPS through the MITM
+	client, _ := startFlowProxy(t, "127.0.0.1:18268", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+		m.SetAccessLog(accessLog)
+	})
+	fetchURL(t, client, upstream.URL+"/secure")
+
+	// Plain HTTP
+	plainClient := startPlainProxy(t, "127.0.0.1:18269", func(p *proxy.ProxyServer) { p.SetAccessLog(accessLog) })
+	fetchURL(t, plainClient, plain.URL+"/plain")
+
+	// Entries are written once the response has been relayed
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Count(entries.String(), "\n") < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	lines := strings.Split(strings.TrimSpace(entries.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 access log entries, got %q", entries.String())
+	}
+
+	byURL := make(map[string]map[string]interface{})
+	for _, line := range lines {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Invalid JSON %q: %v", line, err)
+		}
+		byURL[e["url"].(string)] = e
+	}
+	for rawURL, intercepted := range map[string]bool{upstream.URL + "/secure": true, plain.URL + "/plain": false} {
+		e, ok := byURL[rawURL]
+		if !ok {
+			t.Errorf("Missing entry for %s in %v", rawURL, byURL)
+			continue
+		}
+		if e["method"] != "GET" || e["status"] != float64(200) || e["bytes"] != float64(5) ||
+			e["client_ip"] != "127.0.0.1" || e["intercepted"] != intercepted {
+			t.Errorf("Unexpected entry for %s: %v", rawURL, e)
+		}
+	}
+}
+
+// newJSONAccessLog returns an access log writing JSON entries to the returned buffer
+func newJSONAccessLog(t *testing.T) (*syncBuffer, *logger.AccessLog) {
+	t.Helper()
+	entries := &syncBuffer{}
+	accessLog, err := logger.NewAccessLog(entries, logger.AccessFormatJSON)
+	if err != nil {
+		t.Fatalf("NewAccessLog failed: %v", err)
+	}
+	return entries, accessLog
+}
+
+// waitAccessEntry waits for the single JSON access log entry in entries
+func waitAccessEntry(t *testing.T, entries *syncBuffer) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(entries.String(), "\n") && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	lines := strings.Split(strings.TrimSpace(entries.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 access log entry, got %q", entries.String())
+	}
+	var e map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", lines[0], err)
+	}
+	return e
+}
+
+// fetchURL fetches rawURL and discards the body
+func fetchURL(t *testing.T, client *http.Client, rawURL string) {
+	t.Helper()
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", rawURL, err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,347 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// breakpointResult is the outcome of a request made while a breakpoint is set
+type breakpointResult struct {
+	status int
+	header http.Header
+	body   string
+	err    error
+}
+
+// requestAsync sends req with client in the background
+func requestAsync(client *http.Client, req *http.Request) <-chan breakpointResult {
+	results := make(chan breakpointResult, 1)
+	go func() {
+		resp, err := client.Do(req)
+		if err != nil {
+			results <- breakpointResult{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- breakpointResult{status: resp.StatusCode, header: resp.Header, body: string(body), err: err}
+	}()
+	return results
+}
+
+// waitPaused polls the breakpoint API until a flow is paused at phase
+func waitPaused(t *testing.T, api, phase string) proxy.PausedFlow {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(api + "/flows")
+		if err != nil {
+			t.Fatalf("GET /flows failed: %v", err)
+		}
+		var list struct {
+			Flows []proxy.PausedFlow `json:"flows"`
+		}
+		json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		for _, flow := range list.Flows {
+			if flow.Phase == phase {
+				return flow
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for a flow paused at the %s phase", phase)
+	return proxy.PausedFlow{}
+}
+
+// resolveFlow posts edit to the API action of a paused flow and returns the status
+func resolveFlow(t *testing.T, api, id, action, edit string) int {
+	t.Helper()
+	resp, err := http.Post(api+"/flows/"+id+"/"+action, "application/json", strings.NewReader(edit))
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", action, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// receiveResult waits for the outcome of a request
+func receiveResult(t *testing.T, results <-chan breakpointResult) breakpointResult {
+	t.Helper()
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the paused request to finish")
+		return breakpointResult{}
+	}
+}
+
+// TestBreakpoints tests pausing, editing, replacing, dropping and timing out
+// intercepted HTTPS and plain HTTP flows through the breakpoint API
+func TestBreakpoints(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]string)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = r.Method + " " + r.Header.Get("X-Edited") + " " + string(body)
+		mu.Unlock()
+		w.Header().Set("X-Upstream", "1")
+		io.WriteString(w, "upstream "+r.URL.Path)
+	})
+	upstream := httptest.NewTLSServer(handler)
+	defer upstream.Close()
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	breakpoints := proxy.NewBreakpoints()
+	breakpoints.SetLogger(logger.NewLogger())
+	apiServer := httptest.NewServer(breakpoints)
+	defer apiServer.Close()
+	api := apiServer.URL
+	defer breakpoints.Close()
+
+	// Filters are added through the API
+	for _, filter := range []string{
+		`{"pattern": "` + upstream.URL + `/edit*", "request": true}`,
+		`{"pattern": "` + upstream.URL + `/response", "response": true}`,
+		`{"pattern": "http://` + plain.Listener.Addr().String() + `/*", "methods": ["post"], "request": true}`,
+	} {
+		resp, err := http.Post(api+"/filters", "application/json", strings.NewReader(filter))
+		if err != nil || resp.StatusCode != http.StatusCreated {
+			t.Fatalf("POST /filters %s failed: %v %v", filter, err, resp)
+		}
+		resp.Body.Close()
+	}
+
+	client, _ := startFlowProxy(t, "127.0.0.1:18282", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+		m.SetBreakpoints(breakpoints)
+	})
+
+	plainClient := startPlainProxy(t, "127.0.0.1:18283", func(p *proxy.ProxyServer) { p.SetBreakpoints(breakpoints) })
+
+	t.Run("edit request", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, upstream.URL+"/edit", strings.NewReader("original"))
+		results := requestAsync(client, req)
+
+		flow := waitPaused(t, api, proxy.BreakpointPhaseRequest)
+		if flow.Method != http.MethodPost || flow.URL != upstream.URL+"/edit" || flow.Body != "original" {
+			t.Errorf("Unexpected paused request %+v", flow)
+		}
+		if got := flow.Header.Get("X-Proxied-By"); got != "GoSniffer" {
+			t.Errorf("Expected header rules applied before the breakpoint, got %q", got)
+		}
+
+		// Status codes belong to responses
+		if status := resolveFlow(t, api, flow.ID, "resume", `{"status": 500}`); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a status on a request, got %d", status)
+		}
+		// Intercepted HTTPS requests are bound to their host
+		for _, other := range []string{plain.URL + "/edited", strings.Replace(upstream.URL, "https:", "http:", 1) + "/edited"} {
+			if status := resolveFlow(t, api, flow.ID, "resume", `{"url": "`+other+`"}`); status != http.StatusBadRequest {
+				t.Errorf("Expected 400 for a URL edit to %s, got %d", other, status)
+			}
+		}
+		edit := `{"method": "PUT", "url": "` + upstream.URL + `/edited?x=1", "header": {"X-Edited": ["yes"]}, "body": "changed"}`
+		if status := resolveFlow(t, api, flow.ID, "resume", edit); status != http.StatusNoContent {
+			t.Fatalf("Expected 204 from resume, got %d", status)
+		}
+
+		result := receiveResult(t, results)
+		if result.err != nil || result.body != "upstream /edited" {
+			t.Errorf("Expected the edited request's response, got %+v", result)
+		}
+		mu.Lock()
+		got := received["/edited"]
+		mu.Unlock()
+		if got != "PUT yes changed" {
+			t.Errorf("Expected upstream to receive the edited request, got %q", got)
+		}
+	})
+
+	t.Run("edit response", func(t *testing.T) {
+		results := requestAsync(client, mustRequest(http.MethodGet, upstream.URL+"/response"))
+		flow := waitPaused(t, api, proxy.BreakpointPhaseResponse)
+		if flow.Status != http.StatusOK || flow.Body != "upstream /response" {
+			t.Errorf("Unexpected paused response %+v", flow)
+		}
+		resolveFlow(t, api, flow.ID, "resume", `{"status": 418, "body": "edited"}`)
+		if result := receiveResult(t, results); result.status != http.StatusTeapot || result.body != "edited" ||
+			result.header.Get("X-Upstream") != "1" {
+			t.Errorf("Expected the edited response, got %+v", result)
+		}
+
+		// A replacement keeps nothing of the upstream response
+		results = requestAsync(client, mustRequest(http.MethodGet, upstream.URL+"/response"))
+		flow = waitPaused(t, api, proxy.BreakpointPhaseResponse)
+		resolveFlow(t, api, flow.ID, "replace", `{"header": {"X-Mock": ["1"]}}`)
+		if result := receiveResult(t, results); result.status != http.StatusOK || result.body != "" ||
+			result.header.Get("X-Mock") != "1" || result.header.Get("X-Upstream") != "" {
+			t.Errorf("Expected an empty replacement response, got %+v", result)
+		}
+	})
+
+	t.Run("replace and drop", func(t *testing.T) {
+		results := requestAsync(plainClient, mustRequest(http.MethodPost, plain.URL+"/replace"))
+		flow := waitPaused(t, api, proxy.BreakpointPhaseRequest)
+		resolveFlow(t, api, flow.ID, "replace", `{"status": 201, "header": {"X-Mock": ["1"]}, "body": "mocked"}`)
+		if result := receiveResult(t, results); result.status != http.StatusCreated || result.body != "mocked" {
+			t.Errorf("Expected the replacement response, got %+v", result)
+		}
+
+		results = requestAsync(plainClient, mustRequest(http.MethodPost, plain.URL+"/drop"))
+		flow = waitPaused(t, api, proxy.BreakpointPhaseRequest)
+		resolveFlow(t, api, flow.ID, "drop", "")
+		if result := receiveResult(t, results); result.err == nil {
+			t.Errorf("Expected the dropped request to fail, got %+v", result)
+		}
+
+		mu.Lock()
+		_, replaced := received["/replace"]
+		_, dropped := received["/drop"]
+		mu.Unlock()
+		if replaced || dropped {
+			t.Error("Expected replaced and dropped requests not to reach the upstream")
+		}
+
+		// Other methods are not paused
+		if result := receiveResult(t, requestAsync(plainClient, mustRequest(http.MethodGet, plain.URL+"/get"))); result.body != "upstream /get" {
+			t.Errorf("Expected GET to pass the POST-only filter, got %+v", result)
+		}
+	})
+
+	t.Run("large body", func(t *testing.T) {
+		large := strings.Repeat("a", 9<<20)
+		req, _ := http.NewRequest(http.MethodPost, upstream.URL+"/edit-large", strings.NewReader(large))
+		results := requestAsync(client, req)
+
+		flow := waitPaused(t, api, proxy.BreakpointPhaseRequest)
+		if !flow.BodyTruncated || len(flow.Body) != 8<<20 {
+			t.Errorf("Expected the body truncated to 8 MiB, got %d bytes (truncated=%v)", len(flow.Body), flow.BodyTruncated)
+		}
+		if status := resolveFlow(t, api, flow.ID, "resume", `{"body": "changed"}`); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for editing a truncated body, got %d", status)
+		}
+		resolveFlow(t, api, flow.ID, "resume", "")
+
+		if result := receiveResult(t, results); result.err != nil || result.body != "upstream /edit-large" {
+			t.Errorf("Expected the large request to be relayed, got %+v", result)
+		}
+		mu.Lock()
+		got := received["/edit-large"]
+		mu.Unlock()
+		if got != "POST  "+large {
+			t.Errorf("Expected upstream to receive the whole body, got %d bytes", len(got))
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		if err := breakpoints.SetTimeout(200*time.Millisecond, proxy.BreakpointResume); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		result := receiveResult(t, requestAsync(client, mustRequest(http.MethodGet, upstream.URL+"/edit-timeout")))
+		if result.body != "upstream /edit-timeout" || time.Since(start) < 200*time.Millisecond {
+			t.Errorf("Expected the abandoned flow to resume unchanged after the timeout, got %+v", result)
+		}
+		if status := resolveFlow(t, api, "999", "resume", ""); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for an unknown flow, got %d", status)
+		}
+	})
+}
+
+// mustRequest creates a request without a body
+func mustRequest(method, rawURL string) *http.Request {
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(nil))
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
+
+// TestBreakpointsOutlastServerTimeouts tests that plain HTTP flows paused for
+// longer than the server's read and write timeouts can still be resumed
+func TestBreakpointsOutlastServerTimeouts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, "upstream "+string(body))
+	}))
+	defer upstream.Close()
+
+	breakpoints := proxy.NewBreakpoints()
+	apiServer := httptest.NewServer(breakpoints)
+	defer apiServer.Close()
+	defer breakpoints.Close()
+	if _, err := breakpoints.AddFilter(proxy.BreakpointFilter{Pattern: upstream.URL + "/*", Request: true, Response: true}); err != nil {
+		t.Fatalf("AddFilter failed: %v", err)
+	}
+
+	const serverTimeout = 200 * time.Millisecond
+	client := startPlainProxy(t, "127.0.0.1:18285", func(p *proxy.ProxyServer) {
+		p.SetTimeouts(serverTimeout, serverTimeout)
+		p.SetBreakpoints(breakpoints)
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, upstream.URL+"/slow", strings.NewReader("body"))
+	results := requestAsync(client, req)
+	for _, phase := range []string{proxy.BreakpointPhaseRequest, proxy.BreakpointPhaseResponse} {
+		flow := waitPaused(t, apiServer.URL, phase)
+		time.Sleep(3 * serverTimeout)
+		if status := resolveFlow(t, apiServer.URL, flow.ID, "resume", ""); status != http.StatusNoContent {
+			t.Fatalf("Expected 204 from resume at the %s phase, got %d", phase, status)
+		}
+	}
+	if result := receiveResult(t, results); result.err != nil || result.body != "upstream body" {
+		t.Errorf("Expected the flow to complete after the server timeouts, got %+v", result)
+	}
+}
+
+// TestBreakpointsOutlastUpstreamIdleTimeout tests that intercepted flows paused
+// for longer than the upstream keeps an idle connection open still complete
+func TestBreakpointsOutlastUpstreamIdleTimeout(t *testing.T) {
+	const upstreamTimeout = 200 * time.Millisecond
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "upstream "+r.URL.Path)
+	}))
+	// Connections that send no request in time are closed
+	upstream.Config.ReadHeaderTimeout = upstreamTimeout
+	upstream.Config.IdleTimeout = upstreamTimeout
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	breakpoints := proxy.NewBreakpoints()
+	apiServer := httptest.NewServer(breakpoints)
+	defer apiServer.Close()
+	defer breakpoints.Close()
+	if _, err := breakpoints.AddFilter(proxy.BreakpointFilter{Pattern: upstream.URL + "/paused", Request: true}); err != nil {
+		t.Fatalf("AddFilter failed: %v", err)
+	}
+
+	client, _ := startFlowProxy(t, "127.0.0.1:18286", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+		m.SetBreakpoints(breakpoints)
+	})
+
+	results := requestAsync(client, mustRequest(http.MethodGet, upstream.URL+"/paused"))
+	flow := waitPaused(t, apiServer.URL, proxy.BreakpointPhaseRequest)
+	time.Sleep(3 * upstreamTimeout)
+	resolveFlow(t, apiServer.URL, flow.ID, "resume", "")
+	if result := receiveResult(t, results); result.err != nil || result.body != "upstream /paused" {
+		t.Errorf("Expected the paused request to reach the upstream, got %+v", result)
+	}
+
+	// Paths no filter matches pass through the same host
+	if result := receiveResult(t, requestAsync(client, mustRequest(http.MethodGet, upstream.URL+"/other"))); result.body != "upstream /other" {
+		t.Errorf("Expected an unfiltered path to pass, got %+v", result)
+	}
+}
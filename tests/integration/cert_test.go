@@ -0,0 +1,379 @@
+package integration
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestLeafKeyTypes tests leaf certificate generation for every supported key type
+func TestLeafKeyTypes(t *testing.T) {
+	rootCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCA.Certificate)
+
+	for _, keyType := range []string{"rsa", "ecdsa", "ed25519"} {
+		t.Run(keyType, func(t *testing.T) {
+			bundle, err := rootCA.GenerateCertificate("example.com", ca.KeySpec(keyType))
+			if err != nil {
+				t.Fatalf("Failed to generate %s leaf certificate: %v", keyType, err)
+			}
+
+			if got := publicKeyType(bundle.Certificate.PublicKey); got != keyType {
+				t.Errorf("Expected %s public key, got %s", keyType, got)
+			}
+
+			if _, err := bundle.Certificate.Verify(x509.VerifyOptions{
+				DNSName: "example.com",
+				Roots:   roots,
+			}); err != nil {
+				t.Errorf("Leaf certificate does not verify against root CA: %v", err)
+			}
+		})
+	}
+
+	if _, err := rootCA.GenerateCertificate("example.com", "dsa"); err == nil {
+		t.Error("Expected error for unsupported key type")
+	}
+}
+
+// TestKeySpecs tests CA generation, PEM round trip and signing for every key spec
+func TestKeySpecs(t *testing.T) {
+	wantSignature := map[ca.KeySpec]x509.SignatureAlgorithm{
+		ca.KeyRSA2048:   x509.SHA256WithRSA,
+		ca.KeyRSA3072:   x509.SHA384WithRSA,
+		ca.KeyRSA4096:   x509.SHA384WithRSA,
+		ca.KeyECDSAP256: x509.ECDSAWithSHA256,
+		ca.KeyECDSAP384: x509.ECDSAWithSHA384,
+		ca.KeyEd25519:   x509.PureEd25519,
+	}
+
+	for _, spec := range ca.SupportedKeySpecs {
+		t.Run(string(spec), func(t *testing.T) {
+			generated, err := ca.GenerateCA(spec)
+			if err != nil {
+				t.Fatalf("Failed to generate %s CA: %v", spec, err)
+			}
+
+			dir := t.TempDir()
+			certPath := filepath.Join(dir, "ca-cert.pem")
+			keyPath := filepath.Join(dir, "ca-key.pem")
+			if err := generated.SaveToPEM(certPath, keyPath); err != nil {
+				t.Fatalf("Failed to save CA: %v", err)
+			}
+			rootCA, err := ca.LoadFromPEM(certPath, keyPath)
+			if err != nil {
+				t.Fatalf("Failed to load %s CA: %v", spec, err)
+			}
+			if rootCA.KeySpec() != spec {
+				t.Errorf("Expected loaded key spec %s, got %s", spec, rootCA.KeySpec())
+			}
+			if rootCA.Certificate.SignatureAlgorithm != wantSignature[spec] {
+				t.Errorf("Expected CA signature %s, got %s", wantSignature[spec], rootCA.Certificate.SignatureAlgorithm)
+			}
+
+			bundle, err := rootCA.GenerateCertificate("example.com", ca.KeyECDSAP384)
+			if err != nil {
+				t.Fatalf("Failed to generate leaf: %v", err)
+			}
+			if bundle.Certificate.SignatureAlgorithm != wantSignature[spec] {
+				t.Errorf("Expected leaf signature %s, got %s", wantSignature[spec], bundle.Certificate.SignatureAlgorithm)
+			}
+
+			roots := x509.NewCertPool()
+			roots.AddCert(rootCA.Certificate)
+			if _, err := bundle.Certificate.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots}); err != nil {
+				t.Errorf("Leaf does not verify against %s CA: %v", spec, err)
+			}
+		})
+	}
+
+	for alias, want := range map[string]ca.KeySpec{"rsa": ca.KeyRSA2048, "ECDSA": ca.KeyECDSAP256, "rsa-4096": ca.KeyRSA4096} {
+		if got, err := ca.ParseKeySpec(alias); err != nil || got != want {
+			t.Errorf("ParseKeySpec(%q) = %s, %v; want %s", alias, got, err, want)
+		}
+	}
+	if _, err := ca.ParseKeySpec("rsa-1024"); err == nil {
+		t.Error("Expected error for rsa-1024")
+	}
+}
+
+// TestKeyPool tests that pooled keys have the configured type and are distinct
+func TestKeyPool(t *testing.T) {
+	pool, err := ca.NewKeyPool("ecdsa", 4)
+	if err != nil {
+		t.Fatalf("Failed to create key pool: %v", err)
+	}
+	defer pool.Stop()
+
+	first, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Failed to get key from pool: %v", err)
+	}
+	second, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Failed to get key from pool: %v", err)
+	}
+
+	if _, ok := first.(*ecdsa.PrivateKey); !ok {
+		t.Fatalf("Expected *ecdsa.PrivateKey, got %T", first)
+	}
+	if first.(*ecdsa.PrivateKey).Equal(second) {
+		t.Error("Key pool returned the same key twice")
+	}
+
+	if _, err := ca.NewKeyPool("dsa", 4); err == nil {
+		t.Error("Expected error for unsupported key type")
+	}
+	if _, err := ca.NewKeyPool("ecdsa", 0); err == nil {
+		t.Error("Expected error for zero pool size")
+	}
+}
+
+// TestMITMLeafKeyConfiguration tests that the MITM handler issues leaf certificates
+// with the configured key type and reuses the shared key when one is set
+func TestMITMLeafKeyConfiguration(t *testing.T) {
+	rootCA, err := ca.GenerateCA("rsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	certCache := ca.NewCertificateCache()
+	defer certCache.Stop()
+
+	sharedKey, err := ca.GenerateKey("ed25519")
+	if err != nil {
+		t.Fatalf("Failed to generate shared key: %v", err)
+	}
+
+	log := logger.NewLogger()
+	mitmHandler := proxy.NewMITMHandler(rootCA, certCache, log)
+	if err := mitmHandler.SetLeafKeyType("rsa-1024"); err == nil {
+		t.Error("Expected error for unsupported leaf key type")
+	}
+	if err := mitmHandler.SetLeafKeyType("ed25519"); err != nil {
+		t.Fatalf("Failed to set leaf key type: %v", err)
+	}
+	mitmHandler.SetSharedLeafKey(sharedKey)
+	proxyServer := proxy.NewProxyServerWithMITM("127.0.0.1:18210", log, mitmHandler)
+
+	go proxyServer.Start()
+	defer proxyServer.Shutdown(2 * time.Second)
+
+	time.Sleep(200 * time.Millisecond)
+
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		leaf := connectAndHandshake(t, "127.0.0.1:18210", host, nil)
+
+		pub, ok := leaf.PublicKey.(ed25519.PublicKey)
+		if !ok {
+			t.Fatalf("Expected Ed25519 leaf key for %s, got %T", host, leaf.PublicKey)
+		}
+		if !pub.Equal(sharedKey.(ed25519.PrivateKey).Public()) {
+			t.Errorf("Leaf certificate for %s does not use the shared key", host)
+		}
+	}
+}
+
+// TestIntermediateCAChain tests loading an intermediate CA chain and presenting
+// the intermediate so that clients trusting only the root can verify leaves
+func TestIntermediateCAChain(t *testing.T) {
+	rootCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	intermediate, err := rootCA.GenerateIntermediateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate intermediate CA: %v", err)
+	}
+
+	if _, err := intermediate.GenerateIntermediateCA("ecdsa"); err == nil {
+		t.Error("Expected error when issuing an intermediate from an intermediate")
+	}
+
+	// Round-trip through PEM files to exercise chain loading
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "intermediate-cert.pem")
+	keyPath := filepath.Join(dir, "intermediate-key.pem")
+	if err := intermediate.SaveToPEM(certPath, keyPath); err != nil {
+		t.Fatalf("Failed to save intermediate CA: %v", err)
+	}
+
+	loaded, err := ca.LoadFromPEM(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Failed to load intermediate CA: %v", err)
+	}
+	if !loaded.IsIntermediate() {
+		t.Fatal("Expected loaded CA to be an intermediate")
+	}
+	if !loaded.Root().Equal(rootCA.Certificate) {
+		t.Error("Loaded chain does not end at the root CA")
+	}
+
+	bundle, err := loaded.GenerateCertificate("example.com", "ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate leaf certificate: %v", err)
+	}
+	if len(bundle.TLSCert.Certificate) != 2 {
+		t.Fatalf("Expected leaf + intermediate in TLS chain, got %d certificates", len(bundle.TLSCert.Certificate))
+	}
+
+	// Clients trusting only the root must verify the presented chain through the proxy
+	certCache := ca.NewCertificateCache()
+	defer certCache.Stop()
+
+	log := logger.NewLogger()
+	mitmHandler := proxy.NewMITMHandler(loaded, certCache, log)
+	proxyServer := proxy.NewProxyServerWithMITM("127.0.0.1:18211", log, mitmHandler)
+
+	go proxyServer.Start()
+	defer proxyServer.Shutdown(2 * time.Second)
+
+	time.Sleep(200 * time.Millisecond)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCA.Certificate)
+	connectAndHandshake(t, "127.0.0.1:18211", "example.com", roots)
+
+	// A chain file whose certificates are out of order must be rejected
+	swapped := filepath.Join(dir, "swapped-cert.pem")
+	if err := os.WriteFile(swapped, append(rootCA.CertPEM, intermediate.CertPEM...), 0644); err != nil {
+		t.Fatalf("Failed to write swapped chain: %v", err)
+	}
+	if _, err := ca.LoadFromPEM(swapped, keyPath); err == nil {
+		t.Error("Expected error loading a chain with the root first")
+	}
+}
+
+// TestIssueCertificate tests custom CA subjects and minting multi-host leaf certificates
+func TestIssueCertificate(t *testing.T) {
+	rootCA, err := ca.GenerateCAWithOptions(ca.CAOptions{
+		KeyType:  "ecdsa",
+		Subject:  pkix.Name{CommonName: "Dev CA", Organization: []string{"Acme"}},
+		Validity: 30 * 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	if rootCA.Certificate.Subject.CommonName != "Dev CA" || rootCA.Certificate.Subject.Organization[0] != "Acme" {
+		t.Errorf("Unexpected CA subject: %s", rootCA.Certificate.Subject)
+	}
+	if remaining := time.Until(rootCA.Certificate.NotAfter); remaining > 31*24*time.Hour || remaining < 29*24*time.Hour {
+		t.Errorf("Expected 30-day CA validity, got %s", remaining)
+	}
+
+	hosts := []string{"localhost", "*.dev.test", "127.0.0.1"}
+	bundle, err := rootCA.IssueCertificate(hosts, "ecdsa", 365*24*time.Hour)
+	if err != nil {
+		t.Fatalf("IssueCertificate failed: %v", err)
+	}
+
+	// The leaf must not outlive the CA
+	if bundle.Certificate.NotAfter.After(rootCA.Certificate.NotAfter) {
+		t.Errorf("Leaf expires %s after CA %s", bundle.Certificate.NotAfter, rootCA.Certificate.NotAfter)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCA.Certificate)
+	for _, name := range []string{"localhost", "api.dev.test", "127.0.0.1"} {
+		if err := bundle.Certificate.VerifyHostname(name); err != nil {
+			t.Errorf("Expected certificate to cover %s: %v", name, err)
+		}
+	}
+	if _, err := bundle.Certificate.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+		t.Errorf("Issued certificate does not verify against CA: %v", err)
+	}
+
+	// PEM output must load as a usable TLS key pair
+	certPEM, keyPEM, err := bundle.EncodePEM()
+	if err != nil {
+		t.Fatalf("EncodePEM failed: %v", err)
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Errorf("Encoded PEM is not a valid key pair: %v", err)
+	}
+
+	if _, err := rootCA.IssueCertificate(nil, "ecdsa", time.Hour); err == nil {
+		t.Error("Expected error when issuing without hosts")
+	}
+}
+
+// connectAndHandshake issues a CONNECT through the proxy and completes the client
+// TLS handshake, returning the leaf certificate presented by the proxy.
+// The upstream does not need to exist: the proxy handshakes with the client first.
+// If roots is nil, the presented certificate is not verified.
+func connectAndHandshake(t *testing.T, proxyAddr, host string, roots *x509.CertPool) *x509.Certificate {
+	t.Helper()
+
+	tlsConn := connectTLS(t, proxyAddr, host, &tls.Config{
+		ServerName:         host,
+		RootCAs:            roots,
+		InsecureSkipVerify: roots == nil,
+	})
+	return tlsConn.ConnectionState().PeerCertificates[0]
+}
+
+// connectTLS issues a CONNECT through the proxy and completes the client TLS
+// handshake with config, returning the established connection
+func connectTLS(t *testing.T, proxyAddr, host string, config *tls.Config) *tls.Conn {
+	t.Helper()
+
+	conn, err := net.DialTimeout("tcp", proxyAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	fmt.Fprintf(conn, "CONNECT %s:443 HTTP/1.1\r\nHost: %s:443\r\n\r\n", host, host)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("Failed to read CONNECT response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 for CONNECT, got %d", resp.StatusCode)
+	}
+
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("TLS handshake with proxy failed for %s: %v", host, err)
+	}
+
+	return tlsConn
+}
+
+// publicKeyType returns the key type name for a certificate public key
+func publicKeyType(pub interface{}) string {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return "rsa"
+	case *ecdsa.PublicKey:
+		return "ecdsa"
+	case ed25519.PublicKey:
+		return "ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
@@ -0,0 +1,175 @@
+package integration
+
+import (
+	"crypto/tls"
+	"encoding/asn1"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/pkcs12"
+	"github.com/yourusername/go-mitmproxy/pkg/pkcs8"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestClientCertificatePKCS12 tests loading PKCS#12 client certificates written
+// by OpenSSL 3 (PBES2/AES-256-CBC) and OpenSSL -legacy (RC2-40 and 3DES)
+// The fixtures hold an RSA client certificate for CN=test-client, issued by
+// "Test Client CA", and are protected with the password "secret".
+func TestClientCertificatePKCS12(t *testing.T) {
+	for _, name := range []string{"client.p12", "client-legacy.p12"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", name)
+
+			cert, err := proxy.LoadClientCertificatePKCS12(path, "secret")
+			if err != nil {
+				t.Fatalf("Failed to load %s: %v", name, err)
+			}
+			if cert.Leaf == nil || cert.Leaf.Subject.CommonName != "test-client" {
+				t.Errorf("Expected leaf CN=test-client, got %v", cert.Leaf)
+			}
+			if len(cert.Certificate) != 2 {
+				t.Errorf("Expected leaf and CA certificate in chain, got %d", len(cert.Certificate))
+			}
+
+			if _, err := proxy.LoadClientCertificatePKCS12(path, "wrong"); !errors.Is(err, pkcs12.ErrIncorrectPassword) {
+				t.Errorf("Expected ErrIncorrectPassword, got %v", err)
+			}
+		})
+	}
+}
+
+// TestPKCS12IterationBounds tests that a PKCS#12 file with a crafted MAC
+// iteration count is refused before any key derivation runs
+func TestPKCS12IterationBounds(t *testing.T) {
+	type macData struct {
+		Mac        asn1.RawValue
+		MacSalt    []byte
+		Iterations int `asn1:"optional,default:1"`
+	}
+	type pfx struct {
+		Version  int
+		AuthSafe asn1.RawValue
+		MacData  macData `asn1:"optional"`
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "client-legacy.p12"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var p pfx
+	if _, err := asn1.Unmarshal(data, &p); err != nil {
+		t.Fatalf("Failed to parse fixture: %v", err)
+	}
+
+	for _, iterations := range []int{0, pkcs8.MaxIterations + 1} {
+		p.MacData.Iterations = iterations
+		crafted, err := asn1.Marshal(p)
+		if err != nil {
+			t.Fatalf("Failed to marshal crafted PFX: %v", err)
+		}
+		start := time.Now()
+		_, _, _, err = pkcs12.Decode(crafted, "secret")
+		if err == nil || errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			t.Errorf("Expected iteration count %d to be refused, got %v", iterations, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Refusing iteration count %d took %v; key derivation should not have run", iterations, elapsed)
+		}
+	}
+}
+
+// TestClientCertificateSelection tests host pattern matching for upstream client certificates
+func TestClientCertificateSelection(t *testing.T) {
+	exact, wildcard, deeper, fallback := &tls.Certificate{}, &tls.Certificate{}, &tls.Certificate{}, &tls.Certificate{}
+
+	certs := proxy.NewClientCertificates()
+	for pattern, cert := range map[string]*tls.Certificate{
+		"api.internal.example.com": exact,
+		"*.example.com":            wildcard,
+		"*.internal.example.com":   deeper,
+	} {
+		if err := certs.Add(pattern, cert); err != nil {
+			t.Fatalf("Add(%q) failed: %v", pattern, err)
+		}
+	}
+
+	tests := []struct {
+		host string
+		want *tls.Certificate
+	}{
+		{"API.internal.example.com", exact},
+		{"db.internal.example.com", deeper},
+		{"www.example.com", wildcard},
+		{"example.com", nil},
+		{"example.org", nil},
+	}
+	for _, tt := range tests {
+		if got := certs.ForHost(tt.host); got != tt.want {
+			t.Errorf("ForHost(%q) returned the wrong certificate", tt.host)
+		}
+	}
+
+	if err := certs.Add("*", fallback); err != nil {
+		t.Fatalf("Add(*) failed: %v", err)
+	}
+	if certs.ForHost("example.org") != fallback {
+		t.Error("Expected * to match any other host")
+	}
+
+	for _, pattern := range []string{"", "api.*.example.com", "*.example.com"} {
+		if err := certs.Add(pattern, exact); err == nil {
+			t.Errorf("Expected Add(%q) to fail", pattern)
+		}
+	}
+}
+
+// TestRequestClientCert tests that the proxy asks clients for a certificate only when enabled
+func TestRequestClientCert(t *testing.T) {
+	rootCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	clientCert, err := proxy.LoadClientCertificatePKCS12(filepath.Join("testdata", "client.p12"), "secret")
+	if err != nil {
+		t.Fatalf("Failed to load client certificate: %v", err)
+	}
+
+	// handshake reports whether a proxy on addr requested a client certificate
+	handshake := func(addr string, enabled bool) bool {
+		certCache := ca.NewCertificateCache()
+		defer certCache.Stop()
+
+		log := logger.NewLogger()
+		mitmHandler := proxy.NewMITMHandler(rootCA, certCache, log)
+		mitmHandler.SetRequestClientCert(enabled)
+		proxyServer := proxy.NewProxyServerWithMITM(addr, log, mitmHandler)
+
+		go proxyServer.Start()
+		defer proxyServer.Shutdown(2 * time.Second)
+
+		time.Sleep(200 * time.Millisecond)
+
+		requested := false
+		connectTLS(t, addr, "mtls.example.com", &tls.Config{
+			ServerName:         "mtls.example.com",
+			InsecureSkipVerify: true,
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				requested = true
+				return clientCert, nil
+			},
+		})
+		return requested
+	}
+
+	if handshake("127.0.0.1:18251", false) {
+		t.Error("Expected no certificate request by default")
+	}
+	if !handshake("127.0.0.1:18252", true) {
+		t.Error("Expected certificate request with SetRequestClientCert(true)")
+	}
+}
@@ -0,0 +1,224 @@
+package integration
+
+import (
+	"bufio"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// newConstrainedCA generates a CA limited to example.com (minus secret.example.com) and 10.0.0.0/8
+func newConstrainedCA(t *testing.T) *ca.CA {
+	t.Helper()
+
+	_, permittedRange, _ := net.ParseCIDR("10.0.0.0/8")
+	rootCA, err := ca.GenerateCAWithOptions(ca.CAOptions{
+		KeyType: "ecdsa",
+		NameConstraints: &ca.NameConstraints{
+			PermittedDNSDomains: []string{"example.com"},
+			ExcludedDNSDomains:  []string{"secret.example.com"},
+			PermittedIPRanges:   []*net.IPNet{permittedRange},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate constrained CA: %v", err)
+	}
+	return rootCA
+}
+
+// TestNameConstrainedCA tests CA generation with X.509 name constraints
+func TestNameConstrainedCA(t *testing.T) {
+	rootCA := newConstrainedCA(t)
+
+	if !rootCA.HasNameConstraints() {
+		t.Fatal("Expected CA certificate to carry name constraints")
+	}
+
+	cases := map[string]bool{
+		"example.com":             true,
+		"api.example.com":         true,
+		"secret.example.com":      false,
+		"db.secret.example.com":   false,
+		"notexample.com":          false,
+		"example.org":             false,
+		"10.1.2.3":                true,
+		"192.168.1.1":             false,
+		"EXAMPLE.COM":             true,
+		"deep.nested.example.com": true,
+	}
+	for host, want := range cases {
+		if got := rootCA.Permits(host); got != want {
+			t.Errorf("Permits(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	// Clients enforce the constraints: leaves outside the permitted set fail validation
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCA.Certificate)
+	for _, host := range []string{"api.example.com", "10.0.0.1", "example.org"} {
+		bundle, err := rootCA.GenerateCertificate(host, "ecdsa")
+		if err != nil {
+			t.Fatalf("Failed to generate certificate for %s: %v", host, err)
+		}
+		_, err = bundle.Certificate.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		if rootCA.Permits(host) && err != nil {
+			t.Errorf("Expected certificate for %s to verify: %v", host, err)
+		}
+		if !rootCA.Permits(host) && err == nil {
+			t.Errorf("Expected certificate for %s to fail name constraint validation", host)
+		}
+	}
+
+	if _, err := ca.GenerateCAWithOptions(ca.CAOptions{
+		KeyType:         "ecdsa",
+		NameConstraints: &ca.NameConstraints{PermittedDNSDomains: []string{"bad domain"}},
+	}); err == nil {
+		t.Error("Expected error for malformed DNS constraint")
+	}
+}
+
+// TestOutOfScopeReject tests that CONNECT to hosts outside the constraints is refused
+func TestOutOfScopeReject(t *testing.T) {
+	rootCA := newConstrainedCA(t)
+
+	certCache := ca.NewCertificateCache()
+	defer certCache.Stop()
+
+	log := logger.NewLogger()
+	mitmHandler := proxy.NewMITMHandler(rootCA, certCache, log)
+	mitmHandler.SetOutOfScopePolicy(proxy.OutOfScopeReject)
+	proxyServer := proxy.NewProxyServerWithMITM("127.0.0.1:18230", log, mitmHandler)
+
+	go proxyServer.Start()
+	defer proxyServer.Shutdown(2 * time.Second)
+
+	time.Sleep(200 * time.Millisecond)
+
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:18230", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "CONNECT example.org:443 HTTP/1.1\r\nHost: example.org:443\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("Failed to read CONNECT response: %v", err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for out-of-scope host, got %d", resp.StatusCode)
+	}
+
+	if certCache.Size() != 0 {
+		t.Error("Expected no certificate to be issued for an out-of-scope host")
+	}
+}
+
+// TestOutOfScopeTunnel tests that CONNECT to hosts outside the constraints is relayed untouched
+func TestOutOfScopeTunnel(t *testing.T) {
+	// Echo server standing in for an upstream the CA may not sign for (127.0.0.1 is outside 10.0.0.0/8)
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start echo server: %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	rootCA := newConstrainedCA(t)
+
+	certCache := ca.NewCertificateCache()
+	defer certCache.Stop()
+
+	log := logger.NewLogger()
+	mitmHandler := proxy.NewMITMHandler(rootCA, certCache, log)
+	entries, accessLog := newJSONAccessLog(t)
+	mitmHandler.SetAccessLog(accessLog)
+	proxyServer := proxy.NewProxyServerWithMITM("127.0.0.1:18231", log, mitmHandler)
+
+	go proxyServer.Start()
+	defer proxyServer.Shutdown(2 * time.Second)
+
+	time.Sleep(200 * time.Millisecond)
+
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:18231", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	target := echo.Addr().String()
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("Failed to read CONNECT response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 for tunnelled CONNECT, got %d", resp.StatusCode)
+	}
+
+	// Raw bytes must pass through unmodified (no TLS interception)
+	payload := "not a TLS handshake\n"
+	fmt.Fprint(conn, payload)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read echoed payload: %v", err)
+	}
+	if line != payload {
+		t.Errorf("Expected echoed %q, got %q", payload, line)
+	}
+
+	// The tunnel is logged, not intercepted, once the client closes it
+	conn.Close()
+	e := waitAccessEntry(t, entries)
+	if e["method"] != "CONNECT" || e["url"] != target || e["host"] != target || e["status"] != float64(200) ||
+		e["bytes"] != float64(len(payload)) || e["client_ip"] != "127.0.0.1" || e["intercepted"] != false {
+		t.Errorf("Unexpected tunnel entry: %v", e)
+	}
+
+	// Bytes pipelined in the same write as the CONNECT request must not be lost
+	early, err := net.DialTimeout("tcp", "127.0.0.1:18231", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer early.Close()
+	early.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(early, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n%s", target, target, payload)
+	earlyReader := bufio.NewReader(early)
+	resp, err = http.ReadResponse(earlyReader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("Failed to read CONNECT response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 for tunnelled CONNECT, got %d", resp.StatusCode)
+	}
+	line, err = earlyReader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read echoed pipelined payload: %v", err)
+	}
+	if line != payload {
+		t.Errorf("Expected echoed pipelined %q, got %q", payload, line)
+	}
+}
@@ -0,0 +1,172 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestUpstreamErrorPages tests that upstream failures after CONNECT are reported
+// to the client as error pages inside the intercepted TLS connection
+func TestUpstreamErrorPages(t *testing.T) {
+	untrusted := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer untrusted.Close()
+
+	// A port that refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	refused := "https://" + listener.Addr().String() + "/"
+	listener.Close()
+
+	client, flows := startFlowProxy(t, "127.0.0.1:18259", nil)
+
+	get := func(url, accept string) (*http.Response, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected error page for %s, got error: %v", url, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	t.Run("connection refused", func(t *testing.T) {
+		resp, body := get(refused, "text/html")
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("Expected 502, got %d", resp.StatusCode)
+		}
+		if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") ||
+			!strings.Contains(string(body), "Connection refused") {
+			t.Errorf("Expected HTML connection refused page, got %q", body)
+		}
+		if flow := receiveFlow(t, flows); flow.StatusCode != http.StatusBadGateway || flow.Error == "" {
+			t.Errorf("Expected flow with status 502 and error, got %d %q", flow.StatusCode, flow.Error)
+		}
+	})
+
+	t.Run("unknown host", func(t *testing.T) {
+		resp, body := get("https://gosniffer-test.invalid/", "application/json")
+		var page proxy.ErrorPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			t.Fatalf("Expected JSON error body, got %q: %v", body, err)
+		}
+		if resp.StatusCode != http.StatusBadGateway || page.Title != "Host not found" {
+			t.Errorf("Expected 502 Host not found, got %d %q", resp.StatusCode, page.Title)
+		}
+		receiveFlow(t, flows)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		resp, body := get(untrusted.URL, "application/json, text/html")
+		if resp.StatusCode != proxy.StatusUpstreamCertificateError {
+			t.Errorf("Expected 495, got %d", resp.StatusCode)
+		}
+		var page proxy.ErrorPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			t.Fatalf("Expected JSON error body, got %q: %v", body, err)
+		}
+		if len(page.Chain) == 0 || page.Chain[0].Pin != proxy.SPKIPin(untrusted.Certificate()) {
+			t.Errorf("Expected upstream chain on error page, got %+v", page.Chain)
+		}
+		if flow := receiveFlow(t, flows); flow.UpstreamTLS == nil || flow.UpstreamTLS.Verified {
+			t.Error("Expected failed upstream verification on flow")
+		}
+	})
+
+	t.Run("header rules", func(t *testing.T) {
+		// Error pages get the configured response rules, not a fixed proxy header
+		rules := proxy.NewHeaderRules()
+		for _, rule := range []proxy.HeaderRule{
+			{Direction: proxy.HeaderDirectionResponse, Action: proxy.HeaderActionSet, Header: "X-Error-Rule", Value: "applied"},
+			{Direction: proxy.HeaderDirectionResponse, Action: proxy.HeaderActionRemove, Header: "Cache-Control"},
+		} {
+			if err := rules.Add(rule); err != nil {
+				t.Fatalf("Add(%+v) failed: %v", rule, err)
+			}
+		}
+		client, _ := startFlowProxy(t, "127.0.0.1:18284", func(m *proxy.MITMHandler) {
+			m.SetHeaderRules(rules)
+		})
+
+		resp, err := client.Get(refused)
+		if err != nil {
+			t.Fatalf("Expected error page, got error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadGateway || resp.Header.Get("X-Error-Rule") != "applied" ||
+			resp.Header.Get("Cache-Control") != "" || resp.Header.Get(proxy.ProxyHeaderName) != "" {
+			t.Errorf("Expected header rules on error page, got %d %v", resp.StatusCode, resp.Header)
+		}
+	})
+}
+
+// TestCustomErrorTemplates tests loading user-supplied HTML and JSON error templates
+func TestCustomErrorTemplates(t *testing.T) {
+	dir := t.TempDir()
+	htmlPath := filepath.Join(dir, "error.html")
+	jsonPath := filepath.Join(dir, "error.json")
+	os.WriteFile(htmlPath, []byte(`<p>custom {{.StatusCode}} for {{.Host}}</p>`), 0644)
+	os.WriteFile(jsonPath, []byte(`{"code": {{.StatusCode}}, "reason": {{json .Title}}}`), 0644)
+
+	pages := proxy.NewErrorPages()
+	if err := pages.LoadHTMLTemplate(htmlPath); err != nil {
+		t.Fatalf("Failed to load HTML template: %v", err)
+	}
+	if err := pages.LoadJSONTemplate(jsonPath); err != nil {
+		t.Fatalf("Failed to load JSON template: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	refused := "https://" + listener.Addr().String() + "/"
+	listener.Close()
+
+	client, _ := startFlowProxy(t, "127.0.0.1:18260", func(m *proxy.MITMHandler) {
+		m.SetErrorPages(pages)
+	})
+
+	resp, err := client.Get(refused)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := "<p>custom 502 for " + listener.Addr().String() + "</p>"; string(body) != want {
+		t.Errorf("Expected %q, got %q", want, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, refused, nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := `{"code": 502, "reason": "Connection refused"}`; string(body) != want {
+		t.Errorf("Expected %q, got %q", want, body)
+	}
+
+	if err := proxy.NewErrorPages().LoadHTMLTemplate(filepath.Join(dir, "missing.html")); err == nil {
+		t.Error("Expected error for missing template")
+	}
+}
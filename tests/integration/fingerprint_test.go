@@ -0,0 +1,174 @@
+package integration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-mitmproxy/pkg/metrics"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestJA3 tests the JA3 string and hash against the reference example, which
+// also checks that GREASE values are ignored
+func TestJA3(t *testing.T) {
+	hello := &proxy.ClientHello{
+		Version:      tls.VersionTLS10,
+		CipherSuites: []uint16{0x0a0a, 47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4},
+		Extensions:   []uint16{0, 10, 11, 0xfafa},
+		Curves:       []tls.CurveID{23, 24, 25},
+		PointFormats: []uint8{0},
+	}
+
+	if got, want := hello.JA3String(), "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0"; got != want {
+		t.Errorf("JA3String() = %q, want %q", got, want)
+	}
+	if got, want := hello.JA3(), "ada70206e40642a3e4461f35503241d5"; got != want {
+		t.Errorf("JA3() = %q, want %q", got, want)
+	}
+}
+
+// TestJA4 tests the JA4 fingerprint against the reference example
+func TestJA4(t *testing.T) {
+	hello := &proxy.ClientHello{
+		Version: tls.VersionTLS12,
+		CipherSuites: []uint16{0x1a1a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030,
+			0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035},
+		Extensions: []uint16{0x2a2a, 0x0000, 0x0017, 0xff01, 0x000a, 0x000b, 0x0023, 0x0010, 0x0005,
+			0x000d, 0x0012, 0x0033, 0x002d, 0x002b, 0x001b, 0x0015, 0x4469},
+		SignatureSchemes:  []tls.SignatureScheme{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601},
+		ALPN:              []string{"h2", "http/1.1"},
+		ServerName:        "example.com",
+		SupportedVersions: []uint16{0x3a3a, tls.VersionTLS13, tls.VersionTLS12},
+	}
+
+	if got, want := hello.JA4(), "t13d1516h2_8daaf6152771_e5627efa2ab1"; got != want {
+		t.Errorf("JA4() = %q, want %q", got, want)
+	}
+
+	// Without SNI, ALPN or supported_versions
+	hello = &proxy.ClientHello{
+		Version:      tls.VersionTLS12,
+		CipherSuites: []uint16{0xc02f},
+		Extensions:   []uint16{0x000a},
+	}
+	if got := hello.JA4(); !strings.HasPrefix(got, "t12i010100_") {
+		t.Errorf("JA4() = %q, want prefix t12i010100_", got)
+	}
+}
+
+// TestFingerprintFlow tests that client fingerprints are recorded on flows
+// and that fingerprint rules can tunnel or block clients
+func TestFingerprintFlow(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	client, flows := startFlowProxy(t, "127.0.0.1:18261", trustUpstream(upstream.Certificate()))
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	flow := receiveFlow(t, flows)
+	if flow.ClientHello == nil || len(flow.JA3) != 32 {
+		t.Fatalf("Expected ClientHello and JA3 on flow, got %q", flow.JA3)
+	}
+	// Go clients send no SNI for IP addresses and offer TLS 1.3
+	if !strings.HasPrefix(flow.JA4, "t13i") {
+		t.Errorf("Expected JA4 starting with t13i, got %q", flow.JA4)
+	}
+	ja4 := flow.JA4
+
+	// Tunnelled and blocked clients must not cost a certificate issuance
+	expectNoIssuance := func(t *testing.T, registry *metrics.Registry) {
+		t.Helper()
+		var b strings.Builder
+		registry.WriteTo(&b)
+		if !strings.Contains(b.String(), "gosniffer_certificate_generation_seconds_count 0") {
+			t.Error("Expected no certificate to be issued")
+		}
+	}
+
+	t.Run("tunnel", func(t *testing.T) {
+		rules := proxy.NewFingerprintRules()
+		if err := rules.Add("ja4", ja4[:4]+"*", proxy.FingerprintTunnel); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		registry := metrics.NewRegistry()
+		entries, accessLog := newJSONAccessLog(t)
+		client, flows := startFlowProxy(t, "127.0.0.1:18262", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+			m.SetFingerprintRules(rules)
+			m.SetMetrics(proxy.NewMetrics(registry))
+			m.SetAccessLog(accessLog)
+		})
+
+		// A tunnelled client sees the upstream's own certificate
+		roots := x509.NewCertPool()
+		roots.AddCert(upstream.Certificate())
+		client.Transport.(*http.Transport).TLSClientConfig.RootCAs = roots
+
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("Tunnelled request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "ok" || resp.Header.Get(proxy.ProxyHeaderName) != "" {
+			t.Errorf("Expected untouched upstream response, got %q", body)
+		}
+		select {
+		case <-flows:
+			t.Error("Expected no flow for tunnelled connection")
+		default:
+		}
+		expectNoIssuance(t, registry)
+
+		// The tunnel is logged once it closes
+		client.CloseIdleConnections()
+		e := waitAccessEntry(t, entries)
+		if e["method"] != "CONNECT" || e["intercepted"] != false || e["status"] != float64(200) ||
+			e["ja4"] != ja4 || e["bytes"].(float64) < 2 {
+			t.Errorf("Unexpected tunnel entry: %v", e)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		rules := proxy.NewFingerprintRules()
+		if err := rules.Add("ja3", flow.JA3, proxy.FingerprintBlock); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		registry := metrics.NewRegistry()
+		entries, accessLog := newJSONAccessLog(t)
+		client, _ := startFlowProxy(t, "127.0.0.1:18263", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+			m.SetFingerprintRules(rules)
+			m.SetMetrics(proxy.NewMetrics(registry))
+			m.SetAccessLog(accessLog)
+		})
+
+		if resp, err := client.Get(upstream.URL); err == nil {
+			resp.Body.Close()
+			t.Error("Expected blocked client handshake to fail")
+		}
+		expectNoIssuance(t, registry)
+
+		e := waitAccessEntry(t, entries)
+		if e["method"] != "CONNECT" || e["intercepted"] != false || e["ja3"] != flow.JA3 ||
+			!strings.Contains(e["error"].(string), "fingerprint") {
+			t.Errorf("Unexpected blocked entry: %v", e)
+		}
+	})
+
+	rules := proxy.NewFingerprintRules()
+	for _, bad := range [][3]string{{"ja5", "*", "block"}, {"ja4", "[", "block"}, {"ja4", "*", "drop"}} {
+		if err := rules.Add(bad[0], bad[1], proxy.FingerprintAction(bad[2])); err == nil {
+			t.Errorf("Expected Add(%q, %q, %q) to fail", bad[0], bad[1], bad[2])
+		}
+	}
+}
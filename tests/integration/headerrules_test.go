@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// headerRulesJSON scopes rules by host, path and method in both directions
+const headerRulesJSON = `{
+  "rules": [
+    {"direction": "request", "action": "set", "header": "x-env", "value": "staging", "hosts": ["127.0.0.1", "*.example.com"]},
+    {"direction": "request", "action": "set", "header": "X-Env", "value": "never", "hosts": ["other.example"]},
+    {"direction": "request", "action": "remove", "header": "User-Agent", "path": "/api/*"},
+    {"direction": "request", "action": "add", "header": "X-Write", "value": "yes", "methods": ["post"]},
+    {"direction": "request", "action": "replace", "header": "Cookie", "pattern": "session=(\\w+)", "value": "session=redacted-$1"},
+    {"direction": "response", "action": "remove", "header": "X-Internal"},
+    {"direction": "response", "action": "replace", "header": "Set-Cookie", "pattern": "; Secure", "value": ""}
+  ]
+}`
+
+// TestHeaderRules tests that header rules are applied identically to intercepted
+// HTTPS and plain HTTP traffic, after the default X-Proxied-By rule
+func TestHeaderRules(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]http.Header)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		w.Header().Set("X-Internal", "secret")
+		w.Header().Set("Set-Cookie", "id=1; Path=/; Secure")
+		w.Write([]byte("ok"))
+	})
+	upstream := httptest.NewTLSServer(handler)
+	defer upstream.Close()
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	path := filepath.Join(t.TempDir(), "headers.json")
+	if err := os.WriteFile(path, []byte(headerRulesJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileRules, err := proxy.ReadHeaderRules(path)
+	if err != nil {
+		t.Fatalf("ReadHeaderRules failed: %v", err)
+	}
+	rules := proxy.DefaultHeaderRules()
+	for _, rule := range fileRules {
+		if err := rules.Add(rule); err != nil {
+			t.Fatalf("Add(%+v) failed: %v", rule, err)
+		}
+	}
+
+	client, _ := startFlowProxy(t, "127.0.0.1:18276", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+		m.SetHeaderRules(rules)
+	})
+
+	var httpProxy *proxy.ProxyServer
+	plainClient := startPlainProxy(t, "127.0.0.1:18277", func(p *proxy.ProxyServer) {
+		httpProxy = p
+		p.SetHeaderRules(rules)
+	})
+
+	for _, tt := range []struct {
+		name   string
+		client *http.Client
+		base   string
+		prefix string
+	}{
+		{"https", client, upstream.URL, "/secure"},
+		{"http", plainClient, plain.URL, "/plain"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, tt.base+"/api"+tt.prefix, strings.NewReader("body"))
+			req.Header.Set("User-Agent", "test-agent")
+			req.Header.Set("Cookie", "session=abc123")
+			resp, err := tt.client.Do(req)
+			if err != nil {
+				t.Fatalf("POST failed: %v", err)
+			}
+			resp.Body.Close()
+			if got := resp.Header.Get("X-Internal"); got != "" {
+				t.Errorf("Expected X-Internal to be removed, got %q", got)
+			}
+			if got := resp.Header.Get("Set-Cookie"); got != "id=1; Path=/" {
+				t.Errorf("Expected Secure to be stripped from Set-Cookie, got %q", got)
+			}
+
+			req, _ = http.NewRequest(http.MethodGet, tt.base+tt.prefix, nil)
+			req.Header.Set("User-Agent", "test-agent")
+			resp, err = tt.client.Do(req)
+			if err != nil {
+				t.Fatalf("GET failed: %v", err)
+			}
+			resp.Body.Close()
+
+			mu.Lock()
+			post, get := received["/api"+tt.prefix], received[tt.prefix]
+			mu.Unlock()
+
+			if post.Get("X-Proxied-By") != "GoSniffer" || post.Get("X-Env") != "staging" {
+				t.Errorf("Expected default and host-scoped headers, got %v", post)
+			}
+			if post.Get("User-Agent") != "" || post.Get("X-Write") != "yes" {
+				t.Errorf("Expected path- and method-scoped rules on POST /api, got %v", post)
+			}
+			if got := post.Get("Cookie"); got != "session=redacted-abc123" {
+				t.Errorf("Expected rewritten Cookie, got %q", got)
+			}
+			if get.Get("User-Agent") != "test-agent" || get.Get("X-Write") != "" {
+				t.Errorf("Expected scoped rules not to apply to GET %s, got %v", tt.prefix, get)
+			}
+		})
+	}
+
+	// Without the default rule, the proxy header is not added
+	httpProxy.SetHeaderRules(proxy.NewHeaderRules())
+	fetchURL(t, plainClient, plain.URL+"/bare")
+	mu.Lock()
+	bare := received["/bare"]
+	mu.Unlock()
+	if got := bare.Get("X-Proxied-By"); got != "" {
+		t.Errorf("Expected no X-Proxied-By with the default rule disabled, got %q", got)
+	}
+}
+
+// TestHeaderRuleValidation tests that malformed rules are rejected
+func TestHeaderRuleValidation(t *testing.T) {
+	for _, rule := range []proxy.HeaderRule{
+		{Direction: "sideways", Action: "set", Header: "X-A"},
+		{Direction: "request", Action: "append", Header: "X-A"},
+		{Direction: "request", Action: "set", Header: "X A"},
+		{Direction: "request", Action: "set", Header: "X-A", Value: "a\r\nInjected: 1"},
+		{Direction: "request", Action: "replace", Header: "X-A", Pattern: "("},
+		{Direction: "request", Action: "set", Header: "X-A", Pattern: "a"},
+		{Direction: "response", Action: "remove", Header: "X-A", Hosts: []string{"a*.example.com"}},
+	} {
+		if err := proxy.NewHeaderRules().Add(rule); err == nil {
+			t.Errorf("Expected %+v to be rejected", rule)
+		}
+	}
+}
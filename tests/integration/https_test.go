@@ -399,13 +399,15 @@ func TestHTTPSMITMErrorHandling(t *testing.T) {
 	}
 
 	// Try to connect to non-existent HTTPS server
-	_, err = client.Get("https://localhost:99999/nonexistent")
-	if err == nil {
-		t.Error("Expected error for non-existent HTTPS upstream")
+	// The proxy has already answered the CONNECT, so it reports the failure as 502
+	resp, err := client.Get("https://localhost:99999/nonexistent")
+	if err != nil {
+		t.Fatalf("Expected error page for non-existent HTTPS upstream, got error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected 502 for non-existent HTTPS upstream, got %d", resp.StatusCode)
 	}
-
-	// Error is expected - verify it's handled gracefully
-	t.Logf("Got expected error for non-existent upstream: %v", err)
 }
 
 // TestHTTPSKeepAlive tests HTTP keep-alive over HTTPS connections
@@ -0,0 +1,170 @@
+package integration
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/pkcs8"
+)
+
+// TestEncryptedCAKey tests saving and loading a passphrase-encrypted CA key
+func TestEncryptedCAKey(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	rootCA, err := ca.GenerateCAWithOptions(ca.CAOptions{KeyType: "ecdsa", KeyPassphrase: passphrase})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	if !rootCA.IsKeyEncrypted() {
+		t.Fatal("Expected KeyPEM to be encrypted")
+	}
+	if !strings.HasPrefix(string(rootCA.KeyPEM), "-----BEGIN "+pkcs8.PEMType) {
+		t.Errorf("Expected %s PEM block", pkcs8.PEMType)
+	}
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "ca-cert.pem")
+	keyPath := filepath.Join(dir, "ca-key.pem")
+	if err := rootCA.SaveToPEM(certPath, keyPath); err != nil {
+		t.Fatalf("Failed to save CA: %v", err)
+	}
+
+	if _, err := ca.LoadFromPEM(certPath, keyPath); !errors.Is(err, ca.ErrPassphraseRequired) {
+		t.Errorf("Expected ErrPassphraseRequired without a passphrase, got %v", err)
+	}
+
+	wrong := func() ([]byte, error) { return []byte("wrong"), nil }
+	if _, err := ca.LoadFromPEMWithPassphrase(certPath, keyPath, wrong); err == nil {
+		t.Error("Expected error with an incorrect passphrase")
+	}
+
+	right := func() ([]byte, error) { return passphrase, nil }
+	loaded, err := ca.LoadFromPEMWithPassphrase(certPath, keyPath, right)
+	if err != nil {
+		t.Fatalf("Failed to load encrypted CA: %v", err)
+	}
+	if _, err := loaded.GenerateCertificate("example.com", "ecdsa"); err != nil {
+		t.Errorf("Loaded CA cannot sign: %v", err)
+	}
+}
+
+// TestCAKeyFilePermissions tests that key files readable by others are refused
+func TestCAKeyFilePermissions(t *testing.T) {
+	rootCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "ca-cert.pem")
+	keyPath := filepath.Join(dir, "ca-key.pem")
+
+	// SaveToPEM must tighten a pre-existing world-readable file
+	if err := os.WriteFile(keyPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	if err := rootCA.SaveToPEM(certPath, keyPath); err != nil {
+		t.Fatalf("Failed to save CA: %v", err)
+	}
+	if _, err := ca.LoadFromPEM(certPath, keyPath); err != nil {
+		t.Fatalf("Failed to load CA with 0600 key: %v", err)
+	}
+
+	if err := os.Chmod(keyPath, 0640); err != nil {
+		t.Fatalf("Failed to chmod key file: %v", err)
+	}
+	if _, err := ca.LoadFromPEM(certPath, keyPath); err == nil {
+		t.Error("Expected key file with mode 0640 to be refused")
+	}
+}
+
+// TestEncryptedKeyParameterBounds tests that crafted PBKDF2 parameters are refused
+// before any key derivation runs
+func TestEncryptedKeyParameterBounds(t *testing.T) {
+	type kdfParams struct {
+		Salt           []byte
+		IterationCount int
+		KeyLength      int                      `asn1:"optional"`
+		PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+	}
+	type pbes2Params struct {
+		KeyDerivationFunc pkix.AlgorithmIdentifier
+		EncryptionScheme  pkix.AlgorithmIdentifier
+	}
+	type encryptedKeyInfo struct {
+		Algorithm     pkix.AlgorithmIdentifier
+		EncryptedData []byte
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	plain, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	passphrase := []byte("secret")
+	der, err := pkcs8.EncryptPrivateKey(plain, passphrase)
+	if err != nil {
+		t.Fatalf("Failed to encrypt key: %v", err)
+	}
+
+	// rewrite re-encodes der with the PBKDF2 parameters changed by edit
+	rewrite := func(edit func(*kdfParams)) []byte {
+		var info encryptedKeyInfo
+		var scheme pbes2Params
+		var kdf kdfParams
+		if _, err := asn1.Unmarshal(der, &info); err != nil {
+			t.Fatalf("Failed to parse encrypted key: %v", err)
+		}
+		if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &scheme); err != nil {
+			t.Fatalf("Failed to parse PBES2 parameters: %v", err)
+		}
+		if _, err := asn1.Unmarshal(scheme.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			t.Fatalf("Failed to parse PBKDF2 parameters: %v", err)
+		}
+		edit(&kdf)
+		marshal := func(v interface{}) asn1.RawValue {
+			b, err := asn1.Marshal(v)
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+			return asn1.RawValue{FullBytes: b}
+		}
+		scheme.KeyDerivationFunc.Parameters = marshal(kdf)
+		info.Algorithm.Parameters = marshal(scheme)
+		return marshal(info).FullBytes
+	}
+
+	if _, err := pkcs8.DecryptPrivateKey(rewrite(func(*kdfParams) {}), passphrase); err != nil {
+		t.Fatalf("Unmodified key failed to decrypt: %v", err)
+	}
+
+	cases := map[string]func(*kdfParams){
+		"huge iteration count": func(p *kdfParams) { p.IterationCount = pkcs8.MaxIterations + 1 },
+		"zero iteration count": func(p *kdfParams) { p.IterationCount = 0 },
+		"wrong key length":     func(p *kdfParams) { p.KeyLength = 16 },
+	}
+	for name, edit := range cases {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			if _, err := pkcs8.DecryptPrivateKey(rewrite(edit), passphrase); err == nil {
+				t.Fatal("Expected crafted parameters to be refused")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Refusal took %v; key derivation should not have run", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// clientRandoms returns the client randoms (second field) of NSS key log lines
+func clientRandoms(keyLog string) map[string]bool {
+	randoms := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(keyLog), "\n") {
+		if fields := strings.Fields(line); len(fields) == 3 {
+			randoms[fields[1]] = true
+		}
+	}
+	return randoms
+}
+
+// TestKeyLog tests that the secrets of both legs of concurrent MITM connections
+// are written to the key log file
+func TestKeyLog(t *testing.T) {
+	upstreamKeys := &syncBuffer{}
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	upstream.TLS = &tls.Config{KeyLogWriter: upstreamKeys}
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "keys.log")
+	keyLog, err := proxy.OpenKeyLog(path)
+	if err != nil {
+		t.Fatalf("OpenKeyLog failed: %v", err)
+	}
+	defer keyLog.Close()
+
+	client, _ := startFlowProxy(t, "127.0.0.1:18267", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+		m.SetKeyLogWriter(keyLog)
+	})
+	clientKeys := &syncBuffer{}
+	client.Transport.(*http.Transport).TLSClientConfig.KeyLogWriter = clientKeys
+
+	const requests = 5
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(upstream.URL)
+			if err != nil {
+				t.Errorf("Request failed: %v", err)
+				return
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	keyLog.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read key log: %v", err)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key log mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// NSS key log labels written by crypto/tls for TLS 1.2 and 1.3
+	labels := map[string]bool{
+		"CLIENT_RANDOM":                   true,
+		"CLIENT_HANDSHAKE_TRAFFIC_SECRET": true,
+		"SERVER_HANDSHAKE_TRAFFIC_SECRET": true,
+		"CLIENT_TRAFFIC_SECRET_0":         true,
+		"SERVER_TRAFFIC_SECRET_0":         true,
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if fields := strings.Fields(line); len(fields) != 3 || !labels[fields[0]] {
+			t.Errorf("Malformed key log line %q", line)
+		}
+	}
+
+	// Both the client-facing and the upstream handshakes of every request are logged
+	logged := clientRandoms(string(data))
+	for side, keys := range map[string]*syncBuffer{"client": clientKeys, "upstream": upstreamKeys} {
+		randoms := clientRandoms(keys.String())
+		if len(randoms) != requests {
+			t.Errorf("Expected %d %s handshakes, got %d", requests, side, len(randoms))
+		}
+		for random := range randoms {
+			if !logged[random] {
+				t.Errorf("Missing %s handshake %s in key log", side, random)
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestCAValidation tests that mismatched or unusable CAs are rejected
+func TestCAValidation(t *testing.T) {
+	rootCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	if err := rootCA.Validate(); err != nil {
+		t.Errorf("Expected freshly generated CA to validate: %v", err)
+	}
+	if warning := rootCA.ExpiryWarning(ca.ExpiryWarningThreshold); warning != "" {
+		t.Errorf("Expected no expiry warning for a new CA, got %q", warning)
+	}
+	if warning := rootCA.ExpiryWarning(11 * 365 * 24 * time.Hour); warning == "" {
+		t.Error("Expected expiry warning when threshold exceeds remaining validity")
+	}
+
+	// Pair the certificate with another CA's key
+	otherCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate second CA: %v", err)
+	}
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "ca-cert.pem")
+	keyPath := filepath.Join(dir, "ca-key.pem")
+	mismatched := &ca.CA{
+		PrivateKey:  otherCA.PrivateKey,
+		Certificate: rootCA.Certificate,
+		CertPEM:     rootCA.CertPEM,
+		KeyPEM:      otherCA.KeyPEM,
+	}
+	if err := mismatched.SaveToPEM(certPath, keyPath); err != nil {
+		t.Fatalf("Failed to save CA: %v", err)
+	}
+	if _, err := ca.LoadFromPEM(certPath, keyPath); err == nil {
+		t.Error("Expected LoadFromPEM to reject a key that does not match the certificate")
+	}
+}
+
+// TestCARotation tests rotation with and without a grace period
+func TestCARotation(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "ca-cert.pem")
+	keyPath := filepath.Join(dir, "ca-key.pem")
+
+	original, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	if err := original.SaveToPEM(certPath, keyPath); err != nil {
+		t.Fatalf("Failed to save CA: %v", err)
+	}
+
+	// During the grace period the previous CA signs and the new one is upcoming
+	rotated, err := ca.RotateCA(certPath, keyPath, ca.CAOptions{}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("RotateCA failed: %v", err)
+	}
+	if rotated.KeyType() != "ecdsa" {
+		t.Errorf("Expected rotated CA to keep key type ecdsa, got %s", rotated.KeyType())
+	}
+
+	active, upcoming, state, err := ca.LoadActive(certPath, keyPath, nil)
+	if err != nil {
+		t.Fatalf("LoadActive failed: %v", err)
+	}
+	if !state.InGracePeriod() {
+		t.Error("Expected rotation to be in its grace period")
+	}
+	if !bytes.Equal(active.Certificate.Raw, original.Certificate.Raw) {
+		t.Error("Expected previous CA to stay active during grace period")
+	}
+	if upcoming == nil || !bytes.Equal(upcoming.Certificate.Raw, rotated.Certificate.Raw) {
+		t.Error("Expected rotated CA to be upcoming during grace period")
+	}
+
+	// Rotating again during the grace period would discard the signing CA
+	if _, err := ca.RotateCA(certPath, keyPath, ca.CAOptions{KeyType: "rsa"}, 0, false); err == nil {
+		t.Error("Expected rotation during the grace period to be refused without force")
+	}
+	previousCert, _ := ca.PreviousPaths(certPath, keyPath)
+	if kept, err := ca.LoadCertificates(previousCert); err != nil || !kept[0].Equal(original.Certificate) {
+		t.Error("Expected refused rotation to keep the signing CA as the previous CA")
+	}
+
+	// A zero grace period switches immediately
+	rotatedAgain, err := ca.RotateCA(certPath, keyPath, ca.CAOptions{KeyType: "rsa"}, 0, true)
+	if err != nil {
+		t.Fatalf("RotateCA failed: %v", err)
+	}
+	active, upcoming, _, err = ca.LoadActive(certPath, keyPath, nil)
+	if err != nil {
+		t.Fatalf("LoadActive failed: %v", err)
+	}
+	if !bytes.Equal(active.Certificate.Raw, rotatedAgain.Certificate.Raw) || upcoming != nil {
+		t.Error("Expected new CA to be active immediately with zero grace period")
+	}
+	if active.KeyType() != "rsa" {
+		t.Errorf("Expected rotated CA key type rsa, got %s", active.KeyType())
+	}
+}
+
+// TestCARotationKeepsIdentity tests that rotation keeps the subject and name
+// constraints, refuses intermediates and leaves the CA in place if writing fails
+func TestCARotationKeepsIdentity(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "ca-cert.pem")
+	keyPath := filepath.Join(dir, "ca-key.pem")
+
+	original, err := ca.GenerateCAWithOptions(ca.CAOptions{
+		KeyType:         "ecdsa",
+		Subject:         pkix.Name{CommonName: "Team Test CA", Organization: []string{"Team"}},
+		NameConstraints: &ca.NameConstraints{PermittedDNSDomains: []string{"test.internal"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	if err := original.SaveToPEM(certPath, keyPath); err != nil {
+		t.Fatalf("Failed to save CA: %v", err)
+	}
+
+	// A directory in the way of the new key file makes writing it fail
+	if err := os.Mkdir(keyPath+".new", 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if _, err := ca.RotateCA(certPath, keyPath, ca.CAOptions{}, 0, false); err == nil {
+		t.Fatal("Expected rotation to fail when the new key cannot be written")
+	}
+	kept, err := ca.LoadFromPEM(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Expected current CA to stay loadable after a failed rotation: %v", err)
+	}
+	if !kept.Certificate.Equal(original.Certificate) {
+		t.Error("Expected failed rotation to leave the current CA in place")
+	}
+	os.Remove(keyPath + ".new")
+
+	// Without its rotation state the previous CA would stop signing at once, so
+	// failing to record it undoes the rotation (a non-empty directory is in the way)
+	statePath := filepath.Join(dir, "ca-rotation.json")
+	if err := os.MkdirAll(filepath.Join(statePath, "blocker"), 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if _, err := ca.RotateCA(certPath, keyPath, ca.CAOptions{}, time.Hour, false); err == nil {
+		t.Fatal("Expected rotation to fail when the rotation state cannot be written")
+	}
+	if kept, err := ca.LoadFromPEM(certPath, keyPath); err != nil || !kept.Certificate.Equal(original.Certificate) {
+		t.Errorf("Expected the current CA to be restored after a failed state write: %v", err)
+	}
+	os.RemoveAll(statePath)
+
+	rotated, err := ca.RotateCA(certPath, keyPath, ca.CAOptions{}, 0, false)
+	if err != nil {
+		t.Fatalf("RotateCA failed: %v", err)
+	}
+	if rotated.Certificate.Subject.CommonName != "Team Test CA" {
+		t.Errorf("Expected rotated CA to keep its subject, got %q", rotated.Certificate.Subject.CommonName)
+	}
+	if !rotated.HasNameConstraints() || rotated.Permits("example.com") || !rotated.Permits("api.test.internal") {
+		t.Error("Expected rotated CA to keep its name constraints")
+	}
+
+	// Intermediates cannot be rotated into self-signed roots
+	intermediate, err := original.GenerateIntermediateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate intermediate CA: %v", err)
+	}
+	intermediateCert := filepath.Join(dir, "intermediate-cert.pem")
+	intermediateKey := filepath.Join(dir, "intermediate-key.pem")
+	if err := intermediate.SaveToPEM(intermediateCert, intermediateKey); err != nil {
+		t.Fatalf("Failed to save intermediate CA: %v", err)
+	}
+	if _, err := ca.RotateCA(intermediateCert, intermediateKey, ca.CAOptions{}, 0, false); err == nil {
+		t.Error("Expected rotating an intermediate CA to be refused")
+	}
+}
+
+// TestCAReload tests swapping the CA in a running proxy
+func TestCAReload(t *testing.T) {
+	firstCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	secondCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	certCache := ca.NewCertificateCache()
+	defer certCache.Stop()
+
+	log := logger.NewLogger()
+	mitmHandler := proxy.NewMITMHandler(firstCA, certCache, log)
+	proxyServer := proxy.NewProxyServerWithMITM("127.0.0.1:18240", log, mitmHandler)
+
+	go proxyServer.Start()
+	defer proxyServer.Shutdown(2 * time.Second)
+
+	time.Sleep(200 * time.Millisecond)
+
+	firstRoots := x509.NewCertPool()
+	firstRoots.AddCert(firstCA.Certificate)
+	leaf := connectAndHandshake(t, "127.0.0.1:18240", "reload.example.com", firstRoots)
+	if !bytes.Equal(leaf.AuthorityKeyId, firstCA.Certificate.SubjectKeyId) {
+		t.Error("Expected leaf to be issued by the first CA")
+	}
+
+	mitmHandler.ReloadCA(secondCA)
+	if certCache.Size() != 0 {
+		t.Errorf("Expected certificate cache to be cleared on reload, size %d", certCache.Size())
+	}
+
+	// The same host must now be served with a certificate from the new CA
+	secondRoots := x509.NewCertPool()
+	secondRoots.AddCert(secondCA.Certificate)
+	leaf = connectAndHandshake(t, "127.0.0.1:18240", "reload.example.com", secondRoots)
+	if !bytes.Equal(leaf.AuthorityKeyId, secondCA.Certificate.SubjectKeyId) {
+		t.Error("Expected leaf to be issued by the reloaded CA")
+	}
+}
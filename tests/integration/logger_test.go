@@ -0,0 +1,126 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// TestLoggerLevels tests that entries below the configured level are dropped
+func TestLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(&buf, logger.LevelWarn, logger.FormatText)
+
+	l.Debug("debug entry")
+	l.Info("info entry")
+	l.Warn("warn entry")
+	l.Error("error entry")
+
+	out := buf.String()
+	for _, dropped := range []string{"debug entry", "info entry"} {
+		if strings.Contains(out, dropped) {
+			t.Errorf("Expected %q to be filtered, got %q", dropped, out)
+		}
+	}
+	for _, kept := range []string{"[WARN] warn entry", "[ERROR] error entry"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("Expected %q in output, got %q", kept, out)
+		}
+	}
+
+	if level, err := logger.ParseLevel("WARNING"); err != nil || level != logger.LevelWarn {
+		t.Errorf("Expected ParseLevel(WARNING) = warn, got %v, %v", level, err)
+	}
+	if _, err := logger.ParseLevel("verbose"); err == nil {
+		t.Error("Expected ParseLevel(verbose) to fail")
+	}
+	if _, err := logger.ParseFormat("xml"); err == nil {
+		t.Error("Expected ParseFormat(xml) to fail")
+	}
+}
+
+// TestLoggerText tests text entries with subsystems, fields and injected newlines
+func TestLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(&buf, logger.LevelDebug, logger.FormatText).Named("proxy").Named("mitm")
+
+	l.Info("request\nforged line", "host", "example.com", "path", "/a b", "error", errors.New("boom"))
+
+	out := buf.String()
+	if strings.Count(out, "\n") != 1 {
+		t.Fatalf("Expected a single line, got %q", out)
+	}
+	want := `[INFO] [proxy.mitm] requestforged line host=example.com path="/a b" error=boom`
+	if !strings.Contains(out, want) {
+		t.Errorf("Expected %q in %q", want, out)
+	}
+}
+
+// TestLoggerJSON tests that JSON entries are one parseable object per line
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(&buf, logger.LevelInfo, logger.FormatJSON).Named("cache")
+
+	l.Warn("evicted", "host", "a\"b.example.com", "size", 3, "error", errors.New("full"))
+	l.LogRequest("example.com", 200)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", lines[0], err)
+	}
+	want := map[string]interface{}{
+		"level": "warn", "subsystem": "cache", "msg": "evicted",
+		"host": "a\"b.example.com", "size": float64(3), "error": "full",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Error("Expected a time field")
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", lines[1], err)
+	}
+	if entry["msg"] != "request" || entry["status"] != float64(200) {
+		t.Errorf("Unexpected request entry %v", entry)
+	}
+}
+
+// TestCALogger tests that certificate generation and the cache log to injected loggers
+func TestCALogger(t *testing.T) {
+	rootCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("GenerateCA failed: %v", err)
+	}
+	var buf bytes.Buffer
+	rootCA.SetLogger(logger.New(&buf, logger.LevelInfo, logger.FormatText).Named("ca"))
+
+	if _, err := rootCA.GenerateCertificate("logged.example.com", rootCA.KeySpec()); err != nil {
+		t.Fatalf("GenerateCertificate failed: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "[ca] generated leaf certificate host=logged.example.com") {
+		t.Errorf("Expected leaf generation entry, got %q", out)
+	}
+
+	// The cache reports its lifecycle at debug level to its own logger
+	var cacheBuf bytes.Buffer
+	cache := ca.NewCertificateCache()
+	cache.SetLogger(logger.New(&cacheBuf, logger.LevelDebug, logger.FormatText).Named("cache"))
+	cache.Stop()
+	if out := cacheBuf.String(); !strings.Contains(out, "[DEBUG] [cache] stopping TTL cleanup goroutine") {
+		t.Errorf("Expected cache stop entry, got %q", out)
+	}
+}
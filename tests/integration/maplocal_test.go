@@ -0,0 +1,162 @@
+package integration
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestMapLocal tests that matching intercepted HTTPS and plain HTTP requests are
+// answered from local files, with conditional and range requests, without
+// contacting the upstream: the proxy does not trust the HTTPS upstream's
+// certificate, and one mapped host is not listening at all
+func TestMapLocal(t *testing.T) {
+	var upstreamHits atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+		io.WriteString(w, "upstream")
+	})
+	// Counts connections too, as a dial whose certificate is rejected sends no request
+	var upstreamConns atomic.Int32
+	upstream := httptest.NewUnstartedServer(handler)
+	upstream.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			upstreamConns.Add(1)
+		}
+	}
+	upstream.StartTLS()
+	defer upstream.Close()
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "override.js")
+	if err := os.WriteFile(script, []byte("console.log('local')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	site := filepath.Join(dir, "site")
+	if err := os.MkdirAll(filepath.Join(site, "css"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(site, "index.html"), []byte("<h1>index</h1>"), 0644)
+	os.WriteFile(filepath.Join(site, "css", "app.css"), []byte("body{color:red}"), 0644)
+
+	// A closed port standing in for an unreachable production host
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := "https://" + closed.Addr().String()
+	closed.Close()
+
+	rules := proxy.NewMapLocal()
+	for pattern, target := range map[string]string{
+		upstream.URL + "/app/*.js": script,
+		upstream.URL + "/static/*": site,
+		plain.URL + "/static/*":    site,
+		unreachable + "/*":         site,
+	} {
+		if err := rules.Add(pattern, target); err != nil {
+			t.Fatalf("Add(%q) failed: %v", pattern, err)
+		}
+	}
+
+	client, flows := startFlowProxy(t, "127.0.0.1:18278", func(m *proxy.MITMHandler) {
+		m.SetMapLocal(rules)
+	})
+
+	plainClient := startPlainProxy(t, "127.0.0.1:18279", func(p *proxy.ProxyServer) { p.SetMapLocal(rules) })
+
+	// A file rule answers the intercepted request and is recorded in the flow
+	resp, body := mapLocalGet(t, client, upstream.URL+"/app/v2/main.js", nil)
+	if resp.StatusCode != http.StatusOK || body != "console.log('local')" {
+		t.Errorf("Expected local script, got %d %q", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+		t.Errorf("Expected JavaScript Content-Type, got %q", ct)
+	}
+	if flow := receiveFlow(t, flows); flow.LocalFile != script || flow.StatusCode != http.StatusOK {
+		t.Errorf("Expected flow to record %s, got %q (status %d)", script, flow.LocalFile, flow.StatusCode)
+	}
+
+	if resp, body := mapLocalGet(t, client, unreachable+"/", nil); resp.StatusCode != http.StatusOK || body != "<h1>index</h1>" {
+		t.Errorf("Expected local index for an unreachable host, got %d %q", resp.StatusCode, body)
+	}
+	receiveFlow(t, flows)
+
+	for _, tt := range []struct {
+		name   string
+		client *http.Client
+		base   string
+	}{
+		{"https", client, upstream.URL},
+		{"http", plainClient, plain.URL},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := mapLocalGet(t, tt.client, tt.base+"/static/css/app.css", nil)
+			etag := resp.Header.Get("ETag")
+			if resp.StatusCode != http.StatusOK || body != "body{color:red}" || etag == "" {
+				t.Fatalf("Expected nested file with an ETag, got %d %q (ETag %q)", resp.StatusCode, body, etag)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "text/css; charset=utf-8" {
+				t.Errorf("Expected CSS Content-Type, got %q", ct)
+			}
+
+			resp, _ = mapLocalGet(t, tt.client, tt.base+"/static/css/app.css", http.Header{"If-None-Match": {etag}})
+			if resp.StatusCode != http.StatusNotModified {
+				t.Errorf("Expected 304 for a matching ETag, got %d", resp.StatusCode)
+			}
+
+			resp, body = mapLocalGet(t, tt.client, tt.base+"/static/css/app.css", http.Header{"Range": {"bytes=5-9"}})
+			if resp.StatusCode != http.StatusPartialContent || body != "color" ||
+				resp.Header.Get("Content-Range") != "bytes 5-9/15" {
+				t.Errorf("Expected partial content, got %d %q (%s)", resp.StatusCode, body, resp.Header.Get("Content-Range"))
+			}
+
+			if resp, body = mapLocalGet(t, tt.client, tt.base+"/static/", nil); body != "<h1>index</h1>" {
+				t.Errorf("Expected directory index, got %d %q", resp.StatusCode, body)
+			}
+			if resp, _ = mapLocalGet(t, tt.client, tt.base+"/static/../../etc/passwd", nil); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected 404 outside the mapped directory, got %d", resp.StatusCode)
+			}
+			if resp, _ = mapLocalGet(t, tt.client, tt.base+"/static/missing.js", nil); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected 404 for a missing file, got %d", resp.StatusCode)
+			}
+		})
+	}
+
+	if n := upstreamHits.Load(); n != 0 {
+		t.Errorf("Expected mapped requests not to reach the upstream, got %d", n)
+	}
+	if n := upstreamConns.Load(); n != 0 {
+		t.Errorf("Expected no connection to the HTTPS upstream, got %d", n)
+	}
+
+	// Unmatched requests are still relayed
+	if _, body := mapLocalGet(t, plainClient, plain.URL+"/other", nil); body != "upstream" {
+		t.Errorf("Expected unmatched request to be relayed, got %q", body)
+	}
+}
+
+// mapLocalGet fetches rawURL with header and returns the response and its body
+func mapLocalGet(t *testing.T, client *http.Client, rawURL string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
@@ -0,0 +1,103 @@
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestMapRemote tests that matching intercepted HTTPS and plain HTTP requests
+// are sent to another upstream with their scheme, host, port and path rewritten
+func TestMapRemote(t *testing.T) {
+	var mu sync.Mutex
+	var received []*http.Request
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r)
+		mu.Unlock()
+		io.WriteString(w, "local "+r.URL.RequestURI())
+	}))
+	defer local.Close()
+	production := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "production "+r.URL.Path)
+	}))
+	defer production.Close()
+
+	// api.prod.invalid cannot be resolved, so mapped requests must not dial it
+	rules := proxy.NewMapRemote()
+	for pattern, target := range map[string]string{
+		"https://api.prod.invalid/v2/*": local.URL + "/v2/*",
+		production.URL + "/v2/*":        local.URL + "/mapped/*",
+		"http://api.prod.invalid/*":     local.URL + "/plain/*",
+	} {
+		if err := rules.Add(pattern, target); err != nil {
+			t.Fatalf("Add(%q) failed: %v", pattern, err)
+		}
+	}
+
+	client, flows := startFlowProxy(t, "127.0.0.1:18280", trustUpstream(production.Certificate()), func(m *proxy.MITMHandler) {
+		m.SetMapRemote(rules)
+	})
+
+	plainClient := startPlainProxy(t, "127.0.0.1:18281", func(p *proxy.ProxyServer) { p.SetMapRemote(rules) })
+
+	for _, tt := range []struct {
+		name   string
+		client *http.Client
+		url    string
+		want   string
+	}{
+		{"https", client, "https://api.prod.invalid/v2/users?id=1", "local /v2/users?id=1"},
+		{"https port", client, production.URL + "/v2/a/b", "local /mapped/a/b"},
+		{"https unmapped", client, production.URL + "/v1/users", "production /v1/users"},
+		{"http", plainClient, "http://api.prod.invalid/v2/users?id=2", "local /plain/v2/users?id=2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Get(tt.url)
+			if err != nil {
+				t.Fatalf("GET %s failed: %v", tt.url, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || string(body) != tt.want {
+				t.Errorf("Expected %q, got %d %q", tt.want, resp.StatusCode, body)
+			}
+		})
+	}
+
+	// Flows record where intercepted requests were sent
+	for _, want := range []string{local.URL + "/v2/users?id=1", local.URL + "/mapped/a/b", ""} {
+		if flow := receiveFlow(t, flows); flow.MappedURL != want {
+			t.Errorf("Expected MappedURL %q, got %q", want, flow.MappedURL)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 3 {
+		t.Fatalf("Expected 3 requests at the mapped upstream, got %d", len(received))
+	}
+	for _, r := range received {
+		if r.Header.Get("X-Proxied-By") != "GoSniffer" || r.Host != local.Listener.Addr().String() {
+			t.Errorf("Expected header rules and the mapped Host, got Host %q headers %v", r.Host, r.Header)
+		}
+	}
+}
+
+// TestMapRemoteValidation tests that malformed rules are rejected
+func TestMapRemoteValidation(t *testing.T) {
+	for _, rule := range [][2]string{
+		{"api.example.com/*", "http://localhost:9000/*"},
+		{"https://api.example.com/*", "localhost:9000/*"},
+		{"https://api.example.com/*", "ftp://localhost/*"},
+		{"https://api.example.com/v2", "http://localhost:9000/*"},
+	} {
+		if err := proxy.NewMapRemote().Add(rule[0], rule[1]); err == nil {
+			t.Errorf("Expected %q=%q to be rejected", rule[0], rule[1])
+		}
+	}
+}
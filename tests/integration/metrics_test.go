@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/metrics"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestMetricsExposition tests the Prometheus text format of each metric type
+func TestMetricsExposition(t *testing.T) {
+	registry := metrics.NewRegistry()
+	requests := registry.NewCounterVec("test_requests_total", "Requests.", "method", "path")
+	requests.With("GET", `/a"b`).Inc()
+	requests.With("GET", `/a"b`).Add(2)
+	latency := registry.NewHistogram("test_latency_seconds", "Latency\nin seconds.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.1)
+	latency.Observe(3)
+	registry.NewGaugeFunc("test_open", "Open things.", func() float64 { return 7 })
+
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Expected Content-Type %q, got %q", metrics.ContentType, ct)
+	}
+
+	var b strings.Builder
+	registry.WriteTo(&b)
+	want := `# HELP test_latency_seconds Latency\nin seconds.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 2
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.15
+test_latency_seconds_count 3
+# HELP test_open Open things.
+# TYPE test_open gauge
+test_open 7
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",path="/a\"b"} 3
+`
+	if b.String() != want {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+// TestProxyMetrics tests request, cache, certificate and handshake metrics of a running proxy
+func TestProxyMetrics(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	upstream := httptest.NewTLSServer(handler)
+	defer upstream.Close()
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	registry := metrics.NewRegistry()
+	proxyMetrics := proxy.NewMetrics(registry)
+
+	// HTTPS: two requests to the same host (one certificate, one cache hit) and a
+	// client that rejects the proxy's certificate
+	client, _ := startFlowProxy(t, "127.0.0.1:18270", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+		m.SetMetrics(proxyMetrics)
+	})
+	fetchURL(t, client, upstream.URL+"/one")
+	fetchURL(t, client, upstream.URL+"/two")
+	proxyURL, _ := url.Parse("http://127.0.0.1:18270")
+	untrusting := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 10 * time.Second}
+	if _, err := untrusting.Get(upstream.URL); err == nil {
+		t.Error("Expected client without the proxy CA to fail")
+	}
+
+	// Plain HTTP through a server with metrics enabled
+	plainClient := startPlainProxy(t, "127.0.0.1:18271", func(p *proxy.ProxyServer) { p.SetMetrics(proxyMetrics) })
+	fetchURL(t, plainClient, plain.URL+"/plain")
+
+	want := []string{
+		`gosniffer_requests_total{scheme="https",method="GET",status="200"} 2`,
+		`gosniffer_requests_total{scheme="http",method="GET",status="200"} 1`,
+		`gosniffer_transferred_bytes_total{scheme="https",direction="downstream"} 10`,
+		`gosniffer_transferred_bytes_total{scheme="http",direction="downstream"} 5`,
+		`gosniffer_upstream_latency_seconds_count{scheme="https"} 2`,
+		`gosniffer_upstream_latency_seconds_count{scheme="http"} 1`,
+		`gosniffer_certificate_cache_misses_total 1`,
+		`gosniffer_certificate_cache_hits_total 2`,
+		`gosniffer_certificate_generation_seconds_count 1`,
+		`gosniffer_tls_handshake_failures_total{side="client"} 1`,
+		`gosniffer_active_connections 0`,
+	}
+	// Counters are updated after responses are relayed, so wait for the last ones
+	var out string
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var b strings.Builder
+		registry.WriteTo(&b)
+		out = b.String()
+		if containsAll(out, want) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for _, line := range want {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Missing %q in metrics:\n%s", line, out)
+		}
+	}
+}
+
+// containsAll reports whether s contains every line in lines
+func containsAll(s string, lines []string) bool {
+	for _, line := range lines {
+		if !strings.Contains(s, line+"\n") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package integration
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestCAExportFormats tests exporting the root CA in every device-friendly format
+func TestCAExportFormats(t *testing.T) {
+	rootCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	if !bytes.Equal(rootCA.ExportDER(), rootCA.Certificate.Raw) {
+		t.Error("DER export does not match the root certificate")
+	}
+
+	if !regexp.MustCompile(`^[0-9a-f]{8}\.0$`).MatchString(rootCA.AndroidFilename()) {
+		t.Errorf("Unexpected Android filename %q", rootCA.AndroidFilename())
+	}
+
+	first, err := rootCA.ExportMobileConfig()
+	if err != nil {
+		t.Fatalf("Failed to export mobileconfig: %v", err)
+	}
+	second, _ := rootCA.ExportMobileConfig()
+	if !bytes.Equal(first, second) {
+		t.Error("Expected mobileconfig export to be deterministic")
+	}
+	if !bytes.Contains(first, []byte("com.apple.security.root")) {
+		t.Error("mobileconfig does not contain a root certificate payload")
+	}
+
+	dir := t.TempDir()
+	paths, err := rootCA.ExportAll(dir, "secret")
+	if err != nil {
+		t.Fatalf("Failed to export CA: %v", err)
+	}
+	if len(paths) != 5 {
+		t.Fatalf("Expected 5 exported files, got %d", len(paths))
+	}
+	for _, name := range []string{ca.ExportPEMFile, ca.ExportDERFile, ca.ExportPKCS12File, ca.ExportMobileConfigFile, rootCA.AndroidFilename()} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Size() == 0 {
+			t.Errorf("Expected non-empty export file %s: %v", name, err)
+		}
+	}
+}
+
+// TestOnboardingPage tests that the proxy answers the magic onboarding hostname itself
+func TestOnboardingPage(t *testing.T) {
+	rootCA, err := ca.GenerateCA("ecdsa")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	certCache := ca.NewCertificateCache()
+	defer certCache.Stop()
+
+	log := logger.NewLogger()
+	mitmHandler := proxy.NewMITMHandler(rootCA, certCache, log)
+	proxyServer := proxy.NewProxyServerWithMITM("127.0.0.1:18220", log, mitmHandler)
+	proxyServer.SetOnboardingHandler(proxy.NewOnboardingHandler(rootCA, "", log))
+
+	go proxyServer.Start()
+	defer proxyServer.Shutdown(2 * time.Second)
+
+	time.Sleep(200 * time.Millisecond)
+
+	proxyURL, _ := url.Parse("http://127.0.0.1:18220")
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(proxyURL),
+		},
+		Timeout: 5 * time.Second,
+	}
+
+	resp, err := client.Get("http://" + proxy.OnboardingHost + "/")
+	if err != nil {
+		t.Fatalf("Onboarding page request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), ca.Fingerprint(rootCA.Certificate)) {
+		t.Error("Onboarding page does not show the CA fingerprint")
+	}
+
+	resp, err = client.Get("http://" + proxy.OnboardingHost + "/cert/cer")
+	if err != nil {
+		t.Fatalf("Certificate download failed: %v", err)
+	}
+	der, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !bytes.Equal(der, rootCA.Certificate.Raw) {
+		t.Error("Downloaded .cer does not match the root certificate")
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/pkix-cert" {
+		t.Errorf("Expected Content-Type application/pkix-cert, got %s", ct)
+	}
+
+	resp, err = client.Get("http://" + proxy.OnboardingHost + "/missing")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown onboarding path, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,131 @@
+package integration
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+)
+
+// TestRotatingFileSize tests size-based rotation with compression and retention
+func TestRotatingFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gosniffer.log")
+	f, err := logger.OpenRotatingFile(path, logger.RotateOptions{MaxSize: 250, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < 10; i++ {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Size() != 200 {
+		t.Errorf("Expected current file of 200 bytes, got %v, %v", info, err)
+	}
+	backups, err := f.Backups()
+	if err != nil {
+		t.Fatalf("Backups failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 retained backups, got %v", backups)
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, ".gz") {
+			t.Errorf("Expected compressed backup, got %s", backup)
+			continue
+		}
+		if data := gunzip(t, backup); data != line+line {
+			t.Errorf("Unexpected content of %s: %q", backup, data)
+		}
+	}
+}
+
+// TestRotatingFileDaily tests that a file last written yesterday is rotated on the next write
+func TestRotatingFileDaily(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("yesterday\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	yesterday := time.Now().AddDate(0, 0, -1)
+	if err := os.Chtimes(path, yesterday, yesterday); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := logger.OpenRotatingFile(path, logger.RotateOptions{Daily: true})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	f.Write([]byte("today\n"))
+	f.Write([]byte("today again\n"))
+	f.Close()
+
+	if data, _ := os.ReadFile(path); string(data) != "today\ntoday again\n" {
+		t.Errorf("Unexpected current file %q", data)
+	}
+	backups, _ := f.Backups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "yesterday\n" {
+		t.Errorf("Unexpected backup %q", data)
+	}
+}
+
+// TestRotatingFileReopen tests reopening after an external tool moved the file (logrotate)
+func TestRotatingFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gosniffer.log")
+	f, err := logger.OpenRotatingFile(path, logger.RotateOptions{})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("before\n"))
+	moved := filepath.Join(dir, "gosniffer.log.1")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("still old\n"))
+	if err := f.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	f.Write([]byte("after\n"))
+
+	if data, _ := os.ReadFile(moved); string(data) != "before\nstill old\n" {
+		t.Errorf("Unexpected moved file %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "after\n" {
+		t.Errorf("Unexpected reopened file %q", data)
+	}
+}
+
+// gunzip returns the decompressed content of a gzip file
+func gunzip(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Invalid gzip %s: %v", path, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Failed to decompress %s: %v", path, err)
+	}
+	return string(data)
+}
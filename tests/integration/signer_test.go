@@ -0,0 +1,172 @@
+package integration
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+	"github.com/yourusername/go-mitmproxy/pkg/signer"
+)
+
+// startSigningAgent serves rootCA's key on a Unix socket and returns the socket path
+func startSigningAgent(t *testing.T, rootCA *ca.CA) string {
+	t.Helper()
+
+	// Unix socket paths are length-limited, so avoid the long t.TempDir names
+	dir, err := os.MkdirTemp("", "signer")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "signer.sock")
+
+	agent, err := signer.NewAgent(rootCA.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	listener, err := agent.Listen(socketPath, 0600)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go agent.Serve(listener)
+	t.Cleanup(func() { agent.Close() })
+
+	return socketPath
+}
+
+// TestExternalSigner tests issuing certificates with a CA key held by a signing agent
+func TestExternalSigner(t *testing.T) {
+	for _, spec := range []ca.KeySpec{ca.KeyRSA2048, ca.KeyECDSAP384, ca.KeyEd25519} {
+		t.Run(string(spec), func(t *testing.T) {
+			rootCA, err := ca.GenerateCA(spec)
+			if err != nil {
+				t.Fatalf("Failed to generate CA: %v", err)
+			}
+			certPath := filepath.Join(t.TempDir(), "ca-cert.pem")
+			if err := os.WriteFile(certPath, rootCA.CertPEM, 0644); err != nil {
+				t.Fatalf("Failed to write certificate: %v", err)
+			}
+
+			client, err := signer.Dial(startSigningAgent(t, rootCA))
+			if err != nil {
+				t.Fatalf("Failed to dial agent: %v", err)
+			}
+			remoteCA, err := ca.LoadWithSigner(certPath, client)
+			if err != nil {
+				t.Fatalf("LoadWithSigner failed: %v", err)
+			}
+			if remoteCA.KeySpec() != spec {
+				t.Errorf("Expected key spec %s, got %s", spec, remoteCA.KeySpec())
+			}
+
+			bundle, err := remoteCA.GenerateCertificate("signer.example.com", ca.KeyECDSAP256)
+			if err != nil {
+				t.Fatalf("Failed to issue certificate through agent: %v", err)
+			}
+			roots := x509.NewCertPool()
+			roots.AddCert(rootCA.Certificate)
+			if _, err := bundle.Certificate.Verify(x509.VerifyOptions{DNSName: "signer.example.com", Roots: roots}); err != nil {
+				t.Errorf("Certificate signed by agent does not verify: %v", err)
+			}
+		})
+	}
+}
+
+// TestExternalSignerMismatch tests that a certificate for another key is refused
+func TestExternalSignerMismatch(t *testing.T) {
+	rootCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	otherCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	certPath := filepath.Join(t.TempDir(), "ca-cert.pem")
+	if err := os.WriteFile(certPath, otherCA.CertPEM, 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	client, err := signer.Dial(startSigningAgent(t, rootCA))
+	if err != nil {
+		t.Fatalf("Failed to dial agent: %v", err)
+	}
+	if _, err := ca.LoadWithSigner(certPath, client); err == nil {
+		t.Error("Expected LoadWithSigner to reject a certificate that does not match the agent key")
+	}
+}
+
+// TestExternalSignerRefusesRawSigning tests that the agent only signs the
+// digests certificate issuance uses, not arbitrary data
+func TestExternalSignerRefusesRawSigning(t *testing.T) {
+	rootCA, err := ca.GenerateCA(ca.KeyRSA2048)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	client, err := signer.Dial(startSigningAgent(t, rootCA))
+	if err != nil {
+		t.Fatalf("Failed to dial agent: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("tbs"))
+	if _, err := client.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+		t.Errorf("Expected SHA-256 digest to be signed: %v", err)
+	}
+	if _, err := client.Sign(rand.Reader, []byte("arbitrary PKCS#1 payload"), crypto.Hash(0)); err == nil {
+		t.Error("Expected raw signing without a hash to be refused for an RSA key")
+	}
+	if _, err := client.Sign(rand.Reader, digest[:16], crypto.SHA256); err == nil {
+		t.Error("Expected a digest of the wrong length to be refused")
+	}
+	if _, err := client.Sign(rand.Reader, digest[:20], crypto.SHA1); err == nil {
+		t.Error("Expected SHA-1 to be refused")
+	}
+}
+
+// TestExternalSignerProxy tests MITM interception with the CA key held by an agent
+func TestExternalSignerProxy(t *testing.T) {
+	rootCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	certPath := filepath.Join(t.TempDir(), "ca-cert.pem")
+	if err := os.WriteFile(certPath, rootCA.CertPEM, 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	client, err := signer.Dial(startSigningAgent(t, rootCA))
+	if err != nil {
+		t.Fatalf("Failed to dial agent: %v", err)
+	}
+	remoteCA, err := ca.LoadWithSigner(certPath, client)
+	if err != nil {
+		t.Fatalf("LoadWithSigner failed: %v", err)
+	}
+
+	certCache := ca.NewCertificateCache()
+	defer certCache.Stop()
+
+	log := logger.NewLogger()
+	mitmHandler := proxy.NewMITMHandler(remoteCA, certCache, log)
+	proxyServer := proxy.NewProxyServerWithMITM("127.0.0.1:18250", log, mitmHandler)
+
+	go proxyServer.Start()
+	defer proxyServer.Shutdown(2 * time.Second)
+
+	time.Sleep(200 * time.Millisecond)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCA.Certificate)
+	leaf := connectAndHandshake(t, "127.0.0.1:18250", "agent.example.com", roots)
+	if !bytes.Equal(leaf.AuthorityKeyId, rootCA.Certificate.SubjectKeyId) {
+		t.Error("Expected leaf to be issued by the agent-held CA")
+	}
+}
@@ -0,0 +1,71 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// upstreamDelay is how long the test upstreams wait before answering
+const upstreamDelay = 50 * time.Millisecond
+
+// TestFlowTimings tests the timing breakdown of an intercepted flow
+func TestFlowTimings(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(upstreamDelay)
+		w.Write([]byte("slow"))
+	}))
+	defer upstream.Close()
+
+	client, flows := startFlowProxy(t, "127.0.0.1:18272", trustUpstream(upstream.Certificate()))
+	fetchURL(t, client, upstream.URL+"/slow")
+
+	timings := receiveFlow(t, flows).Timings
+	if timings.ClientTLS <= 0 || timings.TCPConnect <= 0 || timings.UpstreamTLS <= 0 {
+		t.Errorf("Expected connection phases to be timed, got %+v", timings)
+	}
+	if timings.TimeToFirstByte < upstreamDelay {
+		t.Errorf("Expected time to first byte of at least %v, got %v", upstreamDelay, timings.TimeToFirstByte)
+	}
+}
+
+// TestPlainHTTPTimings tests that plain HTTP timings are traced into the access log
+func TestPlainHTTPTimings(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(upstreamDelay)
+		w.Write([]byte("slow"))
+	}))
+	defer upstream.Close()
+
+	entries := &syncBuffer{}
+	accessLog, err := logger.NewAccessLog(entries, logger.AccessFormatJSON)
+	if err != nil {
+		t.Fatalf("NewAccessLog failed: %v", err)
+	}
+
+	plainClient := startPlainProxy(t, "127.0.0.1:18273", func(p *proxy.ProxyServer) { p.SetAccessLog(accessLog) })
+	fetchURL(t, plainClient, upstream.URL+"/plain")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(entries.String(), "\n") && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	var entry struct {
+		Timings map[string]float64 `json:"timings"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(entries.String())), &entry); err != nil {
+		t.Fatalf("Invalid access log entry %q: %v", entries.String(), err)
+	}
+	if entry.Timings["connect_ms"] <= 0 {
+		t.Errorf("Expected connect time, got %v", entry.Timings)
+	}
+	if ttfb := entry.Timings["ttfb_ms"]; ttfb < float64(upstreamDelay/time.Millisecond) {
+		t.Errorf("Expected ttfb_ms of at least %d, got %v", upstreamDelay/time.Millisecond, entry.Timings)
+	}
+}
@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// TestParseTLSSettings tests the TLS settings syntax used by -client-tls and -upstream-tls
+func TestParseTLSSettings(t *testing.T) {
+	s, err := proxy.ParseTLSSettings("min=1.0; max=TLS1.2; ciphers=TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,TLS_RSA_WITH_AES_128_CBC_SHA; curves=X25519,P-256; alpn=http/1.1")
+	if err != nil {
+		t.Fatalf("ParseTLSSettings failed: %v", err)
+	}
+	if s.MinVersion != tls.VersionTLS10 || s.MaxVersion != tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.0-1.2, got %x-%x", s.MinVersion, s.MaxVersion)
+	}
+	if len(s.CipherSuites) != 2 || s.CipherSuites[1] != tls.TLS_RSA_WITH_AES_128_CBC_SHA {
+		t.Errorf("Unexpected cipher suites %v", s.CipherSuites)
+	}
+	if len(s.CurvePreferences) != 2 || s.CurvePreferences[1] != tls.CurveP256 {
+		t.Errorf("Unexpected curves %v", s.CurvePreferences)
+	}
+	if len(s.NextProtos) != 1 || s.NextProtos[0] != "http/1.1" {
+		t.Errorf("Unexpected ALPN %v", s.NextProtos)
+	}
+
+	for _, spec := range []string{"min=1.3;max=1.2", "min=1.4", "ciphers=TLS_FOO", "curves=P224", "alpn=h2", "sni=off", "min"} {
+		if _, err := proxy.ParseTLSSettings(spec); err == nil {
+			t.Errorf("Expected ParseTLSSettings(%q) to fail", spec)
+		}
+	}
+}
+
+// TestTLSProfiles tests per-side TLS versions with per-host overrides
+func TestTLSProfiles(t *testing.T) {
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	upstream.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	// configure trusts the upstream and applies client and upstream settings
+	configure := func(client, upstreamSide *proxy.TLSProfiles) func(*proxy.MITMHandler) {
+		return func(m *proxy.MITMHandler) {
+			trustUpstream(upstream.Certificate())(m)
+			if client != nil {
+				m.SetClientTLSProfiles(client)
+			}
+			if upstreamSide != nil {
+				m.SetUpstreamTLSProfiles(upstreamSide)
+			}
+		}
+	}
+	// get fetches the upstream with the client limited to versions min-max
+	get := func(client *http.Client, min, max uint16) (*http.Response, error) {
+		config := client.Transport.(*http.Transport).TLSClientConfig
+		config.MinVersion, config.MaxVersion = min, max
+		resp, err := client.Get(upstream.URL)
+		if err == nil {
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	t.Run("client TLS 1.3 only", func(t *testing.T) {
+		profiles, err := proxy.NewTLSProfiles(proxy.TLSSettings{MinVersion: tls.VersionTLS13})
+		if err != nil {
+			t.Fatalf("NewTLSProfiles failed: %v", err)
+		}
+		client, flows := startFlowProxy(t, "127.0.0.1:18264", configure(profiles, nil))
+
+		if _, err := get(client, tls.VersionTLS12, tls.VersionTLS12); err == nil {
+			t.Error("Expected TLS 1.2 client to be refused")
+		}
+		if _, err := get(client, 0, 0); err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		flow := receiveFlow(t, flows)
+		if flow.ClientTLSVersion != tls.VersionTLS13 || flow.UpstreamTLSVersion != tls.VersionTLS12 {
+			t.Errorf("Expected client TLS 1.3 and upstream TLS 1.2, got %x and %x",
+				flow.ClientTLSVersion, flow.UpstreamTLSVersion)
+		}
+	})
+
+	t.Run("legacy client host override", func(t *testing.T) {
+		profiles, err := proxy.NewTLSProfiles(proxy.TLSSettings{})
+		if err != nil {
+			t.Fatalf("NewTLSProfiles failed: %v", err)
+		}
+		if err := profiles.Add("127.0.0.1", proxy.TLSSettings{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if got := profiles.ForHost("example.com"); got.MinVersion != tls.VersionTLS12 {
+			t.Errorf("Expected default TLS 1.2 minimum for other hosts, got %x", got.MinVersion)
+		}
+		client, flows := startFlowProxy(t, "127.0.0.1:18265", configure(profiles, nil))
+
+		if _, err := get(client, tls.VersionTLS10, tls.VersionTLS11); err != nil {
+			t.Fatalf("TLS 1.1 client request failed: %v", err)
+		}
+		if flow := receiveFlow(t, flows); flow.ClientTLSVersion != tls.VersionTLS11 {
+			t.Errorf("Expected client TLS 1.1, got %x", flow.ClientTLSVersion)
+		}
+	})
+
+	t.Run("upstream TLS 1.3 only", func(t *testing.T) {
+		profiles, err := proxy.NewTLSProfiles(proxy.TLSSettings{MinVersion: tls.VersionTLS13})
+		if err != nil {
+			t.Fatalf("NewTLSProfiles failed: %v", err)
+		}
+		client, _ := startFlowProxy(t, "127.0.0.1:18266", configure(nil, profiles))
+
+		resp, err := get(client, 0, 0)
+		if err != nil {
+			t.Fatalf("Expected error page, got error: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("Expected 502 for TLS 1.2-only upstream, got %d", resp.StatusCode)
+		}
+	})
+
+	if _, err := proxy.NewTLSProfiles(proxy.TLSSettings{MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS12}); err == nil ||
+		!strings.Contains(err.Error(), "above maximum") {
+		t.Errorf("Expected error for inverted versions, got %v", err)
+	}
+}
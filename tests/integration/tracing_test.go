@@ -0,0 +1,228 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+	"github.com/yourusername/go-mitmproxy/pkg/tracing"
+)
+
+// TestParseTraceparent tests parsing and formatting of the traceparent header
+func TestParseTraceparent(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := tracing.ParseTraceparent(valid)
+	if err != nil {
+		t.Fatalf("ParseTraceparent(%q) failed: %v", valid, err)
+	}
+	if !sc.IsSampled() || sc.Traceparent() != valid {
+		t.Errorf("Expected sampled %s, got %s", valid, sc.Traceparent())
+	}
+
+	// Future versions may append fields
+	if _, err := tracing.ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Errorf("Expected future version to parse, got %v", err)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		if _, err := tracing.ParseTraceparent(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+// exportedSpan is the part of an OTLP JSON span checked by the tests
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Status       *struct {
+		Code int `json:"code"`
+	} `json:"status"`
+}
+
+// stubCollector records the spans posted to it as OTLP/HTTP JSON
+type stubCollector struct {
+	mu       sync.Mutex
+	spans    []exportedSpan
+	services []string
+}
+
+func (c *stubCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string `json:"key"`
+					Value struct {
+						StringValue string `json:"stringValue"`
+					} `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []exportedSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if r.URL.Path != tracing.TracesPath || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, a := range rs.Resource.Attributes {
+			if a.Key == "service.name" {
+				c.services = append(c.services, a.Value.StringValue)
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	w.Write([]byte("{}"))
+}
+
+// byName returns the collected spans of the trace keyed by name
+func (c *stubCollector) byName(traceID string) map[string]exportedSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	spans := make(map[string]exportedSpan)
+	for _, s := range c.spans {
+		if s.TraceID == traceID {
+			spans[s.Name] = s
+		}
+	}
+	return spans
+}
+
+// TestTracingPropagationAndExport tests traceparent propagation and span export
+// for intercepted HTTPS and plain HTTP requests
+func TestTracingPropagationAndExport(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]http.Header)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		io.WriteString(w, "ok")
+	})
+	upstream := httptest.NewTLSServer(handler)
+	defer upstream.Close()
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	collector := &stubCollector{}
+	collectorServer := httptest.NewServer(collector)
+	defer collectorServer.Close()
+	exporter, err := tracing.NewExporter(collectorServer.URL, tracing.ExporterOptions{ServiceName: "gosniffer-test"})
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+	tracer := tracing.NewTracer(exporter)
+
+	// HTTPS request continuing the client's trace
+	client, _ := startFlowProxy(t, "127.0.0.1:18274", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+		m.SetTracer(tracer)
+	})
+	const clientTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const clientSpan = "00f067aa0ba902b7"
+	req, _ := http.NewRequest(http.MethodGet, upstream.URL+"/secure", nil)
+	req.Header.Set("traceparent", "00-"+clientTrace+"-"+clientSpan+"-01")
+	req.Header.Set("tracestate", "vendor=value")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Plain HTTP request starting a new trace
+	plainClient := startPlainProxy(t, "127.0.0.1:18275", func(p *proxy.ProxyServer) { p.SetTracer(tracer) })
+	fetchURL(t, plainClient, plain.URL+"/plain")
+
+	// Spans end after the response has been relayed, so let the exchanges finish
+	time.Sleep(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	mu.Lock()
+	secureHeader, plainHeader := received["/secure"], received["/plain"]
+	mu.Unlock()
+
+	// The upstream continues the client's trace from the proxy's span
+	forwarded, err := tracing.ParseTraceparent(secureHeader.Get("traceparent"))
+	if err != nil {
+		t.Fatalf("Invalid forwarded traceparent: %v", err)
+	}
+	if forwarded.TraceID.String() != clientTrace || forwarded.SpanID.String() == clientSpan || !forwarded.IsSampled() {
+		t.Errorf("Expected trace %s continued from a new span, got %s", clientTrace, forwarded.Traceparent())
+	}
+	if got := secureHeader.Get("tracestate"); got != "vendor=value" {
+		t.Errorf("Expected tracestate to be passed on, got %q", got)
+	}
+
+	spans := collector.byName(clientTrace)
+	request, ok := spans["GET"]
+	if !ok {
+		t.Fatalf("Missing request span in %v", collector.spans)
+	}
+	if request.SpanID != forwarded.SpanID.String() || request.ParentSpanID != clientSpan ||
+		request.Kind != int(tracing.SpanKindServer) || request.Status != nil {
+		t.Errorf("Unexpected request span %+v", request)
+	}
+	for _, name := range []string{"tls.client_handshake", "upstream.connect"} {
+		if s, ok := spans[name]; !ok || s.ParentSpanID != request.SpanID {
+			t.Errorf("Expected %s span as child of the request span, got %+v", name, s)
+		}
+	}
+
+	// A new trace is started for requests without trace context
+	started, err := tracing.ParseTraceparent(plainHeader.Get("traceparent"))
+	if err != nil {
+		t.Fatalf("Invalid generated traceparent: %v", err)
+	}
+	plainSpans := collector.byName(started.TraceID.String())
+	if s, ok := plainSpans["GET"]; !ok || s.ParentSpanID != "" || s.SpanID != started.SpanID.String() {
+		t.Errorf("Expected root request span for the plain request, got %+v", plainSpans)
+	}
+	if _, ok := plainSpans["upstream.connect"]; !ok {
+		t.Errorf("Expected upstream.connect span for the plain request, got %+v", plainSpans)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	for _, service := range collector.services {
+		if service != "gosniffer-test" {
+			t.Errorf("Unexpected service.name %q", service)
+		}
+	}
+	if !strings.HasSuffix(exporter.Endpoint(), tracing.TracesPath) {
+		t.Errorf("Expected endpoint to end in %s, got %s", tracing.TracesPath, exporter.Endpoint())
+	}
+}
@@ -0,0 +1,319 @@
+package integration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-mitmproxy/pkg/ca"
+	"github.com/yourusername/go-mitmproxy/pkg/logger"
+	"github.com/yourusername/go-mitmproxy/pkg/proxy"
+)
+
+// startFlowProxy starts a MITM proxy on addr, configured by each of configure in
+// turn, and returns a client that trusts the proxy CA plus a channel receiving
+// completed flows
+func startFlowProxy(t *testing.T, addr string, configure ...func(*proxy.MITMHandler)) (*http.Client, <-chan *proxy.Flow) {
+	t.Helper()
+
+	rootCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	certCache := ca.NewCertificateCache()
+	t.Cleanup(certCache.Stop)
+
+	flows := make(chan *proxy.Flow, 16)
+	log := logger.NewLogger()
+	mitmHandler := proxy.NewMITMHandler(rootCA, certCache, log)
+	mitmHandler.SetFlowHandler(func(f *proxy.Flow) { flows <- f })
+	for _, option := range configure {
+		if option != nil {
+			option(mitmHandler)
+		}
+	}
+	proxyServer := proxy.NewProxyServerWithMITM(addr, log, mitmHandler)
+
+	go proxyServer.Start()
+	t.Cleanup(func() { proxyServer.Shutdown(2 * time.Second) })
+
+	time.Sleep(200 * time.Millisecond)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCA.Certificate)
+	proxyURL, _ := url.Parse("http://" + addr)
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyURL(proxyURL),
+			TLSClientConfig:   &tls.Config{RootCAs: roots},
+			DisableKeepAlives: true,
+		},
+		Timeout: 10 * time.Second,
+	}
+	return client, flows
+}
+
+// startPlainProxy starts a proxy server on addr, configured by each of configure
+// in turn, and returns a client that sends plain HTTP requests through it
+func startPlainProxy(t *testing.T, addr string, configure ...func(*proxy.ProxyServer)) *http.Client {
+	t.Helper()
+
+	proxyServer := proxy.NewProxyServer(addr, logger.NewLogger())
+	for _, option := range configure {
+		option(proxyServer)
+	}
+
+	go proxyServer.Start()
+	t.Cleanup(func() { proxyServer.Shutdown(2 * time.Second) })
+
+	time.Sleep(200 * time.Millisecond)
+
+	proxyURL, _ := url.Parse("http://" + addr)
+	return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 10 * time.Second}
+}
+
+// trustUpstream returns a startFlowProxy option that trusts cert for upstream connections
+func trustUpstream(cert *x509.Certificate) func(*proxy.MITMHandler) {
+	return func(m *proxy.MITMHandler) {
+		policy := proxy.NewUpstreamTLSPolicy()
+		policy.AddRootCAs(cert)
+		m.SetUpstreamTLSPolicy(policy)
+	}
+}
+
+// receiveFlow waits for the next completed flow
+func receiveFlow(t *testing.T, flows <-chan *proxy.Flow) *proxy.Flow {
+	t.Helper()
+	select {
+	case f := <-flows:
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for flow")
+		return nil
+	}
+}
+
+// TestUpstreamTLSPolicy tests extra roots, insecure hosts and SPKI pinning
+func TestUpstreamTLSPolicy(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	upstreamCert := upstream.Certificate()
+	otherCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		addr       string
+		configure  func(*proxy.UpstreamTLSPolicy) error
+		wantOK     bool
+		wantVerify bool
+		wantPinned bool
+	}{
+		{
+			name:      "untrusted by default",
+			addr:      "127.0.0.1:18253",
+			configure: func(p *proxy.UpstreamTLSPolicy) error { return nil },
+		},
+		{
+			name: "trusted root",
+			addr: "127.0.0.1:18254",
+			configure: func(p *proxy.UpstreamTLSPolicy) error {
+				p.AddRootCAs(upstreamCert)
+				return nil
+			},
+			wantOK:     true,
+			wantVerify: true,
+		},
+		{
+			name: "insecure host",
+			addr: "127.0.0.1:18255",
+			configure: func(p *proxy.UpstreamTLSPolicy) error {
+				return p.AddInsecureHost("127.0.0.1")
+			},
+			wantOK: true,
+		},
+		{
+			name: "matching pin",
+			addr: "127.0.0.1:18256",
+			configure: func(p *proxy.UpstreamTLSPolicy) error {
+				p.AddRootCAs(upstreamCert)
+				return p.AddPin("127.0.0.1", proxy.SPKIPin(upstreamCert))
+			},
+			wantOK:     true,
+			wantVerify: true,
+			wantPinned: true,
+		},
+		{
+			name: "mismatched pin",
+			addr: "127.0.0.1:18257",
+			configure: func(p *proxy.UpstreamTLSPolicy) error {
+				p.AddRootCAs(upstreamCert)
+				return p.AddPin("127.0.0.1", proxy.SPKIPin(otherCA.Certificate))
+			},
+			wantVerify: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := proxy.NewUpstreamTLSPolicy()
+			if err := tt.configure(policy); err != nil {
+				t.Fatalf("Failed to configure policy: %v", err)
+			}
+			client, flows := startFlowProxy(t, tt.addr, func(m *proxy.MITMHandler) {
+				m.SetUpstreamTLSPolicy(policy)
+			})
+
+			resp, err := client.Get(upstream.URL)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			// Rejected certificates are reported to the client as 495
+			wantStatus := http.StatusOK
+			if !tt.wantOK {
+				wantStatus = proxy.StatusUpstreamCertificateError
+			}
+			if resp.StatusCode != wantStatus {
+				t.Errorf("Expected status %d, got %d", wantStatus, resp.StatusCode)
+			}
+
+			v := receiveFlow(t, flows).UpstreamTLS
+			if v == nil {
+				t.Fatal("Expected upstream verification on flow")
+			}
+			if len(v.Chain) == 0 || !v.Chain[0].Equal(upstreamCert) {
+				t.Error("Expected upstream chain to be recorded")
+			}
+			if v.Verified != tt.wantVerify || v.Pinned != tt.wantPinned {
+				t.Errorf("Expected verified=%v pinned=%v, got %+v", tt.wantVerify, tt.wantPinned, v)
+			}
+			if (!v.Verified || !tt.wantOK) && v.Error == "" {
+				t.Error("Expected verification error to be recorded for unverified or rejected chain")
+			}
+		})
+	}
+
+	if err := proxy.NewUpstreamTLSPolicy().AddPin("example.com", "sha256/not-a-hash"); err == nil {
+		t.Error("Expected error for malformed pin")
+	}
+}
+
+// TestUpstreamPinIgnoresUnverifiedCertificates tests that a pinned certificate
+// appended to an otherwise unrelated chain does not satisfy the pin
+func TestUpstreamPinIgnoresUnverifiedCertificates(t *testing.T) {
+	trustedCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	pinnedCA, err := ca.GenerateCA(ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	trustedLeaf, err := trustedCA.GenerateCertificate("pinned.example.com", ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	pinnedLeaf, err := pinnedCA.GenerateCertificate("pinned.example.com", ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	pin := proxy.SPKIPin(pinnedCA.Certificate)
+
+	policy := proxy.NewUpstreamTLSPolicy()
+	policy.AddRootCAs(trustedCA.Certificate)
+	if err := policy.AddPin("pinned.example.com", pin); err != nil {
+		t.Fatalf("Failed to add pin: %v", err)
+	}
+
+	// A publicly trusted leaf with the pinned certificate appended as an "intermediate"
+	v, err := policy.Verify("pinned.example.com", []*x509.Certificate{trustedLeaf.Certificate, pinnedCA.Certificate})
+	if err == nil || v.Pinned {
+		t.Errorf("Expected appended pinned certificate to be rejected, got %+v", v)
+	}
+	if !v.Verified {
+		t.Error("Expected the trusted leaf itself to verify")
+	}
+
+	// Insecure hosts are not verified, so only the leaf can match a pin
+	insecure := proxy.NewUpstreamTLSPolicy()
+	if err := insecure.AddInsecureHost("pinned.example.com"); err != nil {
+		t.Fatalf("Failed to add insecure host: %v", err)
+	}
+	if err := insecure.AddPin("pinned.example.com", proxy.SPKIPin(pinnedLeaf.Certificate)); err != nil {
+		t.Fatalf("Failed to add pin: %v", err)
+	}
+	if _, err := insecure.Verify("pinned.example.com", []*x509.Certificate{trustedLeaf.Certificate, pinnedLeaf.Certificate}); err == nil {
+		t.Error("Expected appended pinned certificate to be rejected for an insecure host")
+	}
+	if v, err := insecure.Verify("pinned.example.com", []*x509.Certificate{pinnedLeaf.Certificate}); err != nil || !v.Pinned {
+		t.Errorf("Expected pinned leaf to be accepted for an insecure host: %v", err)
+	}
+}
+
+// TestUpstreamMutualTLS tests presenting a client certificate to an upstream that
+// requires one, and recording both client certificate subjects on the flow
+func TestUpstreamMutualTLS(t *testing.T) {
+	clientCert, err := proxy.LoadClientCertificatePKCS12(filepath.Join("testdata", "client.p12"), "secret")
+	if err != nil {
+		t.Fatalf("Failed to load client certificate: %v", err)
+	}
+	clientCA, err := x509.ParseCertificate(clientCert.Certificate[1])
+	if err != nil {
+		t.Fatalf("Failed to parse client CA: %v", err)
+	}
+
+	upstreamSubject := ""
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamSubject = r.TLS.PeerCertificates[0].Subject.CommonName
+		w.Write([]byte("ok"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA)
+	upstream.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	client, flows := startFlowProxy(t, "127.0.0.1:18258", trustUpstream(upstream.Certificate()), func(m *proxy.MITMHandler) {
+		certs := proxy.NewClientCertificates()
+		if err := certs.Add("127.0.0.1", clientCert); err != nil {
+			t.Fatalf("Failed to add client certificate: %v", err)
+		}
+		m.SetClientCertificates(certs)
+		m.SetRequestClientCert(true)
+	})
+	client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{*clientCert}
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("Request through proxy to mTLS upstream failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if upstreamSubject != "test-client" {
+		t.Errorf("Expected upstream to see client certificate CN=test-client, got %q", upstreamSubject)
+	}
+
+	flow := receiveFlow(t, flows)
+	if flow.UpstreamClientCertSubject != clientCert.Leaf.Subject.String() {
+		t.Errorf("Expected upstream client certificate subject on flow, got %q", flow.UpstreamClientCertSubject)
+	}
+	if flow.ClientCertSubject != clientCert.Leaf.Subject.String() {
+		t.Errorf("Expected downstream client certificate subject on flow, got %q", flow.ClientCertSubject)
+	}
+}